@@ -0,0 +1,84 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// The resource exists in Datadog and is in sync with the spec
+	ConditionReady = "Ready"
+	// The spec was successfully applied to Datadog
+	ConditionSynced = "Synced"
+	// The resource in Datadog no longer matches the spec
+	ConditionDrifted = "Drifted"
+	// The last reconcile failed
+	ConditionDegraded = "Degraded"
+)
+
+// Condition describes one aspect of the current state of a resource. It has
+// the same fields as the metav1.Condition added in Kubernetes 1.19.
+type Condition struct {
+	// Type of the condition, one of Ready, Synced, Drifted or Degraded
+	Type string `json:"type"`
+	// Status of the condition, one of True, False or Unknown
+	// +kubebuilder:validation:Enum=True;False;Unknown
+	Status metav1.ConditionStatus `json:"status"`
+	// Machine readable reason for the last transition
+	Reason string `json:"reason,omitempty"`
+	// Human readable details about the last transition
+	Message string `json:"message,omitempty"`
+	// When the condition last changed status
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// FindCondition returns the condition of the given type or nil
+func FindCondition(conditions []Condition, conditionType string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates a condition and reports whether anything changed.
+// LastTransitionTime is only moved when the status of the condition changes.
+func SetCondition(conditions *[]Condition, newCondition Condition) bool {
+	existing := FindCondition(*conditions, newCondition.Type)
+	if existing == nil {
+		if newCondition.LastTransitionTime.IsZero() {
+			newCondition.LastTransitionTime = metav1.Now()
+		}
+		*conditions = append(*conditions, newCondition)
+		return true
+	}
+
+	if existing.Status == newCondition.Status && existing.Reason == newCondition.Reason && existing.Message == newCondition.Message {
+		return false
+	}
+
+	if existing.Status != newCondition.Status {
+		existing.Status = newCondition.Status
+		existing.LastTransitionTime = metav1.Now()
+	}
+	existing.Reason = newCondition.Reason
+	existing.Message = newCondition.Message
+
+	return true
+}
@@ -0,0 +1,32 @@
+package v1beta1
+
+import (
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestSetCondition(t *testing.T) {
+	conditions := []Condition{}
+	past := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	assert.True(t, SetCondition(&conditions, Condition{Type: ConditionReady, Status: metav1.ConditionTrue, Reason: "Created"}))
+	assert.False(t, FindCondition(conditions, ConditionReady).LastTransitionTime.IsZero())
+	assert.Nil(t, FindCondition(conditions, ConditionSynced))
+
+	conditions[0].LastTransitionTime = past
+
+	assert.False(t, SetCondition(&conditions, Condition{Type: ConditionReady, Status: metav1.ConditionTrue, Reason: "Created"}))
+
+	// A new reason or message keeps the transition time
+	assert.True(t, SetCondition(&conditions, Condition{Type: ConditionReady, Status: metav1.ConditionTrue, Reason: "InSync"}))
+	assert.Equal(t, "InSync", conditions[0].Reason)
+	assert.Equal(t, past, conditions[0].LastTransitionTime)
+
+	assert.True(t, SetCondition(&conditions, Condition{Type: ConditionReady, Status: metav1.ConditionFalse, Reason: "FailedUpdate", Message: "invalid query"}))
+	assert.Equal(t, metav1.ConditionFalse, conditions[0].Status)
+	assert.Equal(t, "invalid query", conditions[0].Message)
+	assert.True(t, conditions[0].LastTransitionTime.After(past.Time))
+	assert.Len(t, conditions, 1)
+}
@@ -0,0 +1,75 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The Datadog sites a DatadogCredentials can use. The keys in its Secrets are
+// sent to the site, so only sites of Datadog are allowed.
+var DatadogSites = []string{
+	"datadoghq.com",
+	"datadoghq.eu",
+	"us3.datadoghq.com",
+	"us5.datadoghq.com",
+	"ap1.datadoghq.com",
+	"ddog-gov.com",
+}
+
+type SecretKeyRef struct {
+	// The name of the Secret in the same namespace
+	Name string `json:"name"`
+	// The key in the Secret
+	Key string `json:"key"`
+}
+
+// DatadogCredentialsSpec defines the desired state of DatadogCredentials
+type DatadogCredentialsSpec struct {
+	// The Datadog site of the organization, one of `datadoghq.com`, `datadoghq.eu`, `us3.datadoghq.com`, `us5.datadoghq.com`, `ap1.datadoghq.com` or `ddog-gov.com`. Defaults to the site the controller is configured with.
+	// +kubebuilder:validation:Enum=datadoghq.com;datadoghq.eu;us3.datadoghq.com;us5.datadoghq.com;ap1.datadoghq.com;ddog-gov.com
+	Host string `json:"host,omitempty"`
+	// The Secret key holding the API key of the organization
+	ApiKeySecretRef SecretKeyRef `json:"api_key_secret_ref"`
+	// The Secret key holding the application key of the organization
+	AppKeySecretRef SecretKeyRef `json:"app_key_secret_ref"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=datadogcredentials
+// +kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`,description="The Datadog site of the organization"
+
+// DatadogCredentials is the Schema for the datadogcredentials API. DatadogMonitors, DatadogDowntimes and DatadogSLOs in the same namespace use it with `credentials_ref`.
+type DatadogCredentials struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DatadogCredentialsSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatadogCredentialsList contains a list of DatadogCredentials
+type DatadogCredentialsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatadogCredentials `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatadogCredentials{}, &DatadogCredentialsList{})
+}
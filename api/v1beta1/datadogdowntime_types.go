@@ -0,0 +1,103 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type DatadogDowntimeRecurrence struct {
+	// How often to repeat as an integer. For example, to repeat every 3 days, set a type of `days` and a period of 3.
+	Period int32 `json:"period,omitempty"`
+	// The type of recurrence. Must be one of: "days", "weeks", "months", "years" or "rrule"
+	// +kubebuilder:validation:Enum=days;weeks;months;years;rrule
+	Type string `json:"type"`
+	// The `RRULE` standard for defining recurring events, used when the type is `rrule`. For example, to have a recurring event on the first day of each month, use `FREQ=MONTHLY;INTERVAL=1`.
+	Rrule string `json:"rrule,omitempty"`
+	// When the recurrence ends
+	UntilDate *metav1.Time `json:"until_date,omitempty"`
+	// How many times the downtime is rescheduled
+	UntilOccurrences int32 `json:"until_occurrences,omitempty"`
+	// The days of the week to repeat on, used when the type is `weeks`. Must be any of: "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"
+	WeekDays []string `json:"week_days,omitempty"`
+}
+
+// DatadogDowntimeSpec defines the desired state of DatadogDowntime
+type DatadogDowntimeSpec struct {
+	// The scope to apply the downtime to, e.g. `env:staging`. Defaults to `*`, all scopes.
+	Scope []string `json:"scope,omitempty"`
+	// Only mute monitors with all of these tags
+	MonitorTags []string `json:"monitor_tags,omitempty"`
+	// The name of a DatadogMonitor in the same namespace to mute. Its ID is read from the status of the DatadogMonitor once it's created.
+	MonitorRef string `json:"monitor_ref,omitempty"`
+	// The ID of a single monitor to mute. Ignored when `monitor_ref` is set.
+	MonitorId int64 `json:"monitor_id,omitempty"`
+	// When the downtime starts. Defaults to when it's created.
+	Start *metav1.Time `json:"start,omitempty"`
+	// When the downtime ends. Defaults to never.
+	End *metav1.Time `json:"end,omitempty"`
+	// A message to include with notifications for this downtime
+	Message string `json:"message,omitempty"`
+	// Repeat the downtime on a schedule
+	Recurrence *DatadogDowntimeRecurrence `json:"recurrence,omitempty"`
+	// The name of a DatadogCredentials in the same namespace with the keys of the Datadog organization to use. Defaults to the organization the controller is configured with.
+	CredentialsRef string `json:"credentials_ref,omitempty"`
+}
+
+// DatadogDowntimeStatus defines the observed state of DatadogDowntime
+type DatadogDowntimeStatus struct {
+	// The downtime ID in Datadog
+	Id int64 `json:"id,omitempty"`
+	// The ID of the muted monitor, resolved from `monitor_ref` or `monitor_id`
+	MonitorId int64 `json:"monitor_id,omitempty"`
+	// The generation of the spec that was last applied to Datadog
+	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+	// What would be done in Datadog to apply the spec. Only set in dry-run mode.
+	Plan *Plan `json:"plan,omitempty"`
+	// Current state of the downtime. The Ready, Synced and Degraded conditions are set.
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,description="Whether the downtime exists in Datadog and is in sync"
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`,description="Reason for the last change of the Ready condition"
+// +kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`,description="The downtime ID in Datadog"
+// +kubebuilder:printcolumn:name="Start",type=string,JSONPath=`.spec.start`,description="When the downtime starts"
+// +kubebuilder:printcolumn:name="End",type=string,JSONPath=`.spec.end`,description="When the downtime ends"
+
+// DatadogDowntime is the Schema for the datadogdowntimes API
+type DatadogDowntime struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DatadogDowntimeSpec   `json:"spec,omitempty"`
+	Status DatadogDowntimeStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatadogDowntimeList contains a list of DatadogDowntime
+type DatadogDowntimeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatadogDowntime `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatadogDowntime{}, &DatadogDowntimeList{})
+}
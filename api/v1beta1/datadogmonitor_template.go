@@ -0,0 +1,132 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"bytes"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strings"
+	"text/template"
+)
+
+// Templates in the spec use these delimiters so they don't clash with the
+// `{{...}}` template variables of Datadog notifications
+const (
+	TemplateLeftDelim  = "[["
+	TemplateRightDelim = "]]"
+)
+
+// TemplateData is what templates in a DatadogMonitorSpec are rendered with,
+// e.g. `[[ .Namespace ]]` or `[[ .Labels.team ]]`
+// +kubebuilder:object:generate=false
+type TemplateData struct {
+	Namespace   string
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+	// The --cluster-name of the controller
+	Cluster string
+	// The --template-var variables of the controller
+	Vars map[string]string
+}
+
+type templateField struct {
+	path  *field.Path
+	value *string
+}
+
+// templateFields returns the fields of the spec that can have templates
+func (spec *DatadogMonitorSpec) templateFields() []templateField {
+	specPath := field.NewPath("spec")
+
+	fields := []templateField{
+		{specPath.Child("name"), &spec.Name},
+		{specPath.Child("message"), &spec.Message},
+		{specPath.Child("query"), &spec.Query},
+		{specPath.Child("options", "escalation_message"), &spec.Options.EscalationMessage},
+	}
+	for i := range spec.Tags {
+		fields = append(fields, templateField{specPath.Child("tags").Index(i), &spec.Tags[i]})
+	}
+
+	return fields
+}
+
+// HasTemplates reports whether any field of the spec has a template
+func (spec DatadogMonitorSpec) HasTemplates() bool {
+	for _, f := range spec.templateFields() {
+		if strings.Contains(*f.value, TemplateLeftDelim) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Render returns the spec with the templates in its name, message, query,
+// tags and escalation message rendered. Referencing a missing label,
+// annotation or variable is an error, `index` can be used for optional ones.
+func (spec DatadogMonitorSpec) Render(data TemplateData) (DatadogMonitorSpec, error) {
+	rendered := *spec.DeepCopy()
+
+	errs := field.ErrorList{}
+	for _, f := range rendered.templateFields() {
+		if !strings.Contains(*f.value, TemplateLeftDelim) {
+			continue
+		}
+
+		tmpl, err := parseTemplate(f.path.String(), *f.value)
+		if err != nil {
+			errs = append(errs, field.Invalid(f.path, *f.value, err.Error()))
+			continue
+		}
+
+		out := bytes.Buffer{}
+		if err := tmpl.Execute(&out, data); err != nil {
+			errs = append(errs, field.Invalid(f.path, *f.value, err.Error()))
+			continue
+		}
+		*f.value = out.String()
+	}
+
+	if len(errs) > 0 {
+		return spec, errs.ToAggregate()
+	}
+
+	return rendered, nil
+}
+
+// validateTemplates checks the templates in the spec can be parsed
+func (spec DatadogMonitorSpec) validateTemplates() field.ErrorList {
+	errs := field.ErrorList{}
+
+	for _, f := range spec.templateFields() {
+		if !strings.Contains(*f.value, TemplateLeftDelim) {
+			continue
+		}
+
+		if _, err := parseTemplate(f.path.String(), *f.value); err != nil {
+			errs = append(errs, field.Invalid(f.path, *f.value, err.Error()))
+		}
+	}
+
+	return errs
+}
+
+func parseTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Delims(TemplateLeftDelim, TemplateRightDelim).Option("missingkey=error").Parse(text)
+}
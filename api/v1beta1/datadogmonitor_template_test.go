@@ -0,0 +1,48 @@
+package v1beta1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	data := TemplateData{
+		Namespace:   "my-team",
+		Name:        "error-rate",
+		Labels:      map[string]string{"app": "web"},
+		Annotations: map[string]string{"team/slack": "@slack-my-team"},
+		Cluster:     "production",
+		Vars:        map[string]string{"env": "prod"},
+	}
+
+	spec := validMonitorSpec()
+	assert.False(t, spec.HasTemplates())
+	rendered, err := spec.Render(data)
+	assert.Nil(t, err)
+	assert.Equal(t, spec, rendered)
+
+	spec.Name = "[[ .Labels.app ]] in [[ .Cluster ]]"
+	spec.Query = "avg(last_5m):sum:trace.errors{service:[[ .Labels.app ]],env:[[ .Vars.env ]]} > 100"
+	spec.Message = `{{#is_alert}}[[ .Namespace ]]/[[ .Name ]] [[ index .Annotations "team/slack" ]][[ index .Annotations "missing" ]]{{/is_alert}}`
+	spec.Tags = []string{"service:[[ .Labels.app ]]", "team:a"}
+	assert.True(t, spec.HasTemplates())
+	assert.Empty(t, spec.Validate())
+
+	rendered, err = spec.Render(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "web in production", rendered.Name)
+	assert.Equal(t, "avg(last_5m):sum:trace.errors{service:web,env:prod} > 100", rendered.Query)
+	assert.Equal(t, "{{#is_alert}}my-team/error-rate @slack-my-team{{/is_alert}}", rendered.Message)
+	assert.Equal(t, []string{"service:web", "team:a"}, rendered.Tags)
+	assert.Equal(t, "service:[[ .Labels.app ]]", spec.Tags[0])
+
+	data.Labels = nil
+	_, err = spec.Render(data)
+	assert.Contains(t, err.Error(), "spec.name")
+	assert.Contains(t, err.Error(), "spec.tags[0]")
+
+	spec.Message = "[[ .Labels.app"
+	errs := spec.Validate()
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "spec.message", errs[0].Field)
+}
@@ -85,36 +85,89 @@ type DatadogMonitorSpec struct {
 	Options DatadogMonitorOptions `json:"options,omitempty"`
 	// Integer from 1 (high) to 5 (low) indicating alert severity.
 	Priority int64 `json:"priority,omitempty"`
-	// The monitor query.
+	// The monitor query. Like the name, message, tags and escalation message it can be a Go template with `[[` and `]]` delimiters, rendered with the `.Namespace`, `.Name`, `.Labels` and `.Annotations` of this resource, the `.Cluster` name and the `.Vars` of the controller. Other resources in the same namespace can be referenced with `${monitor:<name>}` or `${slo:<name>}`, which are replaced by their ID in Datadog, e.g. `${monitor:cpu-high} && ${monitor:memory-high}` for a composite monitor.
 	Query string `json:"query"`
 	// Tags associated to your monitor.
 	Tags []string `json:"tags,omitempty"`
 	// The Type of monitor it is. Must be one of: "composite", "event alert", "log alert", "metric alert", "process alert", "query alert", "rum alert", "service check", "synthetics alert", "trace-analytics alert", "slo alert"
 	Type string `json:"type,omitempty"`
+	// What to do when the monitor in Datadog no longer matches this spec, e.g. after an edit in the Datadog UI. Must be one of: "Correct" (re-apply the spec, the default) or "Report" (only report the drift). Not sent to Datadog.
+	// +kubebuilder:validation:Enum=Correct;Report
+	DriftPolicy string `json:"drift_policy,omitempty"`
+	// What to do with the monitor in Datadog when this resource is deleted. Must be one of: "Delete" (delete the monitor) or "Orphan" (leave it in Datadog). Defaults to the `--deletion-policy` of the controller. Not sent to Datadog.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	DeletionPolicy string `json:"deletion_policy,omitempty"`
+	// The name of a DatadogCredentials in the same namespace with the keys of the Datadog organization to use. Defaults to the organization the controller is configured with. Not sent to Datadog.
+	CredentialsRef string `json:"credentials_ref,omitempty"`
 }
 
-// DatadogMonitorStatus defines the observed state of DatadogMonitor
-// Why don't these printcolumns work?
-// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`,description="The status of the monitor"
-// +kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`,description="The monitor ID in Datadog"
-// +kubebuilder:printcolumn:name="Url",type=string,JSONPath=`.status.url`,description="The monitor URL in Datadog"
+const (
+	DriftPolicyCorrect = "Correct"
+	DriftPolicyReport  = "Report"
+
+	DeletionPolicyDelete = "Delete"
+	DeletionPolicyOrphan = "Orphan"
+
+	// Set to the ID of an existing monitor in Datadog to manage it with this resource instead of creating a new one
+	AdoptMonitorIdAnnotation = "datadoghq.com/adopt-monitor-id"
+	// Set to a duration, e.g. "30m", to resync this monitor with Datadog at another interval than the controller's --resync-period. "0" disables resyncing it.
+	ResyncPeriodAnnotation = "datadoghq.com/resync-period"
+
+	// Set on a Deployment, StatefulSet or Service to a comma separated list of monitor presets, e.g. "restarts,error-rate", to generate a DatadogMonitor for each
+	MonitorPresetsAnnotation = "datadoghq.com/monitors"
+	// Prefix of the annotations overriding the thresholds of a preset, e.g. "datadoghq.com/monitor.restarts.critical" and "datadoghq.com/monitor.restarts.warning"
+	MonitorPresetAnnotationPrefix = "datadoghq.com/monitor."
+	// Set on a Deployment, StatefulSet or Service to the handles to notify from the generated monitors, e.g. "@slack-my-team"
+	MonitorNotifyAnnotation = "datadoghq.com/monitor-notify"
+	// The label on the DatadogMonitors generated from the annotations of a workload, set to the name of the preset
+	MonitorPresetLabel = "datadoghq.com/monitor-preset"
+)
 
+type DatadogMonitorGroupState struct {
+	// The group, e.g. "host:host0"
+	Name string `json:"name"`
+	// The state of the group. One of: "Alert", "Warn", "No Data", "Skipped", "Ignored" or "Unknown"
+	State string `json:"state"`
+	// When the group last triggered
+	LastTriggeredTime *metav1.Time `json:"last_triggered_time,omitempty"`
+}
+
+// DatadogMonitorStatus defines the observed state of DatadogMonitor
 type DatadogMonitorStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	// True if BaseRole and Database present in the host
 
-	// Is the monitor created in Datadog
-	Status string `json:"status,omitempty"`
 	// The monitor ID in Datadog
 	Id int64 `json:"id,omitempty"`
 	// The monitor URL in Datadog
 	Url string `json:"url,omitempty"`
-	// The last applied generation. Used to distinguish
+	// The generation of the spec that was last applied to Datadog
 	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+	// The query that was last applied to Datadog with templates rendered and references replaced by IDs. Only set when it differs from the query in the spec.
+	ResolvedQuery string `json:"resolved_query,omitempty"`
+	// A hash of the spec rendered from templates that was last applied to Datadog. Only set when the spec has templates.
+	TemplateHash string `json:"template_hash,omitempty"`
+	// Fields of the monitor in Datadog that differ from the spec
+	DriftedFields []string `json:"drifted_fields,omitempty"`
+	// The state of the monitor in Datadog. One of: "OK", "Alert", "Warn", "No Data", "Skipped", "Ignored" or "Unknown"
+	OverallState string `json:"overall_state,omitempty"`
+	// The state of each group of the monitor that is not OK
+	GroupStates []DatadogMonitorGroupState `json:"group_states,omitempty"`
+	// When the monitor last triggered
+	LastTriggeredTime *metav1.Time `json:"last_triggered_time,omitempty"`
+	// What would be done in Datadog to apply the spec. Only set in dry-run mode.
+	Plan *Plan `json:"plan,omitempty"`
+	// Current state of the monitor. The Ready, Synced, Drifted and Degraded conditions are set.
+	Conditions []Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,description="Whether the monitor exists in Datadog and is in sync"
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`,description="Reason for the last change of the Ready condition"
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.overall_state`,description="The state of the monitor in Datadog"
+// +kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`,description="The monitor ID in Datadog"
+// +kubebuilder:printcolumn:name="Url",type=string,JSONPath=`.status.url`,description="The monitor URL in Datadog"
 
 // DatadogMonitor is the Schema for the datadogmonitors API
 type DatadogMonitor struct {
@@ -0,0 +1,164 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"regexp"
+	"strconv"
+)
+
+// The monitor types accepted by Datadog
+var MonitorTypes = []string{
+	"composite",
+	"event alert",
+	"log alert",
+	"metric alert",
+	"process alert",
+	"query alert",
+	"rum alert",
+	"service check",
+	"synthetics alert",
+	"trace-analytics alert",
+	"slo alert",
+}
+
+// Matches the comparison at the end of a metric query, e.g. `> 0.05`
+var queryThresholdPattern = regexp.MustCompile(`(>=|<=|>|<)\s*(-?[0-9.]+)\s*$`)
+
+// Validate checks the spec for mistakes that Datadog would reject. Thresholds
+// of 0 are treated as unset as they are left out when sent to Datadog.
+func (spec DatadogMonitorSpec) Validate() field.ErrorList {
+	errs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	if spec.Name == "" {
+		errs = append(errs, field.Required(specPath.Child("name"), ""))
+	}
+
+	if spec.Query == "" {
+		errs = append(errs, field.Required(specPath.Child("query"), ""))
+	}
+
+	if spec.Type != "" && !isMonitorType(spec.Type) {
+		errs = append(errs, field.NotSupported(specPath.Child("type"), spec.Type, MonitorTypes))
+	}
+
+	if spec.Priority != 0 && (spec.Priority < 1 || spec.Priority > 5) {
+		errs = append(errs, field.Invalid(specPath.Child("priority"), spec.Priority, "must be from 1 (high) to 5 (low)"))
+	}
+
+	errs = append(errs, spec.validateOptions(specPath.Child("options"))...)
+	errs = append(errs, spec.validateTemplates()...)
+
+	return errs
+}
+
+func (spec DatadogMonitorSpec) validateOptions(optionsPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	options := spec.Options
+
+	nonNegative := []struct {
+		name  string
+		value int64
+	}{
+		{"evaluation_delay", options.EvaluationDelay},
+		{"new_host_delay", options.NewHostDelay},
+		{"no_data_timeframe", options.NoDataTimeframe},
+		{"renotify_interval", options.RenotifyInterval},
+		{"timeout_h", options.TimeoutH},
+		{"min_location_failed", options.MinLocationFailed},
+	}
+	for _, option := range nonNegative {
+		if option.value < 0 {
+			errs = append(errs, field.Invalid(optionsPath.Child(option.name), option.value, "must not be negative"))
+		}
+	}
+
+	if options.MinFailureDuration < 0 || options.MinFailureDuration > 7200 {
+		errs = append(errs, field.Invalid(optionsPath.Child("min_failure_duration"), options.MinFailureDuration, "must be from 0 to 7200 seconds"))
+	}
+
+	if spec.Type != "synthetics alert" {
+		if options.MinFailureDuration != 0 {
+			errs = append(errs, field.Forbidden(optionsPath.Child("min_failure_duration"), "only supported by synthetics alert monitors"))
+		}
+		if options.MinLocationFailed != 0 {
+			errs = append(errs, field.Forbidden(optionsPath.Child("min_location_failed"), "only supported by synthetics alert monitors"))
+		}
+	}
+
+	thresholdsPath := optionsPath.Child("thresholds")
+	thresholds := options.Thresholds
+
+	if spec.Type == "composite" {
+		if thresholds != (DatadogMonitorThresholds{}) {
+			errs = append(errs, field.Forbidden(thresholdsPath, "not supported by composite monitors"))
+		}
+		return errs
+	}
+
+	if spec.Type != "metric alert" && spec.Type != "query alert" {
+		return errs
+	}
+
+	match := queryThresholdPattern.FindStringSubmatch(spec.Query)
+	if match == nil {
+		return errs
+	}
+
+	if queryThreshold, err := strconv.ParseFloat(match[2], 64); err == nil && thresholds.Critical != 0 && thresholds.Critical != queryThreshold {
+		errs = append(errs, field.Invalid(thresholdsPath.Child("critical"), thresholds.Critical, "must match the threshold in the query"))
+	}
+
+	// For `<` and `<=` comparisons the monitor alerts when the value is
+	// below the thresholds, so the ordering is reversed
+	above := match[1] == ">" || match[1] == ">="
+	errs = append(errs, validateThresholdOrder(thresholdsPath, "warning", thresholds.Warning, "critical", thresholds.Critical, above)...)
+	errs = append(errs, validateThresholdOrder(thresholdsPath, "critical_recovery", thresholds.CriticalRecovery, "critical", thresholds.Critical, above)...)
+	errs = append(errs, validateThresholdOrder(thresholdsPath, "warning_recovery", thresholds.WarningRecovery, "warning", thresholds.Warning, above)...)
+
+	return errs
+}
+
+// validateThresholdOrder checks the lower threshold is below the higher
+// threshold for monitors alerting above them, or above it otherwise
+func validateThresholdOrder(thresholdsPath *field.Path, lowerName string, lower float64, higherName string, higher float64, above bool) field.ErrorList {
+	if lower == 0 || higher == 0 {
+		return nil
+	}
+
+	if above && lower >= higher {
+		return field.ErrorList{field.Invalid(thresholdsPath.Child(lowerName), lower, "must be lower than "+higherName)}
+	}
+
+	if !above && lower <= higher {
+		return field.ErrorList{field.Invalid(thresholdsPath.Child(lowerName), lower, "must be higher than "+higherName)}
+	}
+
+	return nil
+}
+
+func isMonitorType(t string) bool {
+	for _, monitorType := range MonitorTypes {
+		if t == monitorType {
+			return true
+		}
+	}
+
+	return false
+}
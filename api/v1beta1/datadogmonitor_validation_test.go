@@ -0,0 +1,60 @@
+package v1beta1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func validMonitorSpec() DatadogMonitorSpec {
+	spec := DatadogMonitorSpec{
+		Name:     "test-monitor",
+		Type:     "metric alert",
+		Query:    "avg(last_5m):sum:system.net.bytes_rcvd{host:host0} > 100",
+		Message:  "test-message",
+		Priority: 2,
+	}
+	spec.Options.Thresholds.Critical = 100
+	spec.Options.Thresholds.Warning = 80
+	spec.Options.Thresholds.CriticalRecovery = 90
+
+	return spec
+}
+
+func TestValidate(t *testing.T) {
+	assert.Empty(t, validMonitorSpec().Validate())
+
+	spec := validMonitorSpec()
+	spec.Type = ""
+	assert.Empty(t, spec.Validate())
+
+	spec = validMonitorSpec()
+	spec.Type = "metric"
+	spec.Priority = 6
+	errs := spec.Validate()
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "spec.type", errs[0].Field)
+	assert.Equal(t, "spec.priority", errs[1].Field)
+
+	spec = validMonitorSpec()
+	spec.Options.Thresholds.Critical = 50
+	assert.Equal(t, "spec.options.thresholds.critical", spec.Validate()[0].Field)
+
+	spec = validMonitorSpec()
+	spec.Options.Thresholds.Warning = 120
+	assert.Equal(t, "spec.options.thresholds.warning", spec.Validate()[0].Field)
+
+	spec = validMonitorSpec()
+	spec.Query = "avg(last_5m):sum:system.net.bytes_rcvd{host:host0} < 100"
+	spec.Options.Thresholds.Warning = 120
+	spec.Options.Thresholds.CriticalRecovery = 110
+	assert.Empty(t, spec.Validate())
+
+	spec = validMonitorSpec()
+	spec.Options.MinFailureDuration = 300
+	assert.Equal(t, "spec.options.min_failure_duration", spec.Validate()[0].Field)
+
+	spec = validMonitorSpec()
+	spec.Type = "composite"
+	spec.Query = "12345 && 67890"
+	assert.Equal(t, "spec.options.thresholds", spec.Validate()[0].Field)
+}
@@ -0,0 +1,83 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	MonitorTemplateTargetNamespace  = "Namespace"
+	MonitorTemplateTargetDeployment = "Deployment"
+	MonitorTemplateTargetService    = "Service"
+
+	// The label on the DatadogMonitors generated from a DatadogMonitorTemplate, set to the name of the template
+	MonitorTemplateLabel = "datadoghq.com/monitor-template"
+)
+
+// DatadogMonitorTemplateSpec defines the desired state of DatadogMonitorTemplate
+type DatadogMonitorTemplateSpec struct {
+	// What a DatadogMonitor is generated for. Must be one of: "Namespace" (one per matching namespace, the default), "Deployment" or "Service" (one per matching Deployment or Service in the matching namespaces).
+	// +kubebuilder:validation:Enum=Namespace;Deployment;Service
+	Target string `json:"target,omitempty"`
+	// Selects the namespaces by their labels. Defaults to all namespaces.
+	NamespaceSelector *metav1.LabelSelector `json:"namespace_selector,omitempty"`
+	// Selects the Deployments or Services by their labels. Defaults to all of them. Only used with the "Deployment" and "Service" targets.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// The spec of the generated DatadogMonitors. Templates with `[[` and `]]` delimiters are rendered with the `.Namespace`, `.Name`, `.Labels` and `.Annotations` of the namespace, Deployment or Service each monitor is generated for, the `.Cluster` name and the `.Vars` of the controller.
+	Monitor DatadogMonitorSpec `json:"monitor"`
+}
+
+// DatadogMonitorTemplateStatus defines the observed state of DatadogMonitorTemplate
+type DatadogMonitorTemplateStatus struct {
+	// The generation of the spec that was last applied to the generated DatadogMonitors
+	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+	// The number of DatadogMonitors generated from the template
+	Monitors int32 `json:"monitors,omitempty"`
+	// Current state of the template. The Ready and Degraded conditions are set.
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.target`,description="What a DatadogMonitor is generated for"
+// +kubebuilder:printcolumn:name="Monitors",type=integer,JSONPath=`.status.monitors`,description="The number of generated DatadogMonitors"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,description="Whether all DatadogMonitors are generated"
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`,description="Reason for the last change of the Ready condition"
+
+// DatadogMonitorTemplate is the Schema for the datadogmonitortemplates API. It generates a DatadogMonitor for each matching namespace, Deployment or Service.
+type DatadogMonitorTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DatadogMonitorTemplateSpec   `json:"spec,omitempty"`
+	Status DatadogMonitorTemplateStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatadogMonitorTemplateList contains a list of DatadogMonitorTemplate
+type DatadogMonitorTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatadogMonitorTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatadogMonitorTemplate{}, &DatadogMonitorTemplateList{})
+}
@@ -0,0 +1,113 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type DatadogSLOQuery struct {
+	// A query for the sum of all the good events, e.g. `sum:trace.servlet.request.hits{service:my-service} - sum:trace.servlet.request.errors{service:my-service}`
+	Numerator string `json:"numerator"`
+	// A query for the sum of all the events, e.g. `sum:trace.servlet.request.hits{service:my-service}`
+	Denominator string `json:"denominator"`
+}
+
+type DatadogSLOThreshold struct {
+	// The timeframe of the target. Must be one of: "7d", "30d" or "90d"
+	// +kubebuilder:validation:Enum="7d";"30d";"90d"
+	Timeframe string `json:"timeframe"`
+	// The target as a percentage, e.g. 99.9
+	// +kubebuilder:validation:Type=number
+	Target float64 `json:"target"`
+	// The warning threshold as a percentage, higher than the target
+	// +kubebuilder:validation:Type=number
+	Warning float64 `json:"warning,omitempty"`
+}
+
+// DatadogSLOSpec defines the desired state of DatadogSLO
+type DatadogSLOSpec struct {
+	// The name of the SLO.
+	Name string `json:"name"`
+	// A description of the SLO.
+	Description string `json:"description,omitempty"`
+	// The type of SLO. Must be one of: "metric" or "monitor"
+	// +kubebuilder:validation:Enum=metric;monitor
+	Type string `json:"type"`
+	// The good and total events of a metric based SLO.
+	Query *DatadogSLOQuery `json:"query,omitempty"`
+	// The IDs of the monitors of a monitor based SLO.
+	MonitorIds []int64 `json:"monitor_ids,omitempty"`
+	// The names of DatadogMonitors in the same namespace for a monitor based SLO. Their IDs are read from the status of each DatadogMonitor once it's created and added to `monitor_ids`.
+	MonitorRefs []string `json:"monitor_refs,omitempty"`
+	// The groups to include from a monitor based SLO with a single multi alert monitor.
+	Groups []string `json:"groups,omitempty"`
+	// Tags associated to the SLO.
+	Tags []string `json:"tags,omitempty"`
+	// The targets of the SLO for each timeframe.
+	// +kubebuilder:validation:MinItems=1
+	Thresholds []DatadogSLOThreshold `json:"thresholds"`
+	// The name of a DatadogCredentials in the same namespace with the keys of the Datadog organization to use. Defaults to the organization the controller is configured with.
+	CredentialsRef string `json:"credentials_ref,omitempty"`
+}
+
+// DatadogSLOStatus defines the observed state of DatadogSLO
+type DatadogSLOStatus struct {
+	// The SLO ID in Datadog
+	Id string `json:"id,omitempty"`
+	// The SLO URL in Datadog
+	Url string `json:"url,omitempty"`
+	// The IDs of the monitors of a monitor based SLO, including those resolved from `monitor_refs`
+	MonitorIds []int64 `json:"monitor_ids,omitempty"`
+	// The generation of the spec that was last applied to Datadog
+	ObservedGeneration int64 `json:"observed_generation,omitempty"`
+	// What would be done in Datadog to apply the spec. Only set in dry-run mode.
+	Plan *Plan `json:"plan,omitempty"`
+	// Current state of the SLO. The Ready, Synced and Degraded conditions are set.
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=datadogslos
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,description="Whether the SLO exists in Datadog and is in sync"
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`,description="Reason for the last change of the Ready condition"
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`,description="The type of SLO"
+// +kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`,description="The SLO ID in Datadog"
+// +kubebuilder:printcolumn:name="Url",type=string,JSONPath=`.status.url`,description="The SLO URL in Datadog"
+
+// DatadogSLO is the Schema for the datadogslos API
+type DatadogSLO struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DatadogSLOSpec   `json:"spec,omitempty"`
+	Status DatadogSLOStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatadogSLOList contains a list of DatadogSLO
+type DatadogSLOList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatadogSLO `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatadogSLO{}, &DatadogSLOList{})
+}
@@ -0,0 +1,37 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+const (
+	// Set to "true" to only plan changes to the resource in Datadog without making them
+	DryRunAnnotation = "datadoghq.com/dry-run"
+
+	PlanActionCreate = "Create"
+	PlanActionUpdate = "Update"
+	PlanActionDelete = "Delete"
+	PlanActionAdopt  = "Adopt"
+	PlanActionNone   = "None"
+)
+
+// Plan is what the controller would do in Datadog to apply the spec of a
+// resource in dry-run mode
+type Plan struct {
+	// What would be done in Datadog. One of: "Create", "Update", "Delete", "Adopt" or "None"
+	Action string `json:"action"`
+	// The fields that would change in Datadog, if known
+	Changes []string `json:"changes,omitempty"`
+}
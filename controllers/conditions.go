@@ -0,0 +1,61 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setCondition is a shorthand for setting a condition with a boolean status.
+// It reports whether the condition changed.
+func setCondition(conditions *[]datadoghqcomv1beta1.Condition, conditionType string, status bool, reason string, message string) bool {
+	condition := datadoghqcomv1beta1.Condition{
+		Type:    conditionType,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}
+
+	if status {
+		condition.Status = metav1.ConditionTrue
+	}
+
+	return datadoghqcomv1beta1.SetCondition(conditions, condition)
+}
+
+// setSynced sets the Ready, Synced and Degraded conditions after applying a
+// spec to Datadog. A nil err means the resource in Datadog matches the spec.
+func setSynced(conditions *[]datadoghqcomv1beta1.Condition, reason string, err error) bool {
+	if err != nil {
+		changed := setCondition(conditions, datadoghqcomv1beta1.ConditionSynced, false, reason, err.Error())
+		changed = setCondition(conditions, datadoghqcomv1beta1.ConditionReady, false, reason, err.Error()) || changed
+		changed = setCondition(conditions, datadoghqcomv1beta1.ConditionDegraded, true, reason, err.Error()) || changed
+		return changed
+	}
+
+	changed := setCondition(conditions, datadoghqcomv1beta1.ConditionSynced, true, reason, "")
+	changed = setCondition(conditions, datadoghqcomv1beta1.ConditionReady, true, reason, "") || changed
+	changed = setCondition(conditions, datadoghqcomv1beta1.ConditionDegraded, false, reason, "") || changed
+	return changed
+}
+
+// setDegraded records an error that doesn't change whether the resource in
+// Datadog matches its spec, e.g. a failed read
+func setDegraded(conditions *[]datadoghqcomv1beta1.Condition, reason string, err error) bool {
+	return setCondition(conditions, datadoghqcomv1beta1.ConditionDegraded, true, reason, err.Error())
+}
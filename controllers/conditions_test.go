@@ -0,0 +1,79 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+// assertCondition checks the status and reason of a condition
+func assertCondition(t *testing.T, conditions []datadoghqcomv1beta1.Condition, conditionType string, status metav1.ConditionStatus, reason string) {
+	t.Helper()
+
+	condition := datadoghqcomv1beta1.FindCondition(conditions, conditionType)
+	if assert.NotNil(t, condition, conditionType) {
+		assert.Equal(t, status, condition.Status, conditionType)
+		assert.Equal(t, reason, condition.Reason, conditionType)
+	}
+}
+
+func TestSetSynced(t *testing.T) {
+	conditions := []datadoghqcomv1beta1.Condition{}
+	past := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	assert.True(t, setSynced(&conditions, "Created", nil))
+	assertCondition(t, conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionTrue, "Created")
+	assertCondition(t, conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionTrue, "Created")
+	assertCondition(t, conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionFalse, "Created")
+
+	for i := range conditions {
+		conditions[i].LastTransitionTime = past
+	}
+
+	assert.False(t, setSynced(&conditions, "Created", nil))
+	assert.True(t, setSynced(&conditions, "InSync", nil))
+	for _, condition := range conditions {
+		assert.Equal(t, past, condition.LastTransitionTime, condition.Type)
+	}
+
+	assert.True(t, setSynced(&conditions, "FailedUpdate", errors.New("invalid query")))
+	assertCondition(t, conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionFalse, "FailedUpdate")
+	assertCondition(t, conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "FailedUpdate")
+	assertCondition(t, conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionTrue, "FailedUpdate")
+	assert.Equal(t, "invalid query", datadoghqcomv1beta1.FindCondition(conditions, datadoghqcomv1beta1.ConditionDegraded).Message)
+	for _, condition := range conditions {
+		assert.True(t, condition.LastTransitionTime.After(past.Time), condition.Type)
+	}
+}
+
+func TestSetDegraded(t *testing.T) {
+	conditions := []datadoghqcomv1beta1.Condition{}
+	setSynced(&conditions, "Created", nil)
+
+	assert.True(t, setDegraded(&conditions, "FailedResync", errors.New("timeout")))
+	assert.False(t, setDegraded(&conditions, "FailedResync", errors.New("timeout")))
+
+	// A failed read doesn't change whether the monitor matches its spec
+	assertCondition(t, conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionTrue, "Created")
+	assertCondition(t, conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionTrue, "Created")
+	assertCondition(t, conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionTrue, "FailedResync")
+}
@@ -0,0 +1,54 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+)
+
+// stopContext gives reconcilers a context that is cancelled when the manager
+// stops, so requests to Datadog in flight are abandoned on shutdown instead
+// of holding it up until they time out. The manager sets the stop channel
+// of reconcilers it runs with InjectStopChannel.
+type stopContext struct {
+	stop <-chan struct{}
+}
+
+// InjectStopChannel is called by the manager with its stop channel
+func (s *stopContext) InjectStopChannel(stop <-chan struct{}) error {
+	s.stop = stop
+	return nil
+}
+
+// newContext returns a context for a reconcile. It's cancelled when the
+// manager stops or cancel is called.
+func (s *stopContext) newContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if s.stop == nil {
+		return ctx, cancel
+	}
+
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
@@ -0,0 +1,409 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/max-rocket-internet/datadog-controller/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sync"
+	"time"
+)
+
+const (
+	failedCredentialsDelay    = 10 * time.Second
+	failedCredentialsMaxDelay = 5 * time.Minute
+)
+
+var (
+	credentialsReloadCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "datadog_controller",
+		Subsystem: "credentials",
+		Name:      "reload",
+		Help:      "Count of Datadog keys replaced after a Secret changed, by whether the new keys were valid",
+	}, []string{
+		"result",
+	})
+)
+
+// DatadogClients returns the Datadog client for the organization a resource
+// belongs to. Clients for organizations set with a DatadogCredentials are
+// created on first use and cached per DatadogCredentials. When the keys in
+// its Secrets change the CredentialsSecretReconciler swaps them in the cached
+// client.
+type DatadogClients struct {
+	// The client of the organization the controller is configured with
+	Default  datadog.Datadog
+	LogLevel string
+	// Options of the clients created for DatadogCredentials
+	Options []datadog.Option
+	// Reads the Secrets of DatadogCredentials, e.g. the manager's API reader
+	// so not all Secrets are cached. Defaults to the client passed to For.
+	Secrets client.Reader
+
+	mu       sync.Mutex
+	clients  map[types.NamespacedName]cachedClient
+	calls    map[clientKey]*clientCall
+	failures map[clientKey]failedCredentials
+
+	secretsMu    sync.Mutex
+	secretValues map[types.NamespacedName]cachedSecret
+}
+
+// MonitorClients picks the monitor API for the credentials_ref of a DatadogMonitor
+type MonitorClients interface {
+	MonitorsFor(ctx context.Context, k8sClient client.Client, namespace string, credentialsRef string) (datadog.MonitorAPI, error)
+}
+
+// DowntimeClients is what DatadogDowntimeReconciler needs from DatadogClients
+type DowntimeClients interface {
+	DowntimesFor(ctx context.Context, k8sClient client.Client, namespace string, credentialsRef string) (datadog.DowntimeAPI, error)
+}
+
+// SLOClients resolves the organization of a DatadogSLO to its SLO API
+type SLOClients interface {
+	SLOsFor(ctx context.Context, k8sClient client.Client, namespace string, credentialsRef string) (datadog.SLOAPI, error)
+}
+
+// resultForClientError decides how a reconcile goes on when the Datadog client
+// of a resource can't be had. It reports done with the result to return if
+// the reconcile stops: the resource gets the FailedCredentials reason and is
+// retried. A resource being deleted that was never created in Datadog doesn't
+// need a client, so its reconcile goes on to remove the finalizer.
+func resultForClientError(log logr.Logger, err error, object metav1.Object, created bool, conditions *[]datadoghqcomv1beta1.Condition, updateStatus func() error) (ctrl.Result, bool, error) {
+	if err == nil || (!object.GetDeletionTimestamp().IsZero() && !created) {
+		return ctrl.Result{}, false, nil
+	}
+
+	log.Error(err, "Failed to get Datadog client")
+
+	if setSynced(conditions, "FailedCredentials", err) {
+		if err := updateStatus(); err != nil {
+			return ctrl.Result{}, true, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: dependencyRequeueDelay}, true, nil
+}
+
+type cachedClient struct {
+	creds  datadog.Credentials
+	client datadog.Datadog
+}
+
+// clientKey identifies the keys of a DatadogCredentials
+type clientKey struct {
+	name  types.NamespacedName
+	creds datadog.Credentials
+}
+
+// clientCall is a check of keys in progress that other callers wait for
+type clientCall struct {
+	done   chan struct{}
+	client datadog.Datadog
+	err    error
+}
+
+// cachedSecret is the data of a Secret read for a DatadogCredentials
+type cachedSecret struct {
+	data map[string][]byte
+}
+
+type failedCredentials struct {
+	err     error
+	delay   time.Duration
+	retryAt time.Time
+}
+
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogcredentials,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// For returns the client for the DatadogCredentials with the given name in
+// the namespace, or the default client if the name is empty
+func (c *DatadogClients) For(ctx context.Context, k8sClient client.Client, namespace string, credentialsRef string) (datadog.Datadog, error) {
+	if credentialsRef == "" {
+		return c.Default, nil
+	}
+
+	creds, err := c.credentialsFromRef(ctx, k8sClient, namespace, credentialsRef)
+	if err != nil {
+		return datadog.Datadog{}, err
+	}
+
+	name := types.NamespacedName{Namespace: namespace, Name: credentialsRef}
+	key := clientKey{name: name, creds: creds}
+
+	c.mu.Lock()
+
+	cached, ok := c.clients[name]
+	if ok && cached.creds == creds {
+		c.mu.Unlock()
+		return cached.client, nil
+	}
+
+	if failed, ok := c.failures[key]; ok && time.Now().Before(failed.retryAt) {
+		c.mu.Unlock()
+		return datadog.Datadog{}, failed.err
+	}
+
+	// Keys are checked with Datadog without holding the lock, and only once
+	// when several resources using them are reconciled at the same time
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.client, call.err
+		case <-ctx.Done():
+			return datadog.Datadog{}, ctx.Err()
+		}
+	}
+
+	call := &clientCall{done: make(chan struct{})}
+	if c.calls == nil {
+		c.calls = map[clientKey]*clientCall{}
+	}
+	c.calls[key] = call
+
+	c.mu.Unlock()
+
+	call.client, call.err = c.newClient(ctx, name, cached, ok, creds)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if call.err != nil {
+		c.recordFailure(key, call.err)
+	} else {
+		for failedKey := range c.failures {
+			if failedKey.name == name {
+				delete(c.failures, failedKey)
+			}
+		}
+		if c.clients == nil {
+			c.clients = map[types.NamespacedName]cachedClient{}
+		}
+		c.clients[name] = cachedClient{creds: creds, client: call.client}
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.client, call.err
+}
+
+// newClient swaps the keys in the cached client of a DatadogCredentials, or
+// creates a client if there's none yet or the site changed. Both check the
+// keys with Datadog.
+func (c *DatadogClients) newClient(ctx context.Context, name types.NamespacedName, cached cachedClient, ok bool, creds datadog.Credentials) (datadog.Datadog, error) {
+	if ok && cached.creds.Host == creds.Host {
+		if err := replaceCredentials(ctx, cached.client, creds); err != nil {
+			return datadog.Datadog{}, fmt.Errorf("Error replacing keys of DatadogCredentials %v: %v", name, err)
+		}
+		return cached.client, nil
+	}
+
+	dd, err := datadog.NewForCredentials(c.LogLevel, creds, c.Options...)
+	if err != nil {
+		return datadog.Datadog{}, fmt.Errorf("Error creating Datadog client for DatadogCredentials %v: %v", name, err)
+	}
+
+	return dd, nil
+}
+
+// replaceCredentials checks new keys with Datadog and swaps them in a client
+// if they're valid, counting the result in credentialsReloadCounter
+func replaceCredentials(ctx context.Context, dd datadog.Datadog, creds datadog.Credentials) error {
+	if err := dd.SetCredentials(ctx, creds); err != nil {
+		credentialsReloadCounter.WithLabelValues("invalid").Inc()
+		return err
+	}
+
+	credentialsReloadCounter.WithLabelValues("success").Inc()
+	return nil
+}
+
+// reload reads the keys of a DatadogCredentials again after one of its
+// Secrets changed and swaps them in its client. It reports whether they were
+// replaced. DatadogCredentials without a client yet are skipped, their keys
+// are read when it's created.
+func (c *DatadogClients) reload(ctx context.Context, k8sClient client.Client, namespace string, credentialsRef string) (bool, error) {
+	name := types.NamespacedName{Namespace: namespace, Name: credentialsRef}
+
+	c.mu.Lock()
+	before, ok := c.clients[name]
+	c.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	if _, err := c.For(ctx, k8sClient, namespace, credentialsRef); err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	after := c.clients[name]
+	c.mu.Unlock()
+
+	return after.creds != before.creds, nil
+}
+
+// recordFailure caches the error of keys that failed the check so they aren't
+// checked again for every resource using them. The delay doubles with every
+// failure up to failedCredentialsMaxDelay. Must be called with c.mu held.
+func (c *DatadogClients) recordFailure(key clientKey, err error) {
+	if c.failures == nil {
+		c.failures = map[clientKey]failedCredentials{}
+	}
+
+	failed := c.failures[key]
+	failed.delay *= 2
+	if failed.delay == 0 {
+		failed.delay = failedCredentialsDelay
+	}
+	if failed.delay > failedCredentialsMaxDelay {
+		failed.delay = failedCredentialsMaxDelay
+	}
+	failed.err = err
+	failed.retryAt = time.Now().Add(failed.delay)
+
+	c.failures[key] = failed
+}
+
+// MonitorsFor returns the monitor API of the client For returns
+func (c *DatadogClients) MonitorsFor(ctx context.Context, k8sClient client.Client, namespace string, credentialsRef string) (datadog.MonitorAPI, error) {
+	dd, err := c.For(ctx, k8sClient, namespace, credentialsRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return dd, nil
+}
+
+// DowntimesFor returns the downtime API of the client For returns
+func (c *DatadogClients) DowntimesFor(ctx context.Context, k8sClient client.Client, namespace string, credentialsRef string) (datadog.DowntimeAPI, error) {
+	dd, err := c.For(ctx, k8sClient, namespace, credentialsRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return dd, nil
+}
+
+// SLOsFor returns the SLO API of the client For returns
+func (c *DatadogClients) SLOsFor(ctx context.Context, k8sClient client.Client, namespace string, credentialsRef string) (datadog.SLOAPI, error) {
+	dd, err := c.For(ctx, k8sClient, namespace, credentialsRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return dd, nil
+}
+
+// credentialsFromRef reads the keys of a DatadogCredentials from its Secrets
+func (c *DatadogClients) credentialsFromRef(ctx context.Context, k8sClient client.Client, namespace string, ref string) (datadog.Credentials, error) {
+	credentials := &datadoghqcomv1beta1.DatadogCredentials{}
+	name := types.NamespacedName{Namespace: namespace, Name: ref}
+
+	if err := k8sClient.Get(ctx, name, credentials); err != nil {
+		if apierrors.IsNotFound(err) {
+			return datadog.Credentials{}, fmt.Errorf("DatadogCredentials %v not found", name)
+		}
+		return datadog.Credentials{}, err
+	}
+
+	// The keys are sent to the site, so they're never read for other sites
+	if host := credentials.Spec.Host; host != "" && !utils.ContainsString(datadoghqcomv1beta1.DatadogSites, host) {
+		return datadog.Credentials{}, fmt.Errorf("DatadogCredentials %v has an unknown Datadog site %q", name, host)
+	}
+
+	apiKey, err := c.secretValue(ctx, k8sClient, namespace, credentials.Spec.ApiKeySecretRef)
+	if err != nil {
+		return datadog.Credentials{}, err
+	}
+
+	appKey, err := c.secretValue(ctx, k8sClient, namespace, credentials.Spec.AppKeySecretRef)
+	if err != nil {
+		return datadog.Credentials{}, err
+	}
+
+	return datadog.Credentials{ApiKey: apiKey, AppKey: appKey, Host: credentials.Spec.Host}, nil
+}
+
+// secretValue returns a key of a Secret. Secrets are read once and cached
+// until the CredentialsSecretReconciler sees them change.
+func (c *DatadogClients) secretValue(ctx context.Context, k8sClient client.Client, namespace string, ref datadoghqcomv1beta1.SecretKeyRef) (string, error) {
+	name := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+
+	c.secretsMu.Lock()
+	cached, ok := c.secretValues[name]
+	c.secretsMu.Unlock()
+
+	if !ok {
+		secrets := c.Secrets
+		if secrets == nil {
+			secrets = k8sClient
+		}
+
+		secret := &corev1.Secret{}
+		if err := secrets.Get(ctx, name, secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return "", fmt.Errorf("Secret %v not found", name)
+			}
+			return "", err
+		}
+
+		cached = cachedSecret{data: secret.Data}
+		c.refreshSecret(name, secret.Data)
+	}
+
+	value, ok := cached.data[ref.Key]
+	if !ok || len(value) == 0 {
+		return "", fmt.Errorf("Secret %v has no key %q", name, ref.Key)
+	}
+
+	return string(value), nil
+}
+
+// refreshSecret replaces the cached data of a Secret after it changed
+func (c *DatadogClients) refreshSecret(name types.NamespacedName, data map[string][]byte) {
+	c.secretsMu.Lock()
+	defer c.secretsMu.Unlock()
+
+	if c.secretValues == nil {
+		c.secretValues = map[types.NamespacedName]cachedSecret{}
+	}
+	c.secretValues[name] = cachedSecret{data: data}
+}
+
+// forgetSecret drops a Secret no DatadogCredentials uses anymore from the
+// cache, as it isn't watched anymore
+func (c *DatadogClients) forgetSecret(name types.NamespacedName) {
+	c.secretsMu.Lock()
+	defer c.secretsMu.Unlock()
+
+	delete(c.secretValues, name)
+}
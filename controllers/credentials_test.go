@@ -0,0 +1,71 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+// teamCredentials returns a DatadogCredentials for a site with its keys in
+// the Secret datadog-<name>
+func teamCredentials(name string, host string) (*datadoghqcomv1beta1.DatadogCredentials, *corev1.Secret) {
+	credentials := &datadoghqcomv1beta1.DatadogCredentials{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: datadoghqcomv1beta1.DatadogCredentialsSpec{
+			Host:            host,
+			ApiKeySecretRef: datadoghqcomv1beta1.SecretKeyRef{Name: "datadog-" + name, Key: "api-key"},
+			AppKeySecretRef: datadoghqcomv1beta1.SecretKeyRef{Name: "datadog-" + name, Key: "app-key"},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "datadog-" + name},
+		Data:       map[string][]byte{"api-key": []byte(name + "-api-key"), "app-key": []byte(name + "-app-key")},
+	}
+
+	return credentials, secret
+}
+
+func TestClientsForUnknownSite(t *testing.T) {
+	assert.Nil(t, datadoghqcomv1beta1.AddToScheme(scheme.Scheme))
+
+	server := fake.NewServer()
+	defer server.Close()
+
+	known, knownSecret := teamCredentials("team-a", "datadoghq.com")
+	unknown, unknownSecret := teamCredentials("team-b", "attacker.example.com")
+	k8sClient := fakeclient.NewFakeClientWithScheme(scheme.Scheme, known, knownSecret, unknown, unknownSecret)
+
+	clients := &DatadogClients{LogLevel: "INFO", Options: []datadog.Option{datadog.WithBaseURL(server.URL)}}
+
+	_, err := clients.For(context.Background(), k8sClient, "default", "team-a")
+	assert.Nil(t, err)
+
+	_, err = clients.For(context.Background(), k8sClient, "default", "team-b")
+	assert.EqualError(t, err, `DatadogCredentials default/team-b has an unknown Datadog site "attacker.example.com"`)
+
+	// The keys of the unknown site are never read
+	assert.Len(t, clients.secretValues, 1)
+}
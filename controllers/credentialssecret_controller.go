@@ -0,0 +1,341 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sync"
+)
+
+const (
+	apiKeySecretKey = "DD_CLIENT_API_KEY"
+	appKeySecretKey = "DD_CLIENT_APP_KEY"
+)
+
+// CredentialsSecretReconciler replaces the keys of Datadog clients when the
+// Secrets holding them change, so keys can be rotated without restarting the
+// controller. It watches the Secret of the default client, if set, and the
+// Secrets used by DatadogCredentials.
+type CredentialsSecretReconciler struct {
+	client.Client
+	stopContext
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Datadog  *DatadogClients
+	// Reads the changed Secrets, e.g. the manager's API reader so not all
+	// Secrets are cached. Defaults to the client.
+	Secrets client.Reader
+	// The Secret with the DD_CLIENT_API_KEY and DD_CLIENT_APP_KEY keys of the
+	// default client, if any
+	Secret types.NamespacedName
+	// The keys the default client currently uses
+	Credentials datadog.Credentials
+
+	watches *secretWatches
+}
+
+func (r *CredentialsSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx, cancel := r.newContext()
+	defer cancel()
+
+	log := r.Log.WithValues("secret", req.NamespacedName)
+
+	credentials, err := r.credentialsUsing(ctx, req.NamespacedName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	isDefault := req.NamespacedName == r.Secret
+	if !isDefault && len(credentials) == 0 {
+		log.V(1).Info("Not watching Secret as no DatadogCredentials uses it")
+		if r.watches != nil {
+			r.watches.unwatch(req.NamespacedName)
+		}
+		r.Datadog.forgetSecret(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+	if r.watches != nil {
+		r.watches.watch(req.NamespacedName, r.stop)
+	}
+
+	secrets := r.Secrets
+	if secrets == nil {
+		secrets = r.Client
+	}
+
+	log.V(1).Info("Getting Secret")
+	secret := &corev1.Secret{}
+	if err := secrets.Get(ctx, req.NamespacedName, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("Secret with Datadog keys not found, keeping the current keys")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	r.Datadog.refreshSecret(req.NamespacedName, secret.Data)
+
+	retry := false
+	if isDefault && r.reloadDefault(ctx, log, secret) {
+		retry = true
+	}
+	for i := range credentials {
+		if r.reloadCredentials(ctx, log, &credentials[i]) {
+			retry = true
+		}
+	}
+
+	if retry {
+		return ctrl.Result{RequeueAfter: dependencyRequeueDelay}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reloadDefault replaces the keys of the default client with the keys in its
+// Secret. It reports whether the new keys are invalid and should be retried.
+func (r *CredentialsSecretReconciler) reloadDefault(ctx context.Context, log logr.Logger, secret *corev1.Secret) bool {
+	creds, err := credentialsFromSecret(secret)
+	if err != nil {
+		log.Error(err, "Failed to read Datadog keys")
+		r.Recorder.Eventf(secret, "Warning", "InvalidCredentials", fmt.Sprint(err))
+		return false
+	}
+
+	creds.Host = r.Credentials.Host
+	if creds == r.Credentials {
+		log.V(1).Info("Skipping as keys are unchanged")
+		return false
+	}
+
+	log.Info("Replacing Datadog keys")
+
+	if err := replaceCredentials(ctx, r.Datadog.Default, creds); err != nil {
+		log.Error(err, "New Datadog keys are invalid, keeping the current keys")
+		r.Recorder.Eventf(secret, "Warning", "InvalidCredentials", fmt.Sprintf("New Datadog keys are invalid, keeping the current keys: %v", err))
+		return true
+	}
+
+	r.Recorder.Eventf(secret, "Normal", "CredentialsReloaded", "Datadog keys replaced")
+	r.Credentials = creds
+
+	return false
+}
+
+// reloadCredentials replaces the keys of the client of a DatadogCredentials
+// using the Secret. It reports whether the new keys are invalid and should be
+// retried.
+func (r *CredentialsSecretReconciler) reloadCredentials(ctx context.Context, log logr.Logger, credentials *datadoghqcomv1beta1.DatadogCredentials) bool {
+	replaced, err := r.Datadog.reload(ctx, r.Client, credentials.Namespace, credentials.Name)
+	if err != nil {
+		log.Error(err, "New Datadog keys are invalid", "credentials", credentials.Name)
+		r.Recorder.Eventf(credentials, "Warning", "InvalidCredentials", fmt.Sprintf("New Datadog keys are invalid: %v", err))
+		return true
+	}
+
+	if replaced {
+		log.Info("Replaced Datadog keys", "credentials", credentials.Name)
+		r.Recorder.Eventf(credentials, "Normal", "CredentialsReloaded", "Datadog keys replaced")
+	}
+
+	return false
+}
+
+// credentialsUsing lists the DatadogCredentials with keys in a Secret
+func (r *CredentialsSecretReconciler) credentialsUsing(ctx context.Context, name types.NamespacedName) ([]datadoghqcomv1beta1.DatadogCredentials, error) {
+	list := &datadoghqcomv1beta1.DatadogCredentialsList{}
+	if err := r.List(ctx, list, client.InNamespace(name.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var credentials []datadoghqcomv1beta1.DatadogCredentials
+	for _, item := range list.Items {
+		if item.Spec.ApiKeySecretRef.Name == name.Name || item.Spec.AppKeySecretRef.Name == name.Name {
+			credentials = append(credentials, item)
+		}
+	}
+
+	return credentials, nil
+}
+
+// LoadCredentialsSecret reads the Datadog keys from a Secret, e.g. before the
+// manager's cache is started
+func LoadCredentialsSecret(ctx context.Context, reader client.Reader, name types.NamespacedName) (datadog.Credentials, error) {
+	secret := &corev1.Secret{}
+
+	if err := reader.Get(ctx, name, secret); err != nil {
+		return datadog.Credentials{}, fmt.Errorf("Error getting Secret %v: %v", name, err)
+	}
+
+	return credentialsFromSecret(secret)
+}
+
+func credentialsFromSecret(secret *corev1.Secret) (datadog.Credentials, error) {
+	creds := datadog.Credentials{
+		ApiKey: string(secret.Data[apiKeySecretKey]),
+		AppKey: string(secret.Data[appKeySecretKey]),
+	}
+
+	if creds.ApiKey == "" || creds.AppKey == "" {
+		return creds, fmt.Errorf("Secret %v/%v must have the keys %v and %v", secret.Namespace, secret.Name, apiKeySecretKey, appKeySecretKey)
+	}
+
+	return creds, nil
+}
+
+// requestsForSecrets maps a DatadogCredentials to the Secrets with its keys
+func requestsForSecrets(obj handler.MapObject) []reconcile.Request {
+	credentials, ok := obj.Object.(*datadoghqcomv1beta1.DatadogCredentials)
+	if !ok {
+		return nil
+	}
+
+	names := []string{credentials.Spec.ApiKeySecretRef.Name}
+	if name := credentials.Spec.AppKeySecretRef.Name; name != names[0] {
+		names = append(names, name)
+	}
+
+	var requests []reconcile.Request
+	for _, name := range names {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: credentials.Namespace, Name: name}})
+	}
+
+	return requests
+}
+
+// secretWatches runs an informer for each watched Secret that lists and
+// watches only that name in its namespace, so only the Secrets with Datadog
+// keys are cached and not all Secrets of the cluster. Changes are sent to
+// events.
+type secretWatches struct {
+	clientset kubernetes.Interface
+	events    chan event.GenericEvent
+
+	mu       sync.Mutex
+	watching map[types.NamespacedName]chan struct{}
+}
+
+func newSecretWatches(clientset kubernetes.Interface) *secretWatches {
+	return &secretWatches{
+		clientset: clientset,
+		events:    make(chan event.GenericEvent, 100),
+		watching:  map[types.NamespacedName]chan struct{}{},
+	}
+}
+
+// watch starts watching a Secret until unwatch is called or stop is closed
+func (w *secretWatches) watch(name types.NamespacedName, stop <-chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.watching[name]; ok {
+		return
+	}
+
+	listWatch := toolscache.NewFilteredListWatchFromClient(w.clientset.CoreV1().RESTClient(), "secrets", name.Namespace, func(options *metav1.ListOptions) {
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name.Name).String()
+	})
+	informer := toolscache.NewSharedIndexInformer(listWatch, &corev1.Secret{}, 0, toolscache.Indexers{})
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: w.send,
+		UpdateFunc: func(_, obj interface{}) {
+			w.send(obj)
+		},
+	})
+
+	unwatch := make(chan struct{})
+	w.watching[name] = unwatch
+
+	informerStop := make(chan struct{})
+	go func() {
+		select {
+		case <-stop:
+		case <-unwatch:
+		}
+		close(informerStop)
+	}()
+	go informer.Run(informerStop)
+}
+
+func (w *secretWatches) unwatch(name types.NamespacedName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if unwatch, ok := w.watching[name]; ok {
+		close(unwatch)
+		delete(w.watching, name)
+	}
+}
+
+func (w *secretWatches) send(obj interface{}) {
+	if secret, ok := obj.(*corev1.Secret); ok {
+		w.events <- event.GenericEvent{Meta: secret, Object: secret}
+	}
+}
+
+// SetupWithManager watches each Secret with an informer of its own that lists
+// and watches only that name in its namespace. Secrets are watched from when a
+// DatadogCredentials uses them, and the default Secret from the start.
+func (r *CredentialsSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	r.watches = newSecretWatches(clientset)
+
+	c, err := controller.New("credentials-secret", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	if err := c.Watch(&source.Channel{Source: r.watches.events}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &datadoghqcomv1beta1.DatadogCredentials{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(requestsForSecrets),
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.Secret.Name != "" {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: r.Secret.Namespace, Name: r.Secret.Name}}
+		r.watches.events <- event.GenericEvent{Meta: secret, Object: secret}
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"testing"
+)
+
+// rotateSecret replaces a key of a Secret and reconciles it
+func rotateSecret(t *testing.T, r *CredentialsSecretReconciler, name types.NamespacedName, key string, value string) ctrl.Result {
+	secret := &corev1.Secret{}
+	assert.Nil(t, r.Get(context.Background(), name, secret))
+	secret.Data[key] = []byte(value)
+	assert.Nil(t, r.Update(context.Background(), secret))
+
+	result, err := r.Reconcile(ctrl.Request{NamespacedName: name})
+	assert.Nil(t, err)
+
+	return result
+}
+
+func reloadCount(result string) float64 {
+	return testutil.ToFloat64(credentialsReloadCounter.WithLabelValues(result))
+}
+
+func TestCredentialsSecretRotationForDefault(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.APIKey = "api-key"
+	id := server.CreateMonitor(datadoghqcomv1beta1.DatadogMonitorSpec{Name: "test", Type: "metric alert", Query: "avg(last_5m):avg:system.load.1{*} > 1"})
+
+	name := types.NamespacedName{Namespace: "default", Name: "datadog"}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: name.Namespace, Name: name.Name},
+		Data:       map[string][]byte{apiKeySecretKey: []byte("api-key"), appKeySecretKey: []byte("app-key")},
+	}
+	current := datadog.Credentials{ApiKey: "api-key", AppKey: "app-key"}
+	dd, err := datadog.NewForCredentials("INFO", current, datadog.WithBaseURL(server.URL))
+	assert.Nil(t, err)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &CredentialsSecretReconciler{
+		Client:      fakeclient.NewFakeClientWithScheme(scheme.Scheme, secret),
+		Log:         ctrl.Log.WithName("test"),
+		Recorder:    recorder,
+		Datadog:     &DatadogClients{Default: dd},
+		Secret:      name,
+		Credentials: current,
+	}
+
+	// Valid keys replace the current ones
+	success := reloadCount("success")
+	server.APIKey = "rotated-api-key"
+	result := rotateSecret(t, r, name, apiKeySecretKey, "rotated-api-key")
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, "Normal CredentialsReloaded Datadog keys replaced", <-recorder.Events)
+	assert.Equal(t, success+1, reloadCount("success"))
+	assert.Equal(t, "rotated-api-key", r.Credentials.ApiKey)
+	_, err = dd.GetMonitor(context.Background(), id)
+	assert.Nil(t, err)
+
+	// Invalid keys are ignored and retried
+	invalid := reloadCount("invalid")
+	result = rotateSecret(t, r, name, apiKeySecretKey, "leaked-api-key")
+	assert.Equal(t, dependencyRequeueDelay, result.RequeueAfter)
+	assert.Contains(t, <-recorder.Events, "Warning InvalidCredentials New Datadog keys are invalid, keeping the current keys")
+	assert.Equal(t, invalid+1, reloadCount("invalid"))
+	assert.Equal(t, "rotated-api-key", r.Credentials.ApiKey)
+	_, err = dd.GetMonitor(context.Background(), id)
+	assert.Nil(t, err)
+}
+
+func TestCredentialsSecretRotationForCredentials(t *testing.T) {
+	assert.Nil(t, datadoghqcomv1beta1.AddToScheme(scheme.Scheme))
+
+	server := fake.NewServer()
+	defer server.Close()
+	server.APIKey = "team-a-api-key"
+	id := server.CreateMonitor(datadoghqcomv1beta1.DatadogMonitorSpec{Name: "test", Type: "metric alert", Query: "avg(last_5m):avg:system.load.1{*} > 1"})
+
+	credentials, secret := teamCredentials("team-a", "datadoghq.com")
+	name := types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}
+	k8sClient := fakeclient.NewFakeClientWithScheme(scheme.Scheme, credentials, secret)
+
+	clients := &DatadogClients{LogLevel: "INFO", Options: []datadog.Option{datadog.WithBaseURL(server.URL)}}
+	dd, err := clients.For(context.Background(), k8sClient, "default", "team-a")
+	assert.Nil(t, err)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &CredentialsSecretReconciler{
+		Client:   k8sClient,
+		Log:      ctrl.Log.WithName("test"),
+		Recorder: recorder,
+		Datadog:  clients,
+	}
+
+	// Valid keys are swapped in the cached client right away
+	success := reloadCount("success")
+	server.APIKey = "rotated-api-key"
+	result := rotateSecret(t, r, name, "api-key", "rotated-api-key")
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, "Normal CredentialsReloaded Datadog keys replaced", <-recorder.Events)
+	assert.Equal(t, success+1, reloadCount("success"))
+	_, err = dd.GetMonitor(context.Background(), id)
+	assert.Nil(t, err)
+
+	// Invalid keys are reported on the DatadogCredentials and retried, and
+	// resources using them fail with the error until the keys are fixed
+	invalid := reloadCount("invalid")
+	result = rotateSecret(t, r, name, "api-key", "leaked-api-key")
+	assert.Equal(t, dependencyRequeueDelay, result.RequeueAfter)
+	assert.Contains(t, <-recorder.Events, "Warning InvalidCredentials New Datadog keys are invalid")
+	assert.Equal(t, invalid+1, reloadCount("invalid"))
+	_, err = dd.GetMonitor(context.Background(), id)
+	assert.Nil(t, err)
+	_, err = clients.For(context.Background(), k8sClient, "default", "team-a")
+	assert.Error(t, err)
+}
+
+func TestCredentialsSecretNotUsed(t *testing.T) {
+	assert.Nil(t, datadoghqcomv1beta1.AddToScheme(scheme.Scheme))
+
+	credentials, secret := teamCredentials("team-a", "datadoghq.com")
+	name := types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}
+	k8sClient := fakeclient.NewFakeClientWithScheme(scheme.Scheme, credentials, secret)
+
+	clients := &DatadogClients{}
+	clients.refreshSecret(name, secret.Data)
+
+	r := &CredentialsSecretReconciler{
+		Client:   k8sClient,
+		Log:      ctrl.Log.WithName("test"),
+		Recorder: record.NewFakeRecorder(10),
+		Datadog:  clients,
+	}
+
+	// A DatadogCredentials without a client yet has nothing to replace
+	result, err := r.Reconcile(ctrl.Request{NamespacedName: name})
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Len(t, clients.secretValues, 1)
+
+	// Once no DatadogCredentials uses the Secret it's dropped from the cache
+	assert.Nil(t, k8sClient.Delete(context.Background(), credentials))
+	_, err = r.Reconcile(ctrl.Request{NamespacedName: name})
+	assert.Nil(t, err)
+	assert.Empty(t, clients.secretValues)
+}
+
+func TestRequestsForSecrets(t *testing.T) {
+	credentials, _ := teamCredentials("team-a", "")
+	credentials.Spec.AppKeySecretRef.Name = "datadog-app-keys"
+
+	requests := requestsForSecrets(handler.MapObject{Meta: credentials, Object: credentials})
+	assert.ElementsMatch(t, []types.NamespacedName{
+		{Namespace: "default", Name: "datadog-team-a"},
+		{Namespace: "default", Name: "datadog-app-keys"},
+	}, []types.NamespacedName{requests[0].NamespacedName, requests[1].NamespacedName})
+
+	// Keys in the same Secret map to one request
+	credentials, _ = teamCredentials("team-b", "")
+	assert.Len(t, requestsForSecrets(handler.MapObject{Meta: credentials, Object: credentials}), 1)
+}
@@ -0,0 +1,329 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/max-rocket-internet/datadog-controller/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"time"
+)
+
+// DatadogDowntimeReconciler reconciles a DatadogDowntime object
+type DatadogDowntimeReconciler struct {
+	client.Client
+	stopContext
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Datadog  DowntimeClients
+	// How long to wait before applying a spec Datadog rejected again. 0
+	// disables it.
+	ResyncPeriod time.Duration
+	// Only plan changes to downtimes in Datadog without making them
+	DryRun bool
+}
+
+const (
+	downtimeDeletionFinalizer = "datadogdowntimes.finalizers.datadoghq.com"
+)
+
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogdowntimes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogdowntimes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogmonitors,verbs=get;list;watch
+
+func (r *DatadogDowntimeReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx, cancel := r.newContext()
+	defer cancel()
+
+	log := r.Log.WithValues("downtime", req.NamespacedName)
+
+	instance := &datadoghqcomv1beta1.DatadogDowntime{}
+
+	log.V(1).Info("Getting resource from cluster")
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	dd, err := r.Datadog.DowntimesFor(ctx, r, instance.Namespace, instance.Spec.CredentialsRef)
+	updateStatus := func() error { return r.updateStatus(ctx, log, instance) }
+	if result, done, err := resultForClientError(log, err, instance, instance.Status.Id != 0, &instance.Status.Conditions, updateStatus); done {
+		return result, err
+	}
+
+	if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		return resultForError(log, r.deleteDowntime(ctx, log, dd, instance))
+	}
+
+	monitorId, err := r.resolveMonitorId(ctx, instance)
+	if isReferenceError(err) {
+		// Reconciled again once the resource or the referenced monitor changes
+		log.Info(fmt.Sprintf("Invalid reference: %v", err))
+
+		if setSynced(&instance.Status.Conditions, "InvalidReference", err) {
+			r.Recorder.Eventf(instance, "Warning", "InvalidReference", fmt.Sprint(err))
+			if err := r.updateStatus(ctx, log, instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		log.Info(fmt.Sprintf("Waiting for referenced monitor: %v", err))
+
+		if setSynced(&instance.Status.Conditions, "MonitorNotReady", err) {
+			if err := r.updateStatus(ctx, log, instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		return ctrl.Result{RequeueAfter: dependencyRequeueDelay}, nil
+	}
+
+	dryRun := isDryRun(r.DryRun, instance)
+
+	if instance.Status.Plan != nil && !dryRun {
+		log.V(1).Info("Clearing plan as dry-run is disabled")
+		instance.Status.Plan = nil
+		if err := r.updateStatus(ctx, log, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if dryRun {
+		err = r.planDowntime(ctx, log, instance, monitorId)
+	} else if instance.Status.Id == 0 {
+		err = r.createDowntime(ctx, log, dd, instance, monitorId)
+	} else if instance.ObjectMeta.Generation != instance.Status.ObservedGeneration || monitorId != instance.Status.MonitorId {
+		err = r.updateDowntime(ctx, log, dd, instance, monitorId)
+	} else {
+		log.V(1).Info("Skipping as generation is not new")
+	}
+
+	if err != nil {
+		return resultForSpecError(log, err, jitter(r.ResyncPeriod))
+	}
+
+	if !utils.ContainsString(instance.ObjectMeta.Finalizers, downtimeDeletionFinalizer) {
+		patch := client.MergeFrom(instance.DeepCopy())
+		instance.ObjectMeta.Finalizers = append(instance.ObjectMeta.Finalizers, downtimeDeletionFinalizer)
+		log.V(1).Info("Adding finalizer")
+		if err := r.Patch(ctx, instance, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// resolveMonitorId returns the ID of the monitor to mute, reading it from the
+// referenced DatadogMonitor when monitor_ref is set
+func (r *DatadogDowntimeReconciler) resolveMonitorId(ctx context.Context, instance *datadoghqcomv1beta1.DatadogDowntime) (int64, error) {
+	if instance.Spec.MonitorRef == "" {
+		return instance.Spec.MonitorId, nil
+	}
+
+	return monitorIdFromRef(ctx, r, instance.Namespace, instance.Spec.CredentialsRef, instance.Spec.MonitorRef)
+}
+
+func (r *DatadogDowntimeReconciler) createDowntime(ctx context.Context, log logr.Logger, dd datadog.DowntimeAPI, instance *datadoghqcomv1beta1.DatadogDowntime, monitorId int64) error {
+	log.Info("Creating downtime")
+
+	downtimeId, err := dd.CreateDowntime(ctx, instance.Spec, monitorId)
+	if err != nil {
+		log.Error(err, "Downtime failed to create")
+		r.Recorder.Eventf(instance, "Warning", "FailedCreate", fmt.Sprint(err))
+
+		setSynced(&instance.Status.Conditions, "FailedCreate", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
+	}
+
+	log.V(1).Info(fmt.Sprintf("Downtime created with ID %v", downtimeId))
+	r.Recorder.Eventf(instance, "Normal", "SuccessfulCreate", fmt.Sprintf("Downtime created with ID %v", downtimeId))
+
+	instance.Status.Id = downtimeId
+	instance.Status.MonitorId = monitorId
+	instance.Status.ObservedGeneration = instance.ObjectMeta.Generation
+	setSynced(&instance.Status.Conditions, "Created", nil)
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+// recreateDowntime creates a downtime again that was deleted in Datadog. The ID
+// of the deleted downtime is kept until the new one is created.
+func (r *DatadogDowntimeReconciler) recreateDowntime(ctx context.Context, log logr.Logger, dd datadog.DowntimeAPI, instance *datadoghqcomv1beta1.DatadogDowntime, monitorId int64) error {
+	message := fmt.Sprintf("Downtime %v was deleted in Datadog, recreating it", instance.Status.Id)
+	log.Info(message)
+	r.Recorder.Eventf(instance, "Warning", "Recreating", message)
+
+	return r.createDowntime(ctx, log, dd, instance, monitorId)
+}
+
+func (r *DatadogDowntimeReconciler) updateDowntime(ctx context.Context, log logr.Logger, dd datadog.DowntimeAPI, instance *datadoghqcomv1beta1.DatadogDowntime, monitorId int64) error {
+	log.Info("Updating downtime")
+
+	if err := dd.UpdateDowntime(ctx, instance.Status.Id, instance.Spec, monitorId); err != nil {
+		if datadog.IsNotFound(err) {
+			return r.recreateDowntime(ctx, log, dd, instance, monitorId)
+		}
+
+		log.Error(err, "Downtime update failed")
+		r.Recorder.Eventf(instance, "Warning", "FailedUpdate", fmt.Sprint(err))
+
+		setSynced(&instance.Status.Conditions, "FailedUpdate", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
+	}
+
+	log.V(1).Info(fmt.Sprintf("Downtime updated with ID %v", instance.Status.Id))
+	r.Recorder.Eventf(instance, "Normal", "SuccessfulUpdate", fmt.Sprintf("Downtime updated with ID %v", instance.Status.Id))
+
+	instance.Status.MonitorId = monitorId
+	instance.Status.ObservedGeneration = instance.ObjectMeta.Generation
+	setSynced(&instance.Status.Conditions, "Updated", nil)
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+func (r *DatadogDowntimeReconciler) deleteDowntime(ctx context.Context, log logr.Logger, dd datadog.DowntimeAPI, instance *datadoghqcomv1beta1.DatadogDowntime) error {
+	log.V(1).Info("Deleting downtime")
+
+	if !utils.ContainsString(instance.ObjectMeta.Finalizers, downtimeDeletionFinalizer) {
+		return nil
+	}
+
+	if instance.Status.Id != 0 && isDryRun(r.DryRun, instance) {
+		updateStatus := func() error { return r.updateStatus(ctx, log, instance) }
+		if err := planDeletion(log, r.Recorder, instance, "downtime", instance.Status.Id, &instance.Status.Plan, &instance.Status.Conditions, updateStatus); err != nil {
+			return err
+		}
+	} else if instance.Status.Id == 0 {
+		log.V(1).Info("Skipping deletion as downtime was never created")
+	} else if err := dd.DeleteDowntime(ctx, instance.Status.Id); err != nil {
+		log.Error(err, "Failed to delete downtime from datadog")
+		r.Recorder.Eventf(instance, "Warning", "FailedDelete", fmt.Sprint(err))
+
+		setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionReady, false, "FailedDelete", err.Error())
+		setDegraded(&instance.Status.Conditions, "FailedDelete", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
+	} else {
+		log.Info("Deleted downtime")
+	}
+
+	patch := client.MergeFrom(instance.DeepCopy())
+	instance.ObjectMeta.Finalizers = utils.RemoveString(instance.ObjectMeta.Finalizers, downtimeDeletionFinalizer)
+	log.V(1).Info("Removing finalizer")
+
+	return r.Patch(ctx, instance, patch)
+}
+
+// planDowntime records in the status whether applying the spec would create or
+// update the downtime, without writing to Datadog. Downtimes aren't read from
+// Datadog so changes made outside the controller aren't planned.
+func (r *DatadogDowntimeReconciler) planDowntime(ctx context.Context, log logr.Logger, instance *datadoghqcomv1beta1.DatadogDowntime, monitorId int64) error {
+	plan := datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionNone}
+
+	if instance.Status.Id == 0 {
+		plan.Action = datadoghqcomv1beta1.PlanActionCreate
+	} else {
+		if instance.ObjectMeta.Generation != instance.Status.ObservedGeneration {
+			plan.Changes = append(plan.Changes, "spec")
+		}
+		if monitorId != instance.Status.MonitorId {
+			plan.Changes = append(plan.Changes, "monitor_id")
+		}
+		if len(plan.Changes) > 0 {
+			plan.Action = datadoghqcomv1beta1.PlanActionUpdate
+		}
+	}
+
+	log.V(1).Info(planMessage("downtime", plan))
+
+	if !setPlan(r.Recorder, instance, "downtime", &instance.Status.Plan, &instance.Status.Conditions, plan) {
+		return nil
+	}
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+// updateStatus writes the status back to the cluster through the status subresource
+func (r *DatadogDowntimeReconciler) updateStatus(ctx context.Context, log logr.Logger, instance *datadoghqcomv1beta1.DatadogDowntime) error {
+	if err := r.Status().Update(ctx, instance); err != nil {
+		log.Error(err, "Failed to update status")
+		return err
+	}
+
+	return nil
+}
+
+// requestsForMonitor maps a changed DatadogMonitor to the downtimes that
+// reference it with monitor_ref, so they are applied as soon as it's created
+// in Datadog and follow it when it's recreated with another ID
+func (r *DatadogDowntimeReconciler) requestsForMonitor(object handler.MapObject) []reconcile.Request {
+	requests := []reconcile.Request{}
+
+	downtimes := &datadoghqcomv1beta1.DatadogDowntimeList{}
+	if err := r.List(context.Background(), downtimes, client.InNamespace(object.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "Failed to list downtimes to find references")
+		return requests
+	}
+
+	for _, downtime := range downtimes.Items {
+		if downtime.Spec.MonitorRef == object.Meta.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: downtime.Namespace, Name: downtime.Name}})
+		}
+	}
+
+	return requests
+}
+
+func (r *DatadogDowntimeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&datadoghqcomv1beta1.DatadogDowntime{}).
+		Watches(&source.Kind{Type: &datadoghqcomv1beta1.DatadogMonitor{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.requestsForMonitor),
+		}).
+		Complete(r)
+}
@@ -0,0 +1,270 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+)
+
+func testDowntime(spec datadoghqcomv1beta1.DatadogDowntimeSpec) *datadoghqcomv1beta1.DatadogDowntime {
+	return &datadoghqcomv1beta1.DatadogDowntime{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "maintenance", Generation: 1},
+		Spec:       spec,
+	}
+}
+
+// reconcileDowntime reconciles the DatadogDowntime "maintenance" and returns
+// it as it's stored afterwards
+func reconcileDowntime(t *testing.T, r *DatadogDowntimeReconciler) (*datadoghqcomv1beta1.DatadogDowntime, ctrl.Result) {
+	t.Helper()
+
+	key := types.NamespacedName{Namespace: "default", Name: "maintenance"}
+	result, err := r.Reconcile(ctrl.Request{NamespacedName: key})
+	assert.Nil(t, err)
+
+	instance := &datadoghqcomv1beta1.DatadogDowntime{}
+	assert.Nil(t, r.Get(context.Background(), key, instance))
+
+	return instance, result
+}
+
+func TestDowntimeLifecycle(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	monitorId := server.CreateMonitor(datadoghqcomv1beta1.DatadogMonitorSpec{Name: "cpu", Type: "metric alert", Query: "avg(last_5m):avg:system.cpu.user{*} > 90"})
+	instance := testDowntime(datadoghqcomv1beta1.DatadogDowntimeSpec{Scope: []string{"env:staging"}, MonitorId: monitorId, Message: "Maintenance"})
+
+	r := newTestDowntimeReconciler(t, server, instance)
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	instance, result := reconcileDowntime(t, r)
+	assert.Zero(t, result.RequeueAfter)
+	assert.NotZero(t, instance.Status.Id)
+	assert.Equal(t, monitorId, instance.Status.MonitorId)
+	assert.Equal(t, int64(1), instance.Status.ObservedGeneration)
+	assert.Contains(t, instance.Finalizers, downtimeDeletionFinalizer)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionTrue, "Created")
+	assert.Contains(t, <-recorder.Events, "Normal SuccessfulCreate Downtime created with ID")
+
+	downtime, ok := server.Downtime(instance.Status.Id)
+	assert.True(t, ok)
+	assert.Equal(t, "Maintenance", downtime["message"])
+	assert.Equal(t, float64(monitorId), downtime["monitor_id"])
+
+	// Nothing is written while the generation is applied
+	reconcileDowntime(t, r)
+	assert.Zero(t, server.Requests("PUT /downtime/:id"))
+
+	// A new generation updates the downtime
+	instance.Spec.Message = "Extended maintenance"
+	instance.Generation = 2
+	assert.Nil(t, r.Update(context.Background(), instance))
+
+	instance, _ = reconcileDowntime(t, r)
+	assert.Equal(t, 1, server.Requests("PUT /downtime/:id"))
+	assert.Equal(t, int64(2), instance.Status.ObservedGeneration)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionTrue, "Updated")
+	assert.Contains(t, <-recorder.Events, "Normal SuccessfulUpdate Downtime updated with ID")
+	downtime, _ = server.Downtime(instance.Status.Id)
+	assert.Equal(t, "Extended maintenance", downtime["message"])
+
+	// Deleting the resource deletes the downtime and removes the finalizer
+	now := metav1.Now()
+	instance.DeletionTimestamp = &now
+	assert.Nil(t, r.Update(context.Background(), instance))
+
+	instance, _ = reconcileDowntime(t, r)
+	assert.NotContains(t, instance.Finalizers, downtimeDeletionFinalizer)
+	_, ok = server.Downtime(instance.Status.Id)
+	assert.False(t, ok)
+}
+
+func TestDowntimeRecreated(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	instance := testDowntime(datadoghqcomv1beta1.DatadogDowntimeSpec{Scope: []string{"env:staging"}})
+	instance.Generation = 2
+	instance.Status = datadoghqcomv1beta1.DatadogDowntimeStatus{Id: 42, ObservedGeneration: 1}
+
+	r := newTestDowntimeReconciler(t, server, instance)
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	// A downtime deleted in Datadog is created again on the next update
+	instance, _ = reconcileDowntime(t, r)
+	assert.NotEqual(t, int64(42), instance.Status.Id)
+	_, ok := server.Downtime(instance.Status.Id)
+	assert.True(t, ok)
+	assert.Equal(t, "Warning Recreating Downtime 42 was deleted in Datadog, recreating it", <-recorder.Events)
+}
+
+func TestDowntimeMonitorRef(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	cpu := referencedMonitor("cpu", 0, "avg(last_5m):avg:system.cpu.user{*} > 90")
+	instance := testDowntime(datadoghqcomv1beta1.DatadogDowntimeSpec{Scope: []string{"*"}, MonitorRef: "cpu"})
+
+	r := newTestDowntimeReconciler(t, server, cpu, instance)
+
+	// Retried until the referenced monitor is created in Datadog
+	instance, result := reconcileDowntime(t, r)
+	assert.Equal(t, dependencyRequeueDelay, result.RequeueAfter)
+	assert.Zero(t, instance.Status.Id)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "MonitorNotReady")
+	assert.Zero(t, server.Requests("POST /downtime"))
+
+	// The downtime is reconciled again once the monitor changes
+	requests := r.requestsForMonitor(handler.MapObject{Meta: cpu, Object: cpu})
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "maintenance"}}}, requests)
+
+	cpu.Status.Id = server.CreateMonitor(datadoghqcomv1beta1.DatadogMonitorSpec{Name: "cpu", Type: "metric alert", Query: "avg(last_5m):avg:system.cpu.user{*} > 90"})
+	assert.Nil(t, r.Status().Update(context.Background(), cpu))
+
+	instance, result = reconcileDowntime(t, r)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, cpu.Status.Id, instance.Status.MonitorId)
+	downtime, ok := server.Downtime(instance.Status.Id)
+	assert.True(t, ok)
+	assert.Equal(t, float64(cpu.Status.Id), downtime["monitor_id"])
+
+	// A monitor recreated with another ID updates the downtime
+	cpu.Status.Id = server.CreateMonitor(datadoghqcomv1beta1.DatadogMonitorSpec{Name: "cpu", Type: "metric alert", Query: "avg(last_5m):avg:system.cpu.user{*} > 90"})
+	assert.Nil(t, r.Status().Update(context.Background(), cpu))
+
+	instance, _ = reconcileDowntime(t, r)
+	assert.Equal(t, 1, server.Requests("PUT /downtime/:id"))
+	assert.Equal(t, cpu.Status.Id, instance.Status.MonitorId)
+	downtime, _ = server.Downtime(instance.Status.Id)
+	assert.Equal(t, float64(cpu.Status.Id), downtime["monitor_id"])
+}
+
+func TestDowntimeInvalidMonitorRef(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	cpu := referencedMonitor("cpu", 11, "avg(last_5m):avg:system.cpu.user{*} > 90")
+	cpu.Spec.CredentialsRef = "team-a"
+	instance := testDowntime(datadoghqcomv1beta1.DatadogDowntimeSpec{Scope: []string{"*"}, MonitorRef: "cpu"})
+
+	r := newTestDowntimeReconciler(t, server, cpu, instance)
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	// A monitor of another organization can't be muted, so it's not retried
+	instance, result := reconcileDowntime(t, r)
+	assert.Zero(t, result.RequeueAfter)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "InvalidReference")
+	assert.Contains(t, <-recorder.Events, "Warning InvalidReference")
+	assert.Zero(t, server.Requests("POST /downtime"))
+}
+
+func TestDowntimeCredentialsError(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	instance := testDowntime(datadoghqcomv1beta1.DatadogDowntimeSpec{Scope: []string{"*"}, CredentialsRef: "missing"})
+	instance.Finalizers = []string{downtimeDeletionFinalizer}
+
+	r := newTestDowntimeReconciler(t, server, instance)
+
+	instance, result := reconcileDowntime(t, r)
+	assert.Equal(t, dependencyRequeueDelay, result.RequeueAfter)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "FailedCredentials")
+	assert.Zero(t, server.Requests("POST /downtime"))
+
+	// A downtime that was never created is deleted without the credentials
+	now := metav1.Now()
+	instance.DeletionTimestamp = &now
+	assert.Nil(t, r.Update(context.Background(), instance))
+
+	instance, result = reconcileDowntime(t, r)
+	assert.Zero(t, result.RequeueAfter)
+	assert.NotContains(t, instance.Finalizers, downtimeDeletionFinalizer)
+
+	// A created downtime waits for the credentials to be deleted in Datadog
+	instance.Finalizers = []string{downtimeDeletionFinalizer}
+	instance.Status.Id = 42
+	assert.Nil(t, r.Update(context.Background(), instance))
+
+	instance, result = reconcileDowntime(t, r)
+	assert.Equal(t, dependencyRequeueDelay, result.RequeueAfter)
+	assert.Contains(t, instance.Finalizers, downtimeDeletionFinalizer)
+}
+
+func TestPlanDowntime(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	instance := testDowntime(datadoghqcomv1beta1.DatadogDowntimeSpec{Scope: []string{"*"}, Message: "Maintenance"})
+
+	r := newTestDowntimeReconciler(t, server, instance)
+	r.DryRun = true
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	instance, _ = reconcileDowntime(t, r)
+	assert.Equal(t, &datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionCreate}, instance.Status.Plan)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "DryRun")
+	assert.Equal(t, "Normal DryRun Dry run: would create downtime", <-recorder.Events)
+	assert.Zero(t, server.Requests("POST /downtime"))
+
+	// A downtime created before dry-run was enabled plans its changes
+	instance.Status.Id = 42
+	instance.Status.ObservedGeneration = 1
+	instance.Status.MonitorId = 11
+	assert.Nil(t, r.Status().Update(context.Background(), instance))
+
+	instance, _ = reconcileDowntime(t, r)
+	assert.Equal(t, &datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionUpdate, Changes: []string{"monitor_id"}}, instance.Status.Plan)
+	assert.Equal(t, "Normal DryRun Dry run: would update downtime: monitor_id", <-recorder.Events)
+
+	instance.Status.MonitorId = 0
+	assert.Nil(t, r.Status().Update(context.Background(), instance))
+
+	instance, _ = reconcileDowntime(t, r)
+	assert.Equal(t, &datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionNone}, instance.Status.Plan)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionTrue, "DryRun")
+
+	// Disabling dry-run clears the plan
+	r.DryRun = false
+
+	instance, _ = reconcileDowntime(t, r)
+	assert.Nil(t, instance.Status.Plan)
+	assert.Zero(t, server.Requests("PUT /downtime/:id"))
+
+	// A deletion in dry-run mode is planned and the downtime left in Datadog
+	r.DryRun = true
+	now := metav1.Now()
+	instance.Finalizers = []string{downtimeDeletionFinalizer}
+	instance.DeletionTimestamp = &now
+	assert.Nil(t, r.Update(context.Background(), instance))
+
+	instance, _ = reconcileDowntime(t, r)
+	assert.Equal(t, &datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionDelete}, instance.Status.Plan)
+	assert.NotContains(t, instance.Finalizers, downtimeDeletionFinalizer)
+	assert.Zero(t, server.Requests("DELETE /downtime/:id"))
+}
@@ -18,36 +18,91 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"github.com/go-logr/logr"
 	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
 	"github.com/max-rocket-internet/datadog-controller/datadog"
 	"github.com/max-rocket-internet/datadog-controller/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/record"
+	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // DatadogMonitorReconciler reconciles a DatadogMonitor object
 type DatadogMonitorReconciler struct {
 	client.Client
+	stopContext
 	Log      logr.Logger
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
-	Datadog  datadog.Datadog
+	Datadog  MonitorClients
+	// How often monitors are compared with Datadog to detect drift and
+	// recreate monitors deleted outside the controller, unless a monitor
+	// sets its own with an annotation. 0 disables it.
+	ResyncPeriod time.Duration
+	// Only plan changes to monitors in Datadog without making them
+	DryRun bool
+	// The deletion policy of monitors that don't set one, Delete or Orphan
+	DeletionPolicy string
+	// The name of the cluster in the ownership tags of monitors
+	ClusterName string
+	// Variables for the templates in monitor specs
+	TemplateVars map[string]string
 }
 
 const (
 	deletionFinalizer = "datadogmonitors.finalizers.datadoghq.com"
+	// Limit on the number of group states kept in the status
+	maxGroupStates = 50
+	// Resyncs are delayed by up to this fraction of the resync period
+	resyncJitterFactor = 0.1
+)
+
+var (
+	monitorDriftGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "datadog_controller",
+		Subsystem: "monitor",
+		Name:      "drifted",
+		Help:      "Whether a monitor in Datadog differs from its DatadogMonitor spec",
+	}, []string{
+		"namespace",
+		"name",
+	})
+
+	monitorRecreatedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "datadog_controller",
+		Subsystem: "monitor",
+		Name:      "recreated",
+		Help:      "Count of monitors recreated after they were deleted in Datadog outside the controller",
+	})
 )
 
 // +kubebuilder:rbac:groups=datadoghq.com,resources=datadogmonitors,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=datadoghq.com,resources=datadogmonitors/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogslos,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *DatadogMonitorReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	ctx := context.Background()
+	ctx, cancel := r.newContext()
+	defer cancel()
+
 	log := r.Log.WithValues("monitor", req.NamespacedName)
 
 	instance := &datadoghqcomv1beta1.DatadogMonitor{}
@@ -61,110 +116,929 @@ func (r *DatadogMonitorReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 		return ctrl.Result{}, err
 	}
 
-	if instance.ObjectMeta.DeletionTimestamp.IsZero() {
-		if instance.Status.Id == 0 {
-			log.Info("Creating monitor")
-			monitorId, err := r.Datadog.CreateMonitor(instance.Spec)
+	dd, err := r.Datadog.MonitorsFor(ctx, r, instance.Namespace, instance.Spec.CredentialsRef)
+	updateStatus := func() error { return r.updateStatus(ctx, log, instance) }
+	if result, done, err := resultForClientError(log, err, instance, instance.Status.Id != 0, &instance.Status.Conditions, updateStatus); done {
+		return result, err
+	}
 
-			if err != nil {
-				log.Error(err, "Monitor failed to create")
+	if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		blocked, err := r.blockDeletion(ctx, log, instance)
+		if err != nil || blocked {
+			return ctrl.Result{RequeueAfter: dependencyRequeueDelay}, err
+		}
 
-				r.Recorder.Eventf(instance, "Warning", "FailedCreate", fmt.Sprint(err))
-				instance.Status.Status = "FailedCreate"
-				instance.Status.ObservedGeneration = instance.ObjectMeta.Generation + 1
+		return resultForError(log, r.deleteMonitor(ctx, log, dd, instance))
+	}
 
-				if err = r.Update(ctx, instance); err != nil {
-					log.Error(err, "Failed to update status after failed monitor creation")
-					return ctrl.Result{}, err
-				}
+	rendered, err := instance.Spec.Render(r.templateData(instance))
+	if err != nil {
+		// Reconciled again once the resource changes
+		log.Info(fmt.Sprintf("Failed to render templates: %v", err))
 
+		if setSynced(&instance.Status.Conditions, "FailedTemplate", err) {
+			r.Recorder.Eventf(instance, "Warning", "FailedTemplate", fmt.Sprint(err))
+			if err := r.updateStatus(ctx, log, instance); err != nil {
 				return ctrl.Result{}, err
-			} else {
-				log.V(1).Info(fmt.Sprintf("Monitor created with ID %v", monitorId))
-				r.Recorder.Eventf(instance, "Normal", "SuccessfulCreate", fmt.Sprintf("Monitor created with ID %v", monitorId))
-
-				instance.Status.Id = monitorId
-				instance.Status.Url = fmt.Sprintf("https://app.%v/monitors/%v", r.Datadog.Conf.DatadogHost, monitorId)
-				instance.Status.Status = "Created"
-				instance.Status.ObservedGeneration = instance.ObjectMeta.Generation + 1
-
-				if err = r.Update(ctx, instance); err != nil {
-					log.Error(err, "Failed to update status after monitor creation")
-					return ctrl.Result{}, err
-				}
 			}
-		} else if instance.ObjectMeta.Generation != instance.Status.ObservedGeneration {
-			log.Info("Updating monitor")
+		}
 
-			err := r.Datadog.UpdateMonitor(instance.Status.Id, instance.Spec)
+		return ctrl.Result{}, nil
+	}
 
-			if err != nil {
-				log.Error(err, "Monitor update failed")
+	spec, err := r.resolveSpec(ctx, instance, rendered)
+	if isReferenceError(err) {
+		// Reconciled again once the resource or the referenced resource changes
+		log.Info(fmt.Sprintf("Invalid reference: %v", err))
 
-				r.Recorder.Eventf(instance, "Warning", "FailedUpdate", fmt.Sprint(err))
-				instance.Status.Status = "FailedUpdate"
-				instance.Status.ObservedGeneration = instance.ObjectMeta.Generation + 1
+		if setSynced(&instance.Status.Conditions, "InvalidReference", err) {
+			r.Recorder.Eventf(instance, "Warning", "InvalidReference", fmt.Sprint(err))
+			if err := r.updateStatus(ctx, log, instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 
-				if err = r.Update(ctx, instance); err != nil {
-					log.Error(err, "Failed to update status after failed monitor update")
-					return ctrl.Result{}, err
-				}
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		log.Info(fmt.Sprintf("Waiting for referenced resource: %v", err))
 
+		if setSynced(&instance.Status.Conditions, "ReferenceNotReady", err) {
+			if err := r.updateStatus(ctx, log, instance); err != nil {
 				return ctrl.Result{}, err
-			} else {
-				log.V(1).Info(fmt.Sprintf("Monitor updated with ID %v", instance.Status.Id))
-				r.Recorder.Eventf(instance, "Normal", "SuccessfulUpdate", fmt.Sprintf("Monitor updated with ID %v", instance.Status.Id))
+			}
+		}
 
-				instance.Status.Status = "Updated"
-				instance.Status.ObservedGeneration = instance.ObjectMeta.Generation + 1
+		return ctrl.Result{RequeueAfter: dependencyRequeueDelay}, nil
+	}
 
-				if err = r.Update(ctx, instance); err != nil {
-					log.Error(err, "Failed to update status after monitor update")
-					return ctrl.Result{}, err
-				}
-			}
+	dryRun := isDryRun(r.DryRun, instance)
+	resync := r.resyncPeriod(log, instance)
 
-		} else {
-			log.V(1).Info("Skipping as generation is not new")
+	if instance.Status.Plan != nil && !dryRun {
+		log.V(1).Info("Clearing plan as dry-run is disabled")
+		instance.Status.Plan = nil
+		if err := r.updateStatus(ctx, log, instance); err != nil {
+			return ctrl.Result{}, err
 		}
+	}
 
-		if !utils.ContainsString(instance.ObjectMeta.Finalizers, deletionFinalizer) {
-			instance.ObjectMeta.Finalizers = append(instance.ObjectMeta.Finalizers, deletionFinalizer)
-			log.V(1).Info("Adding finalizer")
-			if err := r.Update(context.Background(), instance); err != nil {
-				return ctrl.Result{}, err
+	if dryRun {
+		err = r.planMonitor(ctx, log, dd, instance, spec, resync)
+	} else if _, adopt := instance.Annotations[datadoghqcomv1beta1.AdoptMonitorIdAnnotation]; adopt && instance.Status.Id == 0 {
+		err = r.adoptMonitor(ctx, log, dd, instance, spec)
+	} else if instance.Status.Id == 0 {
+		err = r.createMonitor(ctx, log, dd, instance, spec)
+	} else if specChanged(instance, spec) {
+		err = r.updateMonitor(ctx, log, dd, instance, spec)
+	} else if resync > 0 {
+		err = r.resyncMonitor(ctx, log, dd, instance, spec)
+	} else {
+		log.V(1).Info("Skipping as generation is not new")
+	}
+
+	if err != nil {
+		return resultForSpecError(log, err, jitter(resync))
+	}
+
+	if !utils.ContainsString(instance.ObjectMeta.Finalizers, deletionFinalizer) {
+		patch := client.MergeFrom(instance.DeepCopy())
+		instance.ObjectMeta.Finalizers = append(instance.ObjectMeta.Finalizers, deletionFinalizer)
+		log.V(1).Info("Adding finalizer")
+		if err := r.Patch(ctx, instance, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: jitter(resync)}, nil
+}
+
+// owner returns the owner of the monitor of a resource, for its ownership tags
+func (r *DatadogMonitorReconciler) owner(instance *datadoghqcomv1beta1.DatadogMonitor) datadog.Owner {
+	return datadog.Owner{
+		Cluster:   r.ClusterName,
+		Namespace: instance.Namespace,
+		Name:      instance.Name,
+		UID:       string(instance.UID),
+	}
+}
+
+// resyncPeriod returns how often the monitor is compared with Datadog, from
+// its annotation or the period of the controller
+func (r *DatadogMonitorReconciler) resyncPeriod(log logr.Logger, instance *datadoghqcomv1beta1.DatadogMonitor) time.Duration {
+	annotation, ok := instance.Annotations[datadoghqcomv1beta1.ResyncPeriodAnnotation]
+	if !ok {
+		return r.ResyncPeriod
+	}
+
+	period, err := time.ParseDuration(annotation)
+	if err != nil || period < 0 {
+		log.Info(fmt.Sprintf("Ignoring invalid %v annotation %q", datadoghqcomv1beta1.ResyncPeriodAnnotation, annotation))
+		return r.ResyncPeriod
+	}
+
+	return period
+}
+
+// jitter spreads out resyncs of monitors created or changed at the same
+// time, e.g. after the controller starts, so they don't all hit the API at
+// once
+func jitter(period time.Duration) time.Duration {
+	if period <= 0 {
+		return 0
+	}
+
+	return wait.Jitter(period, resyncJitterFactor)
+}
+
+// templateData returns what the templates in the spec of a resource are
+// rendered with
+func (r *DatadogMonitorReconciler) templateData(instance *datadoghqcomv1beta1.DatadogMonitor) datadoghqcomv1beta1.TemplateData {
+	return datadoghqcomv1beta1.TemplateData{
+		Namespace:   instance.Namespace,
+		Name:        instance.Name,
+		Labels:      instance.Labels,
+		Annotations: instance.Annotations,
+		Cluster:     r.ClusterName,
+		Vars:        r.TemplateVars,
+	}
+}
+
+// resolveSpec returns the spec to apply to Datadog, with references to other
+// resources in the query of the rendered spec replaced by their IDs
+func (r *DatadogMonitorReconciler) resolveSpec(ctx context.Context, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec) (datadoghqcomv1beta1.DatadogMonitorSpec, error) {
+	query, err := resolveQuery(ctx, r, instance.Namespace, instance.Spec.CredentialsRef, spec.Query)
+	if err != nil {
+		return spec, err
+	}
+	spec.Query = query
+
+	return spec, nil
+}
+
+// specChanged reports whether the spec, or what it resolves or renders to,
+// changed since it was last applied to Datadog
+func specChanged(instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec) bool {
+	return instance.ObjectMeta.Generation != instance.Status.ObservedGeneration ||
+		instance.Status.ResolvedQuery != resolvedQuery(instance.Spec, spec) ||
+		instance.Status.TemplateHash != templateHash(instance.Spec, spec)
+}
+
+// templateHash returns a hash of the resolved spec if the original spec has
+// templates, so changes of labels, annotations or variables are applied
+func templateHash(original datadoghqcomv1beta1.DatadogMonitorSpec, resolved datadoghqcomv1beta1.DatadogMonitorSpec) string {
+	if !original.HasTemplates() {
+		return ""
+	}
+
+	data, _ := json.Marshal(resolved)
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+}
+
+// resolvedQuery returns the query of the resolved spec if it differs from
+// the query in the original spec, which is what's kept in the status
+func resolvedQuery(original datadoghqcomv1beta1.DatadogMonitorSpec, resolved datadoghqcomv1beta1.DatadogMonitorSpec) string {
+	if original.Query == resolved.Query {
+		return ""
+	}
+
+	return resolved.Query
+}
+
+// createMonitor creates the monitor in Datadog. If a monitor tagged with the
+// UID of the resource already exists, e.g. because the status could not be
+// saved after it was created, that monitor is updated and used instead.
+// A monitor being recreated still has the ID of the deleted monitor in the
+// status, which isn't reused as the monitor search lags behind deletions.
+func (r *DatadogMonitorReconciler) createMonitor(ctx context.Context, log logr.Logger, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec) error {
+	existingId, err := dd.FindOwnedMonitor(ctx, r.owner(instance))
+	if err != nil {
+		// Creating the monitor anyway could duplicate it
+		log.Error(err, "Failed to search for an existing monitor")
+		r.Recorder.Eventf(instance, "Warning", "FailedCreate", fmt.Sprint(err))
+
+		setSynced(&instance.Status.Conditions, "FailedCreate", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
+	}
+	if existingId != 0 && existingId != instance.Status.Id {
+		return r.reuseMonitor(ctx, log, dd, instance, spec, existingId)
+	}
+
+	log.Info("Creating monitor")
+
+	monitorId, err := dd.CreateMonitor(ctx, spec, r.owner(instance))
+	if err != nil {
+		log.Error(err, "Monitor failed to create")
+		r.Recorder.Eventf(instance, "Warning", "FailedCreate", fmt.Sprint(err))
+
+		setSynced(&instance.Status.Conditions, "FailedCreate", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
+	}
+
+	log.V(1).Info(fmt.Sprintf("Monitor created with ID %v", monitorId))
+	r.Recorder.Eventf(instance, "Normal", "SuccessfulCreate", fmt.Sprintf("Monitor created with ID %v", monitorId))
+
+	instance.Status.Id = monitorId
+	instance.Status.Url = dd.MonitorURL(monitorId)
+	instance.Status.ObservedGeneration = instance.ObjectMeta.Generation
+	instance.Status.ResolvedQuery = resolvedQuery(instance.Spec, spec)
+	instance.Status.TemplateHash = templateHash(instance.Spec, spec)
+	setSynced(&instance.Status.Conditions, "Created", nil)
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+// reuseMonitor applies the spec to a monitor that was created for the
+// resource before and saves its ID in the status
+func (r *DatadogMonitorReconciler) reuseMonitor(ctx context.Context, log logr.Logger, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec, monitorId int64) error {
+	log.Info(fmt.Sprintf("Monitor already exists with ID %v, reusing it", monitorId))
+
+	if err := dd.UpdateMonitor(ctx, monitorId, spec, r.owner(instance)); err != nil {
+		log.Error(err, "Monitor update failed")
+		r.Recorder.Eventf(instance, "Warning", "FailedUpdate", fmt.Sprint(err))
+
+		setSynced(&instance.Status.Conditions, "FailedUpdate", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
+	}
+
+	r.Recorder.Eventf(instance, "Normal", "SuccessfulCreate", fmt.Sprintf("Monitor already exists with ID %v, reusing it", monitorId))
+
+	instance.Status.Id = monitorId
+	instance.Status.Url = dd.MonitorURL(monitorId)
+	instance.Status.ObservedGeneration = instance.ObjectMeta.Generation
+	instance.Status.ResolvedQuery = resolvedQuery(instance.Spec, spec)
+	instance.Status.TemplateHash = templateHash(instance.Spec, spec)
+	setSynced(&instance.Status.Conditions, "Created", nil)
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+func (r *DatadogMonitorReconciler) updateMonitor(ctx context.Context, log logr.Logger, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec) error {
+	log.Info("Updating monitor")
+
+	err := dd.UpdateMonitor(ctx, instance.Status.Id, spec, r.owner(instance))
+	if datadog.IsNotFound(err) {
+		// A new spec is applied even if the monitor was deleted
+		return r.recreateMonitor(ctx, log, dd, instance, spec)
+	}
+	if err != nil {
+		log.Error(err, "Monitor update failed")
+		r.Recorder.Eventf(instance, "Warning", "FailedUpdate", fmt.Sprint(err))
+
+		setSynced(&instance.Status.Conditions, "FailedUpdate", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
+	}
+
+	log.V(1).Info(fmt.Sprintf("Monitor updated with ID %v", instance.Status.Id))
+	r.Recorder.Eventf(instance, "Normal", "SuccessfulUpdate", fmt.Sprintf("Monitor updated with ID %v", instance.Status.Id))
+
+	instance.Status.ObservedGeneration = instance.ObjectMeta.Generation
+	instance.Status.ResolvedQuery = resolvedQuery(instance.Spec, spec)
+	instance.Status.TemplateHash = templateHash(instance.Spec, spec)
+	setSynced(&instance.Status.Conditions, "Updated", nil)
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+func (r *DatadogMonitorReconciler) deleteMonitor(ctx context.Context, log logr.Logger, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor) error {
+	log.V(1).Info("Deleting monitor")
+
+	if !utils.ContainsString(instance.ObjectMeta.Finalizers, deletionFinalizer) {
+		return nil
+	}
+
+	orphan := r.deletionPolicy(instance) == datadoghqcomv1beta1.DeletionPolicyOrphan
+
+	if instance.Status.Id != 0 && !orphan && isDryRun(r.DryRun, instance) {
+		updateStatus := func() error { return r.updateStatus(ctx, log, instance) }
+		if err := planDeletion(log, r.Recorder, instance, "monitor", instance.Status.Id, &instance.Status.Plan, &instance.Status.Conditions, updateStatus); err != nil {
+			return err
+		}
+	} else if instance.Status.Id == 0 {
+		log.V(1).Info("Skipping deletion as monitor was never created")
+	} else if orphan {
+		// Without its ownership tags the monitor isn't collected as garbage
+		if isDryRun(r.DryRun, instance) {
+			log.V(1).Info("Skipping removing ownership tags in dry-run mode")
+		} else if err := dd.DisownMonitor(ctx, instance.Status.Id); err != nil && !datadog.IsNotFound(err) {
+			log.Error(err, "Failed to remove ownership tags from monitor")
+			r.Recorder.Eventf(instance, "Warning", "FailedOrphan", fmt.Sprint(err))
+
+			setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionReady, false, "FailedOrphan", err.Error())
+			setDegraded(&instance.Status.Conditions, "FailedOrphan", err)
+			if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+				return statusErr
 			}
+
+			return err
 		}
+
+		log.Info(fmt.Sprintf("Leaving monitor %v in Datadog as the deletion policy is Orphan", instance.Status.Id))
+		r.Recorder.Eventf(instance, "Normal", "Orphaned", fmt.Sprintf("Monitor %v left in Datadog", instance.Status.Id))
+	} else if err := dd.DeleteMonitor(ctx, instance.Status.Id); err != nil {
+		log.Error(err, "Failed to delete Monitor from datadog")
+		r.Recorder.Eventf(instance, "Warning", "FailedDelete", fmt.Sprint(err))
+
+		setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionReady, false, "FailedDelete", err.Error())
+		setDegraded(&instance.Status.Conditions, "FailedDelete", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
 	} else {
-		log.V(1).Info("Deleting monitor")
-		if utils.ContainsString(instance.ObjectMeta.Finalizers, deletionFinalizer) {
-			if instance.Status.Id == 0 {
-				log.V(1).Info("Skipping deletion as monitor was never created")
-				return ctrl.Result{}, nil
+		log.Info("Deleted monitor")
+	}
+
+	monitorDriftGauge.DeleteLabelValues(instance.Namespace, instance.Name)
+
+	patch := client.MergeFrom(instance.DeepCopy())
+	instance.ObjectMeta.Finalizers = utils.RemoveString(instance.ObjectMeta.Finalizers, deletionFinalizer)
+	log.V(1).Info("Removing finalizer")
+
+	return r.Patch(ctx, instance, patch)
+}
+
+// deletionPolicy returns the deletion policy of a monitor, defaulting to the
+// one the controller is configured with
+func (r *DatadogMonitorReconciler) deletionPolicy(instance *datadoghqcomv1beta1.DatadogMonitor) string {
+	if instance.Spec.DeletionPolicy != "" {
+		return instance.Spec.DeletionPolicy
+	}
+
+	if r.DeletionPolicy != "" {
+		return r.DeletionPolicy
+	}
+
+	return datadoghqcomv1beta1.DeletionPolicyDelete
+}
+
+// blockDeletion keeps a monitor that is still referenced by another
+// DatadogMonitor in the same namespace, e.g. a composite monitor, or by a
+// DatadogSLO from being deleted in Datadog. It reports whether the deletion is blocked. Orphaned
+// monitors stay in Datadog so they are never blocked.
+func (r *DatadogMonitorReconciler) blockDeletion(ctx context.Context, log logr.Logger, instance *datadoghqcomv1beta1.DatadogMonitor) (bool, error) {
+	if instance.Status.Id == 0 || !utils.ContainsString(instance.ObjectMeta.Finalizers, deletionFinalizer) {
+		return false, nil
+	}
+
+	if r.deletionPolicy(instance) == datadoghqcomv1beta1.DeletionPolicyOrphan {
+		return false, nil
+	}
+
+	referrers, err := r.referringMonitors(ctx, referenceKindMonitor, instance.Namespace, instance.Name)
+	if err != nil {
+		log.Error(err, "Failed to list monitors to check for references")
+		return false, err
+	}
+
+	names := []string{}
+	for _, monitor := range referrers {
+		if monitor.ObjectMeta.DeletionTimestamp.IsZero() {
+			names = append(names, monitor.Name)
+		}
+	}
+
+	// Datadog refuses to delete a monitor used by an SLO. An SLO being
+	// deleted still blocks until it's gone, as it's only deleted in Datadog
+	// before its finalizer is removed.
+	slos, err := r.referringSLOs(ctx, instance.Namespace, instance.Name)
+	if err != nil {
+		log.Error(err, "Failed to list SLOs to check for references")
+		return false, err
+	}
+
+	for _, slo := range slos {
+		names = append(names, slo.Name)
+	}
+
+	if len(names) == 0 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("Monitor is referenced by %v", strings.Join(names, ", "))
+	log.Info(fmt.Sprintf("Waiting to delete monitor: %v", err))
+
+	if setDegraded(&instance.Status.Conditions, "DeletionBlocked", err) {
+		r.Recorder.Eventf(instance, "Warning", "DeletionBlocked", fmt.Sprint(err))
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return true, statusErr
+		}
+	}
+
+	return true, nil
+}
+
+// referringMonitors returns the DatadogMonitors in a namespace with a query
+// that references the given resource
+func (r *DatadogMonitorReconciler) referringMonitors(ctx context.Context, kind string, namespace string, name string) ([]datadoghqcomv1beta1.DatadogMonitor, error) {
+	monitors := &datadoghqcomv1beta1.DatadogMonitorList{}
+	if err := r.List(ctx, monitors, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	referrers := []datadoghqcomv1beta1.DatadogMonitor{}
+	for _, monitor := range monitors.Items {
+		if referencesResource(monitor.Spec.Query, kind, name) {
+			referrers = append(referrers, monitor)
+		}
+	}
+
+	return referrers, nil
+}
+
+// referringSLOs returns the DatadogSLOs in a namespace with the given
+// DatadogMonitor in their monitor_refs
+func (r *DatadogMonitorReconciler) referringSLOs(ctx context.Context, namespace string, name string) ([]datadoghqcomv1beta1.DatadogSLO, error) {
+	slos := &datadoghqcomv1beta1.DatadogSLOList{}
+	if err := r.List(ctx, slos, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	referrers := []datadoghqcomv1beta1.DatadogSLO{}
+	for _, slo := range slos.Items {
+		if utils.ContainsString(slo.Spec.MonitorRefs, name) {
+			referrers = append(referrers, slo)
+		}
+	}
+
+	return referrers, nil
+}
+
+// adoptMonitor takes ownership of an existing monitor in Datadog instead of
+// creating a new one. The monitor must exist and must not already be managed
+// by another DatadogMonitor in the cluster, either by its ID in the status or
+// by the ownership tags of the monitor.
+func (r *DatadogMonitorReconciler) adoptMonitor(ctx context.Context, log logr.Logger, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec) error {
+	annotation := instance.Annotations[datadoghqcomv1beta1.AdoptMonitorIdAnnotation]
+
+	monitorId, err := strconv.ParseInt(annotation, 10, 64)
+	if err != nil || monitorId <= 0 {
+		return r.failAdoption(ctx, log, instance, fmt.Errorf("Invalid monitor ID in %v annotation: %q", datadoghqcomv1beta1.AdoptMonitorIdAnnotation, annotation))
+	}
+
+	log.Info(fmt.Sprintf("Adopting monitor %v", monitorId))
+
+	monitors := &datadoghqcomv1beta1.DatadogMonitorList{}
+	if err := r.List(ctx, monitors); err != nil {
+		log.Error(err, "Failed to list monitors to check for existing owner")
+		return err
+	}
+
+	for _, monitor := range monitors.Items {
+		if monitor.UID == instance.UID || monitor.Status.Id != monitorId {
+			continue
+		}
+
+		// IDs are only unique within an organization, the monitor is only
+		// managed already if the other resource is in the same one
+		other, err := r.Datadog.MonitorsFor(ctx, r, monitor.Namespace, monitor.Spec.CredentialsRef)
+		if err != nil {
+			return r.failAdoption(ctx, log, instance, fmt.Errorf("Monitor %v may already be managed by %v/%v, its credentials can't be read: %v", monitorId, monitor.Namespace, monitor.Name, err))
+		}
+		sameOrganization, err := sameOrganization(ctx, other, dd)
+		if err != nil {
+			log.Error(err, "Failed to read organization to check for existing owner")
+			return err
+		}
+		if sameOrganization {
+			return r.failAdoption(ctx, log, instance, fmt.Errorf("Monitor %v is already managed by %v/%v", monitorId, monitor.Namespace, monitor.Name))
+		}
+	}
+
+	live, err := dd.GetMonitor(ctx, monitorId)
+	if err != nil {
+		if datadog.IsNotFound(err) {
+			return r.failAdoption(ctx, log, instance, fmt.Errorf("Monitor %v does not exist in Datadog", monitorId))
+		}
+		log.Error(err, "Failed to get monitor to adopt")
+		return err
+	}
+
+	// The status of a resource that just adopted or created the monitor might
+	// not be in the cache yet, so the ownership tags in Datadog are checked too
+	if owner := datadog.OwnerOf(live.DatadogMonitorSpec); owner.UID != "" && !strings.EqualFold(owner.UID, string(instance.UID)) {
+		for _, monitor := range monitors.Items {
+			if strings.EqualFold(string(monitor.UID), owner.UID) {
+				return r.failAdoption(ctx, log, instance, fmt.Errorf("Monitor %v is already managed by %v/%v", monitorId, monitor.Namespace, monitor.Name))
 			}
+		}
+	}
 
-			if err := r.Datadog.DeleteMonitor(instance.Status.Id); err != nil {
-				log.Error(err, "Failed to delete Monitor from datadog")
-				return ctrl.Result{}, err
+	if err := dd.UpdateMonitor(ctx, monitorId, spec, r.owner(instance)); err != nil {
+		log.Error(err, "Failed to apply spec to adopted monitor")
+		if statusErr := r.failAdoption(ctx, log, instance, err); statusErr != nil {
+			return statusErr
+		}
+		return err
+	}
+
+	log.V(1).Info(fmt.Sprintf("Monitor adopted with ID %v", monitorId))
+	r.Recorder.Eventf(instance, "Normal", "SuccessfulAdopt", fmt.Sprintf("Monitor adopted with ID %v", monitorId))
+
+	instance.Status.Id = monitorId
+	instance.Status.Url = dd.MonitorURL(monitorId)
+	instance.Status.ObservedGeneration = instance.ObjectMeta.Generation
+	instance.Status.ResolvedQuery = resolvedQuery(instance.Spec, spec)
+	instance.Status.TemplateHash = templateHash(instance.Spec, spec)
+	setSynced(&instance.Status.Conditions, "Adopted", nil)
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+// sameOrganization reports whether two monitor APIs are of the same Datadog
+// organization, whichever keys they use
+func sameOrganization(ctx context.Context, a datadog.MonitorAPI, b datadog.MonitorAPI) (bool, error) {
+	orgA, err := a.Organization(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	orgB, err := b.Organization(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return orgA == orgB, nil
+}
+
+// failAdoption records why a monitor could not be adopted. The resource is
+// reconciled again once its annotations or spec change.
+func (r *DatadogMonitorReconciler) failAdoption(ctx context.Context, log logr.Logger, instance *datadoghqcomv1beta1.DatadogMonitor, err error) error {
+	log.Info(fmt.Sprintf("Monitor failed to adopt: %v", err))
+	r.Recorder.Eventf(instance, "Warning", "FailedAdopt", fmt.Sprint(err))
+
+	setSynced(&instance.Status.Conditions, "FailedAdopt", err)
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+// planMonitor records in the status what reconciling the resource would do in
+// Datadog, without writing to Datadog. It takes the same branches as
+// Reconcile: adopting a monitor, creating one or reusing the one found by its
+// ownership tags, applying a new spec, and correcting drift on resync, which
+// is only planned if the drift policy isn't Report.
+func (r *DatadogMonitorReconciler) planMonitor(ctx context.Context, log logr.Logger, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec, resync time.Duration) error {
+	plan := datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionNone}
+	stateChanged := false
+
+	if annotation, adopt := instance.Annotations[datadoghqcomv1beta1.AdoptMonitorIdAnnotation]; adopt && instance.Status.Id == 0 {
+		adoptId, err := strconv.ParseInt(annotation, 10, 64)
+		if err != nil || adoptId <= 0 {
+			return r.failAdoption(ctx, log, instance, fmt.Errorf("Invalid monitor ID in %v annotation: %q", datadoghqcomv1beta1.AdoptMonitorIdAnnotation, annotation))
+		}
+
+		live, err := dd.GetMonitor(ctx, adoptId)
+		if datadog.IsNotFound(err) {
+			return r.failAdoption(ctx, log, instance, fmt.Errorf("Monitor %v does not exist in Datadog", adoptId))
+		}
+		if err != nil {
+			return r.failPlan(ctx, log, instance, err)
+		}
+
+		plan = datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionAdopt, Changes: datadog.DiffMonitor(spec, live.DatadogMonitorSpec)}
+	} else if instance.Status.Id == 0 {
+		var err error
+		if plan, err = r.planCreate(ctx, dd, instance, spec); err != nil {
+			return r.failPlan(ctx, log, instance, err)
+		}
+	} else if newSpec := specChanged(instance, spec); newSpec || resync > 0 {
+		live, err := dd.GetMonitor(ctx, instance.Status.Id)
+		if datadog.IsNotFound(err) {
+			// A new spec recreates a monitor deleted in Datadog, on resync it's
+			// only recreated if drift is corrected
+			log.V(1).Info(fmt.Sprintf("Monitor %v was deleted in Datadog", instance.Status.Id))
+			if newSpec || instance.Spec.DriftPolicy != datadoghqcomv1beta1.DriftPolicyReport {
+				if plan, err = r.planCreate(ctx, dd, instance, spec); err != nil {
+					return r.failPlan(ctx, log, instance, err)
+				}
+			}
+		} else if err != nil {
+			return r.failPlan(ctx, log, instance, err)
+		} else {
+			stateChanged = setMonitorState(&instance.Status, live)
+			changes := datadog.DiffMonitor(spec, live.DatadogMonitorSpec)
+
+			if newSpec {
+				// A new spec is applied even if Datadog already matches it
+				plan = datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionUpdate, Changes: changes}
+			} else if instance.Spec.DriftPolicy == datadoghqcomv1beta1.DriftPolicyReport {
+				// Drift is only reported, as it would be without dry-run
+				if len(changes) == 0 {
+					changes = nil
+				}
+				if !utils.EqualStrings(instance.Status.DriftedFields, changes) {
+					instance.Status.DriftedFields = changes
+					stateChanged = true
+				}
+			} else if len(changes) > 0 {
+				plan = datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionUpdate, Changes: changes}
 			}
+		}
+	}
 
-			log.Info("Deleted monitor")
+	log.V(1).Info(planMessage("monitor", plan))
 
-			instance.ObjectMeta.Finalizers = utils.RemoveString(instance.ObjectMeta.Finalizers, deletionFinalizer)
-			log.V(1).Info("Removing finalizer")
-			if err := r.Update(context.Background(), instance); err != nil {
-				return ctrl.Result{}, err
+	planChanged := setPlan(r.Recorder, instance, "monitor", &instance.Status.Plan, &instance.Status.Conditions, plan)
+	if !planChanged && !stateChanged {
+		return nil
+	}
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+// planCreate plans what createMonitor would do: reuse the monitor created for
+// the resource before if one is found by its ownership tags, or create one
+func (r *DatadogMonitorReconciler) planCreate(ctx context.Context, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec) (datadoghqcomv1beta1.Plan, error) {
+	existingId, err := dd.FindOwnedMonitor(ctx, r.owner(instance))
+	if err != nil {
+		return datadoghqcomv1beta1.Plan{}, err
+	}
+	if existingId == 0 || existingId == instance.Status.Id {
+		return datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionCreate}, nil
+	}
+
+	live, err := dd.GetMonitor(ctx, existingId)
+	if err != nil {
+		return datadoghqcomv1beta1.Plan{}, err
+	}
+
+	return datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionUpdate, Changes: datadog.DiffMonitor(spec, live.DatadogMonitorSpec)}, nil
+}
+
+// failPlan records that the monitor couldn't be read from Datadog to plan
+// changes
+func (r *DatadogMonitorReconciler) failPlan(ctx context.Context, log logr.Logger, instance *datadoghqcomv1beta1.DatadogMonitor, err error) error {
+	log.Error(err, "Failed to get monitor to plan changes")
+
+	if setDegraded(&instance.Status.Conditions, "FailedPlan", err) {
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+	}
+
+	return err
+}
+
+// resyncMonitor reads the monitor from Datadog to refresh its state in the
+// status and to detect drift from the spec
+func (r *DatadogMonitorReconciler) resyncMonitor(ctx context.Context, log logr.Logger, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec) error {
+	log.V(1).Info("Resyncing monitor with Datadog")
+
+	live, err := dd.GetMonitor(ctx, instance.Status.Id)
+	if datadog.IsNotFound(err) {
+		return r.monitorNotFound(ctx, log, dd, instance, spec)
+	}
+	if err != nil {
+		log.Error(err, "Failed to get monitor for resync")
+
+		if setDegraded(&instance.Status.Conditions, "FailedResync", err) {
+			if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+				return statusErr
 			}
 		}
 
-		return ctrl.Result{}, nil
+		return err
+	}
+
+	changed := setMonitorState(&instance.Status, live)
+
+	driftChanged, err := r.checkDrift(ctx, log, dd, instance, spec, live.DatadogMonitorSpec)
+	if err != nil {
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+		return err
 	}
 
-	return ctrl.Result{}, nil
+	if !changed && !driftChanged {
+		return nil
+	}
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+// monitorNotFound handles a monitor that was deleted in Datadog outside the
+// controller. It's recreated unless the drift policy is Report, in which case
+// it's only reported as missing.
+func (r *DatadogMonitorReconciler) monitorNotFound(ctx context.Context, log logr.Logger, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec) error {
+	if instance.Spec.DriftPolicy != datadoghqcomv1beta1.DriftPolicyReport {
+		return r.recreateMonitor(ctx, log, dd, instance, spec)
+	}
+
+	err := fmt.Errorf("Monitor %v was deleted in Datadog", instance.Status.Id)
+	if !setSynced(&instance.Status.Conditions, "DeletedInDatadog", err) {
+		return nil
+	}
+
+	log.Info(err.Error())
+	r.Recorder.Eventf(instance, "Warning", "DeletedInDatadog", err.Error())
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+// recreateMonitor creates a monitor again after it was deleted in Datadog
+// outside the controller
+func (r *DatadogMonitorReconciler) recreateMonitor(ctx context.Context, log logr.Logger, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec) error {
+	message := fmt.Sprintf("Monitor %v was deleted in Datadog, recreating it", instance.Status.Id)
+	log.Info(message)
+	r.Recorder.Eventf(instance, "Warning", "Recreating", message)
+	monitorRecreatedCounter.Inc()
+
+	// The ID of the deleted monitor is kept until the new one is created, so
+	// a failed attempt is retried as a recreation and not e.g. as an adoption
+	instance.Status.OverallState = ""
+	instance.Status.GroupStates = nil
+	instance.Status.LastTriggeredTime = nil
+
+	return r.createMonitor(ctx, log, dd, instance, spec)
+}
+
+// checkDrift compares the monitor in Datadog with the resolved spec and,
+// depending on the drift policy, re-applies the spec or only reports the
+// difference. It reports whether the status changed.
+func (r *DatadogMonitorReconciler) checkDrift(ctx context.Context, log logr.Logger, dd datadog.MonitorAPI, instance *datadoghqcomv1beta1.DatadogMonitor, spec datadoghqcomv1beta1.DatadogMonitorSpec, live datadoghqcomv1beta1.DatadogMonitorSpec) (bool, error) {
+	drifted := datadog.DiffMonitor(spec, live)
+	changed := false
+
+	if len(drifted) == 0 {
+		monitorDriftGauge.WithLabelValues(instance.Namespace, instance.Name).Set(0)
+
+		instance.Status.DriftedFields = nil
+		changed = setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionDrifted, false, "InSync", "")
+		changed = setSynced(&instance.Status.Conditions, "InSync", nil) || changed
+	} else if instance.Spec.DriftPolicy == datadoghqcomv1beta1.DriftPolicyReport {
+		monitorDriftGauge.WithLabelValues(instance.Namespace, instance.Name).Set(1)
+
+		if !utils.EqualStrings(instance.Status.DriftedFields, drifted) {
+			log.Info(fmt.Sprintf("Monitor has drifted: %v", strings.Join(drifted, ", ")))
+			r.Recorder.Eventf(instance, "Warning", "Drifted", fmt.Sprintf("Monitor in Datadog differs from spec: %v", strings.Join(drifted, ", ")))
+		}
+
+		message := fmt.Sprintf("Fields differ from spec: %v", strings.Join(drifted, ", "))
+
+		instance.Status.DriftedFields = drifted
+		changed = setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionDrifted, true, "DriftDetected", message)
+		changed = setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, false, "DriftDetected", message) || changed
+		// A drifted monitor isn't in sync with its spec, so it isn't ready
+		changed = setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionReady, false, "Drifted", message) || changed
+		// Drift is reported and not an error of the controller
+		changed = setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded, false, "AsExpected", "") || changed
+	} else {
+		log.Info(fmt.Sprintf("Correcting drifted monitor: %v", strings.Join(drifted, ", ")))
+		monitorDriftGauge.WithLabelValues(instance.Namespace, instance.Name).Set(1)
+
+		if err := dd.UpdateMonitor(ctx, instance.Status.Id, spec, r.owner(instance)); err != nil {
+			log.Error(err, "Failed to correct drifted monitor")
+			r.Recorder.Eventf(instance, "Warning", "FailedDriftCorrection", fmt.Sprint(err))
+
+			instance.Status.DriftedFields = drifted
+			setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionDrifted, true, "FailedDriftCorrection", err.Error())
+			setSynced(&instance.Status.Conditions, "FailedDriftCorrection", err)
+
+			return true, err
+		}
+
+		monitorDriftGauge.WithLabelValues(instance.Namespace, instance.Name).Set(0)
+
+		message := fmt.Sprintf("Re-applied spec to drifted fields: %v", strings.Join(drifted, ", "))
+		r.Recorder.Eventf(instance, "Normal", "DriftCorrected", message)
+
+		instance.Status.DriftedFields = nil
+		changed = setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionDrifted, false, "DriftCorrected", message)
+		changed = setSynced(&instance.Status.Conditions, "DriftCorrected", nil) || changed
+	}
+
+	return changed, nil
+}
+
+// setMonitorState copies the state of the monitor in Datadog to the status
+// and reports whether it changed. Only groups that are not OK are kept so
+// the status stays small for monitors with many groups.
+func setMonitorState(status *datadoghqcomv1beta1.DatadogMonitorStatus, live datadog.Monitor) bool {
+	groupStates := []datadoghqcomv1beta1.DatadogMonitorGroupState{}
+	var lastTriggered int64
+
+	for name, group := range live.State.Groups {
+		if group.LastTriggeredTs > lastTriggered {
+			lastTriggered = group.LastTriggeredTs
+		}
+
+		if group.Status == "OK" || len(groupStates) >= maxGroupStates {
+			continue
+		}
+
+		groupState := datadoghqcomv1beta1.DatadogMonitorGroupState{
+			Name:  name,
+			State: group.Status,
+		}
+		if group.LastTriggeredTs > 0 {
+			triggered := metav1.Unix(group.LastTriggeredTs, 0)
+			groupState.LastTriggeredTime = &triggered
+		}
+
+		groupStates = append(groupStates, groupState)
+	}
+
+	sort.Slice(groupStates, func(i, j int) bool {
+		return groupStates[i].Name < groupStates[j].Name
+	})
+
+	var lastTriggeredTime *metav1.Time
+	if lastTriggered > 0 {
+		triggered := metav1.Unix(lastTriggered, 0)
+		lastTriggeredTime = &triggered
+	}
+
+	if len(groupStates) == 0 {
+		groupStates = nil
+	}
+
+	if status.OverallState == live.OverallState &&
+		reflect.DeepEqual(status.GroupStates, groupStates) &&
+		reflect.DeepEqual(status.LastTriggeredTime, lastTriggeredTime) {
+		return false
+	}
+
+	status.OverallState = live.OverallState
+	status.GroupStates = groupStates
+	status.LastTriggeredTime = lastTriggeredTime
+
+	return true
+}
+
+// updateStatus writes the status back to the cluster through the status subresource
+func (r *DatadogMonitorReconciler) updateStatus(ctx context.Context, log logr.Logger, instance *datadoghqcomv1beta1.DatadogMonitor) error {
+	if err := r.Status().Update(ctx, instance); err != nil {
+		log.Error(err, "Failed to update status")
+		return err
+	}
+
+	return nil
+}
+
+// requestsForReferences maps a changed DatadogMonitor or DatadogSLO to the
+// monitors that reference it, so they are applied as soon as it's created in
+// Datadog. A changed monitor is also mapped to the monitors it references, so
+// their deletion is retried once they are no longer referenced.
+func (r *DatadogMonitorReconciler) requestsForReferences(kind string) handler.ToRequestsFunc {
+	return func(object handler.MapObject) []reconcile.Request {
+		requests := []reconcile.Request{}
+
+		referrers, err := r.referringMonitors(context.Background(), kind, object.Meta.GetNamespace(), object.Meta.GetName())
+		if err != nil {
+			r.Log.Error(err, "Failed to list monitors to find references")
+			return requests
+		}
+
+		for _, monitor := range referrers {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: monitor.Namespace, Name: monitor.Name}})
+		}
+
+		if monitor, ok := object.Object.(*datadoghqcomv1beta1.DatadogMonitor); ok {
+			for _, ref := range queryReferences(monitor.Spec.Query) {
+				if ref.Kind == referenceKindMonitor {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: monitor.Namespace, Name: ref.Name}})
+				}
+			}
+		}
+
+		// Monitors waiting to be deleted are unblocked by changes to the SLOs
+		// using them
+		if slo, ok := object.Object.(*datadoghqcomv1beta1.DatadogSLO); ok {
+			for _, name := range slo.Spec.MonitorRefs {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: slo.Namespace, Name: name}})
+			}
+		}
+
+		return requests
+	}
 }
 
 func (r *DatadogMonitorReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&datadoghqcomv1beta1.DatadogMonitor{}).
+		Watches(&source.Kind{Type: &datadoghqcomv1beta1.DatadogMonitor{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.requestsForReferences(referenceKindMonitor),
+		}).
+		Watches(&source.Kind{Type: &datadoghqcomv1beta1.DatadogSLO{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.requestsForReferences(referenceKindSLO),
+		}).
 		Complete(r)
 }
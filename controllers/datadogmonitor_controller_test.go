@@ -0,0 +1,853 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"testing"
+	"time"
+)
+
+var _ = Describe("DatadogMonitor controller", func() {
+	const timeout = 10 * time.Second
+
+	It("creates, updates and deletes the monitor in Datadog", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Namespace: "default", Name: "test-monitor"}
+
+		instance := &datadoghqcomv1beta1.DatadogMonitor{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Spec: datadoghqcomv1beta1.DatadogMonitorSpec{
+				Name:    "test-monitor",
+				Type:    "metric alert",
+				Query:   "avg(last_5m):avg:system.cpu.user{*} > 90",
+				Message: "CPU is high",
+			},
+		}
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+
+		Eventually(func() int64 {
+			_ = k8sClient.Get(ctx, key, instance)
+			return instance.Status.Id
+		}, timeout).ShouldNot(BeZero())
+		monitorId := instance.Status.Id
+
+		// The status isn't overwritten by adding the finalizer
+		Eventually(func() []string {
+			_ = k8sClient.Get(ctx, key, instance)
+			return instance.Finalizers
+		}, timeout).Should(ContainElement(deletionFinalizer))
+		Expect(instance.Status.ObservedGeneration).To(Equal(instance.Generation))
+
+		monitor, ok := fakeDatadog.Monitor(monitorId)
+		Expect(ok).To(BeTrue())
+		Expect(monitor.Query).To(Equal(instance.Spec.Query))
+
+		Eventually(func() error {
+			if err := k8sClient.Get(ctx, key, instance); err != nil {
+				return err
+			}
+			instance.Spec.Message = "CPU is very high"
+			return k8sClient.Update(ctx, instance)
+		}, timeout).Should(Succeed())
+
+		Eventually(func() string {
+			monitor, _ := fakeDatadog.Monitor(monitorId)
+			return monitor.Message
+		}, timeout).Should(Equal("CPU is very high"))
+
+		Eventually(func() bool {
+			_ = k8sClient.Get(ctx, key, instance)
+			return instance.Generation > 1 && instance.Status.ObservedGeneration == instance.Generation
+		}, timeout).Should(BeTrue())
+		Expect(instance.Finalizers).To(ContainElement(deletionFinalizer))
+
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+
+		Eventually(func() bool {
+			_, ok := fakeDatadog.Monitor(monitorId)
+			return ok
+		}, timeout).Should(BeFalse())
+	})
+
+	It("leaves the monitor in Datadog without its ownership tags with the Orphan deletion policy", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Namespace: "default", Name: "test-orphaned-monitor"}
+
+		instance := &datadoghqcomv1beta1.DatadogMonitor{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Spec: datadoghqcomv1beta1.DatadogMonitorSpec{
+				Name:           "test-orphaned-monitor",
+				Type:           "metric alert",
+				Query:          "avg(last_5m):avg:system.cpu.user{*} > 90",
+				Message:        "CPU is high",
+				Tags:           []string{"team:platform"},
+				DeletionPolicy: datadoghqcomv1beta1.DeletionPolicyOrphan,
+			},
+		}
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+
+		Eventually(func() []string {
+			_ = k8sClient.Get(ctx, key, instance)
+			return instance.Finalizers
+		}, timeout).Should(ContainElement(deletionFinalizer))
+		monitorId := instance.Status.Id
+
+		monitor, ok := fakeDatadog.Monitor(monitorId)
+		Expect(ok).To(BeTrue())
+		Expect(datadog.OwnerOf(monitor).UID).To(Equal(string(instance.UID)))
+
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+
+		// The finalizer is removed without deleting the monitor
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, &datadoghqcomv1beta1.DatadogMonitor{}))
+		}, timeout).Should(BeTrue())
+
+		monitor, ok = fakeDatadog.Monitor(monitorId)
+		Expect(ok).To(BeTrue())
+		Expect(monitor.Tags).To(Equal([]string{"team:platform"}))
+	})
+
+	It("recreates a monitor deleted in Datadog at the next resync", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Namespace: "default", Name: "test-recreated-monitor"}
+
+		instance := &datadoghqcomv1beta1.DatadogMonitor{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   key.Namespace,
+				Name:        key.Name,
+				Annotations: map[string]string{datadoghqcomv1beta1.ResyncPeriodAnnotation: "1s"},
+			},
+			Spec: datadoghqcomv1beta1.DatadogMonitorSpec{
+				Name:    "test-recreated-monitor",
+				Type:    "metric alert",
+				Query:   "avg(last_5m):avg:system.cpu.user{*} > 90",
+				Message: "CPU is high",
+			},
+		}
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+
+		Eventually(func() int64 {
+			_ = k8sClient.Get(ctx, key, instance)
+			return instance.Status.Id
+		}, timeout).ShouldNot(BeZero())
+		deletedId := instance.Status.Id
+
+		fakeDatadog.DeleteMonitor(deletedId)
+
+		Eventually(func() int64 {
+			_ = k8sClient.Get(ctx, key, instance)
+			return instance.Status.Id
+		}, timeout).ShouldNot(Or(BeZero(), Equal(deletedId)))
+
+		_, ok := fakeDatadog.Monitor(instance.Status.Id)
+		Expect(ok).To(BeTrue())
+
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+	})
+
+	It("recreates an adopted monitor deleted in Datadog at the next resync", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Namespace: "default", Name: "test-adopted-recreated-monitor"}
+
+		spec := datadoghqcomv1beta1.DatadogMonitorSpec{
+			Name:    "test-adopted-recreated-monitor",
+			Type:    "metric alert",
+			Query:   "avg(last_5m):avg:system.cpu.user{*} > 90",
+			Message: "CPU is high",
+		}
+		adoptedId := fakeDatadog.CreateMonitor(spec)
+
+		instance := &datadoghqcomv1beta1.DatadogMonitor{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+				Annotations: map[string]string{
+					datadoghqcomv1beta1.AdoptMonitorIdAnnotation: fmt.Sprint(adoptedId),
+					datadoghqcomv1beta1.ResyncPeriodAnnotation:   "1s",
+				},
+			},
+			Spec: spec,
+		}
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+
+		Eventually(func() int64 {
+			_ = k8sClient.Get(ctx, key, instance)
+			return instance.Status.Id
+		}, timeout).Should(Equal(adoptedId))
+
+		// The search for an existing monitor fails, so the first attempts to
+		// recreate the monitor fail too
+		fakeDatadog.SetRateLimit("GET /monitor/search", 0, time.Second)
+		fakeDatadog.DeleteMonitor(adoptedId)
+
+		Eventually(func() int {
+			return fakeDatadog.Requests("GET /monitor/search")
+		}, timeout).ShouldNot(BeZero())
+
+		Consistently(func() int64 {
+			_ = k8sClient.Get(ctx, key, instance)
+			return instance.Status.Id
+		}, 3*time.Second).Should(Equal(adoptedId))
+
+		fakeDatadog.SetRateLimit("GET /monitor/search", 1000, time.Second)
+
+		Eventually(func() int64 {
+			_ = k8sClient.Get(ctx, key, instance)
+			return instance.Status.Id
+		}, timeout).ShouldNot(Or(BeZero(), Equal(adoptedId)))
+
+		_, ok := fakeDatadog.Monitor(instance.Status.Id)
+		Expect(ok).To(BeTrue())
+
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+	})
+
+	It("resolves the monitors a composite references and blocks their deletion", func() {
+		ctx := context.Background()
+		compositeKey := types.NamespacedName{Namespace: "default", Name: "test-composite"}
+		cpuKey := types.NamespacedName{Namespace: "default", Name: "test-composite-cpu"}
+
+		composite := &datadoghqcomv1beta1.DatadogMonitor{
+			ObjectMeta: metav1.ObjectMeta{Namespace: compositeKey.Namespace, Name: compositeKey.Name},
+			Spec: datadoghqcomv1beta1.DatadogMonitorSpec{
+				Name:    "test-composite",
+				Type:    "composite",
+				Query:   "${monitor:test-composite-cpu} && ${monitor:test-composite-cpu}",
+				Message: "CPU is high",
+			},
+		}
+		Expect(k8sClient.Create(ctx, composite)).To(Succeed())
+
+		Eventually(func() string {
+			_ = k8sClient.Get(ctx, compositeKey, composite)
+			synced := datadoghqcomv1beta1.FindCondition(composite.Status.Conditions, datadoghqcomv1beta1.ConditionSynced)
+			if synced == nil {
+				return ""
+			}
+			return synced.Reason
+		}, timeout).Should(Equal("ReferenceNotReady"))
+		Expect(composite.Status.Id).To(BeZero())
+
+		cpu := &datadoghqcomv1beta1.DatadogMonitor{
+			ObjectMeta: metav1.ObjectMeta{Namespace: cpuKey.Namespace, Name: cpuKey.Name},
+			Spec: datadoghqcomv1beta1.DatadogMonitorSpec{
+				Name:    "test-composite-cpu",
+				Type:    "metric alert",
+				Query:   "avg(last_5m):avg:system.cpu.user{*} > 90",
+				Message: "CPU is high",
+			},
+		}
+		Expect(k8sClient.Create(ctx, cpu)).To(Succeed())
+
+		// Applied as soon as the referenced monitor is created, not at the next retry
+		Eventually(func() int64 {
+			_ = k8sClient.Get(ctx, compositeKey, composite)
+			return composite.Status.Id
+		}, timeout).ShouldNot(BeZero())
+		Expect(k8sClient.Get(ctx, cpuKey, cpu)).To(Succeed())
+		cpuId := cpu.Status.Id
+
+		monitor, _ := fakeDatadog.Monitor(composite.Status.Id)
+		Expect(monitor.Query).To(Equal(fmt.Sprintf("%v && %v", cpuId, cpuId)))
+		Expect(composite.Status.ResolvedQuery).To(Equal(monitor.Query))
+
+		Expect(k8sClient.Delete(ctx, cpu)).To(Succeed())
+
+		Eventually(func() string {
+			_ = k8sClient.Get(ctx, cpuKey, cpu)
+			degraded := datadoghqcomv1beta1.FindCondition(cpu.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded)
+			if degraded == nil {
+				return ""
+			}
+			return degraded.Reason
+		}, timeout).Should(Equal("DeletionBlocked"))
+
+		Consistently(func() bool {
+			_, ok := fakeDatadog.Monitor(cpuId)
+			return ok
+		}, 2*time.Second).Should(BeTrue())
+
+		// Deleted once the composite no longer references it
+		Expect(k8sClient.Delete(ctx, composite)).To(Succeed())
+
+		Eventually(func() bool {
+			_, ok := fakeDatadog.Monitor(cpuId)
+			return ok
+		}, timeout).Should(BeFalse())
+	})
+
+	It("renders templates and applies changed labels", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Namespace: "default", Name: "test-templated-monitor"}
+
+		instance := &datadoghqcomv1beta1.DatadogMonitor{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+				Labels:    map[string]string{"app": "web"},
+			},
+			Spec: datadoghqcomv1beta1.DatadogMonitorSpec{
+				Name:    "[[ .Labels.app ]] CPU in [[ .Namespace ]]",
+				Type:    "metric alert",
+				Query:   "avg(last_5m):avg:system.cpu.user{service:[[ .Labels.app ]]} > 90",
+				Message: "CPU is high",
+			},
+		}
+		Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+
+		Eventually(func() int64 {
+			_ = k8sClient.Get(ctx, key, instance)
+			return instance.Status.Id
+		}, timeout).ShouldNot(BeZero())
+		monitorId := instance.Status.Id
+
+		monitor, _ := fakeDatadog.Monitor(monitorId)
+		Expect(monitor.Name).To(Equal("web CPU in default"))
+		Expect(monitor.Query).To(Equal("avg(last_5m):avg:system.cpu.user{service:web} > 90"))
+
+		Eventually(func() error {
+			if err := k8sClient.Get(ctx, key, instance); err != nil {
+				return err
+			}
+			instance.Labels["app"] = "api"
+			return k8sClient.Update(ctx, instance)
+		}, timeout).Should(Succeed())
+
+		Eventually(func() string {
+			monitor, _ := fakeDatadog.Monitor(monitorId)
+			return monitor.Query
+		}, timeout).Should(Equal("avg(last_5m):avg:system.cpu.user{service:api} > 90"))
+
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+	})
+})
+
+func adoptingMonitor(name string, uid types.UID, monitorId int64) *datadoghqcomv1beta1.DatadogMonitor {
+	return &datadoghqcomv1beta1.DatadogMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			UID:         uid,
+			Annotations: map[string]string{datadoghqcomv1beta1.AdoptMonitorIdAnnotation: fmt.Sprint(monitorId)},
+		},
+		Spec: datadoghqcomv1beta1.DatadogMonitorSpec{
+			Name:    name,
+			Type:    "metric alert",
+			Query:   "avg(last_5m):avg:system.cpu.user{*} > 90",
+			Message: "CPU is high",
+		},
+	}
+}
+
+// reconcileMonitor reconciles a DatadogMonitor in the default namespace and
+// returns it as saved afterwards
+func reconcileMonitor(t *testing.T, r *DatadogMonitorReconciler, name string) *datadoghqcomv1beta1.DatadogMonitor {
+	t.Helper()
+
+	instance, err := reconcileMonitorWithError(t, r, name)
+	assert.Nil(t, err)
+
+	return instance
+}
+
+// reconcileMonitorWithError is reconcileMonitor for reconciles that may fail
+func reconcileMonitorWithError(t *testing.T, r *DatadogMonitorReconciler, name string) (*datadoghqcomv1beta1.DatadogMonitor, error) {
+	t.Helper()
+
+	key := types.NamespacedName{Namespace: "default", Name: name}
+	_, err := r.Reconcile(ctrl.Request{NamespacedName: key})
+
+	instance := &datadoghqcomv1beta1.DatadogMonitor{}
+	assert.Nil(t, r.Get(context.Background(), key, instance))
+
+	return instance, err
+}
+
+func TestAdoptMonitorClaimedByStatus(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	owner := adoptingMonitor("owner", "owner-uid", 0)
+	delete(owner.Annotations, datadoghqcomv1beta1.AdoptMonitorIdAnnotation)
+	monitorId := server.CreateMonitor(owner.Spec)
+	owner.Status.Id = monitorId
+
+	r := newTestMonitorReconciler(t, server, owner, adoptingMonitor("adopter", "adopter-uid", monitorId))
+
+	adopter := reconcileMonitor(t, r, "adopter")
+
+	assert.Zero(t, adopter.Status.Id)
+	synced := datadoghqcomv1beta1.FindCondition(adopter.Status.Conditions, datadoghqcomv1beta1.ConditionSynced)
+	assert.Equal(t, "FailedAdopt", synced.Reason)
+	assert.Equal(t, fmt.Sprintf("Monitor %v is already managed by default/owner", monitorId), synced.Message)
+	assert.Zero(t, server.Requests("PUT /monitor/:id"))
+}
+
+func TestAdoptMonitorClaimedByOwnerTag(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	// The status of the owner isn't saved yet, only the monitor is tagged
+	owner := adoptingMonitor("owner", "owner-uid", 0)
+	delete(owner.Annotations, datadoghqcomv1beta1.AdoptMonitorIdAnnotation)
+	spec := owner.Spec
+	spec.Tags = datadog.Owner{Namespace: "default", Name: "owner", UID: "owner-uid"}.Tags()
+	monitorId := server.CreateMonitor(spec)
+
+	r := newTestMonitorReconciler(t, server, owner, adoptingMonitor("adopter", "adopter-uid", monitorId))
+
+	adopter := reconcileMonitor(t, r, "adopter")
+
+	assert.Zero(t, adopter.Status.Id)
+	synced := datadoghqcomv1beta1.FindCondition(adopter.Status.Conditions, datadoghqcomv1beta1.ConditionSynced)
+	assert.Equal(t, "FailedAdopt", synced.Reason)
+	assert.Equal(t, fmt.Sprintf("Monitor %v is already managed by default/owner", monitorId), synced.Message)
+	assert.Zero(t, server.Requests("PUT /monitor/:id"))
+}
+
+func TestAdoptMonitorClaimedWithOtherKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		// Whether the owner uses keys of another organization
+		otherOrganization bool
+		adopted           bool
+	}{
+		{"other keys of the same organization", false, false},
+		{"keys of another organization", true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := fake.NewServer()
+			defer server.Close()
+			teamServer := server
+			if test.otherOrganization {
+				teamServer = fake.NewServer()
+				defer teamServer.Close()
+			}
+
+			credentials, secret := teamCredentials("team-a", "")
+			owner := adoptingMonitor("owner", "owner-uid", 0)
+			delete(owner.Annotations, datadoghqcomv1beta1.AdoptMonitorIdAnnotation)
+			owner.Spec.CredentialsRef = "team-a"
+			owner.Status.Id = teamServer.CreateMonitor(owner.Spec)
+			monitorId := owner.Status.Id
+			if test.otherOrganization {
+				// The same ID in another organization is another monitor
+				monitorId = server.CreateMonitor(owner.Spec)
+				assert.Equal(t, owner.Status.Id, monitorId)
+			}
+
+			r := newTestMonitorReconciler(t, server, credentials, secret, owner, adoptingMonitor("adopter", "adopter-uid", monitorId))
+			r.Datadog.(*DatadogClients).Options = []datadog.Option{datadog.WithBaseURL(teamServer.URL)}
+
+			adopter := reconcileMonitor(t, r, "adopter")
+
+			if test.adopted {
+				assert.Equal(t, monitorId, adopter.Status.Id)
+			} else {
+				assert.Zero(t, adopter.Status.Id)
+				assertCondition(t, adopter.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "FailedAdopt")
+				synced := datadoghqcomv1beta1.FindCondition(adopter.Status.Conditions, datadoghqcomv1beta1.ConditionSynced)
+				assert.Equal(t, fmt.Sprintf("Monitor %v is already managed by default/owner", monitorId), synced.Message)
+				assert.Zero(t, server.Requests("PUT /monitor/:id"))
+			}
+		})
+	}
+}
+
+func TestAdoptMonitorTaggedByDeletedResource(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	spec := adoptingMonitor("deleted", "", 0).Spec
+	spec.Tags = datadog.Owner{Namespace: "default", Name: "deleted", UID: "deleted-uid"}.Tags()
+	monitorId := server.CreateMonitor(spec)
+
+	r := newTestMonitorReconciler(t, server, adoptingMonitor("adopter", "adopter-uid", monitorId))
+
+	adopter := reconcileMonitor(t, r, "adopter")
+
+	assert.Equal(t, monitorId, adopter.Status.Id)
+	monitor, _ := server.Monitor(monitorId)
+	assert.Equal(t, "adopter-uid", datadog.OwnerOf(monitor).UID)
+}
+
+func TestMonitorConditions(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	instance := adoptingMonitor("conditions", "conditions-uid", 0)
+	delete(instance.Annotations, datadoghqcomv1beta1.AdoptMonitorIdAnnotation)
+	invalid := adoptingMonitor("invalid", "invalid-uid", 0)
+	delete(invalid.Annotations, datadoghqcomv1beta1.AdoptMonitorIdAnnotation)
+	invalid.Spec.Name = ""
+
+	r := newTestMonitorReconciler(t, server, instance, invalid)
+	r.ResyncPeriod = time.Minute
+
+	instance = reconcileMonitor(t, r, "conditions")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionTrue, "Created")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionTrue, "Created")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionFalse, "Created")
+
+	invalid = reconcileMonitor(t, r, "invalid")
+	assertCondition(t, invalid.Status.Conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionFalse, "FailedCreate")
+	assertCondition(t, invalid.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "FailedCreate")
+	assertCondition(t, invalid.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionTrue, "FailedCreate")
+
+	// Changed in Datadog outside the controller
+	dd := r.Datadog.(*DatadogClients).Default
+	edited := instance.Spec
+	edited.Message = "Edited in the Datadog app"
+	assert.Nil(t, dd.UpdateMonitor(context.Background(), instance.Status.Id, edited, r.owner(instance)))
+
+	instance.Spec.DriftPolicy = datadoghqcomv1beta1.DriftPolicyReport
+	assert.Nil(t, r.Update(context.Background(), instance))
+
+	instance = reconcileMonitor(t, r, "conditions")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionDrifted, metav1.ConditionTrue, "DriftDetected")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionFalse, "Drifted")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "DriftDetected")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionFalse, "AsExpected")
+
+	drifted := *datadoghqcomv1beta1.FindCondition(instance.Status.Conditions, datadoghqcomv1beta1.ConditionDrifted)
+	instance = reconcileMonitor(t, r, "conditions")
+	assert.Equal(t, drifted, *datadoghqcomv1beta1.FindCondition(instance.Status.Conditions, datadoghqcomv1beta1.ConditionDrifted))
+
+	instance.Spec.DriftPolicy = datadoghqcomv1beta1.DriftPolicyCorrect
+	assert.Nil(t, r.Update(context.Background(), instance))
+
+	instance = reconcileMonitor(t, r, "conditions")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionDrifted, metav1.ConditionFalse, "DriftCorrected")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionTrue, "DriftCorrected")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionTrue, "DriftCorrected")
+
+	// The keys of the controller are no longer accepted
+	server.APIKey = "rotated-api-key"
+	now := metav1.Now()
+	instance.DeletionTimestamp = &now
+	assert.Nil(t, r.Update(context.Background(), instance))
+
+	instance, err := reconcileMonitorWithError(t, r, "conditions")
+	assert.NotNil(t, err)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionFalse, "FailedDelete")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionTrue, "FailedDelete")
+
+	instance.Spec.DeletionPolicy = datadoghqcomv1beta1.DeletionPolicyOrphan
+	assert.Nil(t, r.Update(context.Background(), instance))
+
+	instance, err = reconcileMonitorWithError(t, r, "conditions")
+	assert.NotNil(t, err)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionFalse, "FailedOrphan")
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionTrue, "FailedOrphan")
+}
+
+func TestBlockDeletion(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	cpuId := server.CreateMonitor(datadoghqcomv1beta1.DatadogMonitorSpec{Name: "cpu", Type: "metric alert", Query: "avg(last_5m):avg:system.cpu.user{*} > 90"})
+	now := metav1.Now()
+	cpu := referencedMonitor("cpu", cpuId, "avg(last_5m):avg:system.cpu.user{*} > 90")
+	cpu.Finalizers = []string{deletionFinalizer}
+	cpu.DeletionTimestamp = &now
+	composite := referencedMonitor("composite", 0, "${monitor:cpu} && ${monitor:memory}")
+
+	r := newTestMonitorReconciler(t, server, cpu, composite)
+
+	cpu = reconcileMonitor(t, r, "cpu")
+	assertCondition(t, cpu.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionTrue, "DeletionBlocked")
+	assert.Equal(t, "Monitor is referenced by composite", datadoghqcomv1beta1.FindCondition(cpu.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded).Message)
+	assert.Contains(t, cpu.Finalizers, deletionFinalizer)
+	_, ok := server.Monitor(cpuId)
+	assert.True(t, ok)
+
+	// A composite being deleted doesn't block its references
+	composite.Finalizers = []string{deletionFinalizer}
+	composite.DeletionTimestamp = &now
+	assert.Nil(t, r.Update(context.Background(), composite))
+
+	cpu = reconcileMonitor(t, r, "cpu")
+	assert.NotContains(t, cpu.Finalizers, deletionFinalizer)
+	_, ok = server.Monitor(cpuId)
+	assert.False(t, ok)
+}
+
+func TestBlockDeletionBySLO(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	cpuId := server.CreateMonitor(datadoghqcomv1beta1.DatadogMonitorSpec{Name: "cpu", Type: "metric alert", Query: "avg(last_5m):avg:system.cpu.user{*} > 90"})
+	now := metav1.Now()
+	cpu := referencedMonitor("cpu", cpuId, "avg(last_5m):avg:system.cpu.user{*} > 90")
+	cpu.Finalizers = []string{deletionFinalizer}
+	cpu.DeletionTimestamp = &now
+	slo := &datadoghqcomv1beta1.DatadogSLO{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "availability", Finalizers: []string{deletionFinalizer}},
+		Spec:       datadoghqcomv1beta1.DatadogSLOSpec{Name: "availability", Type: "monitor", MonitorRefs: []string{"cpu"}},
+	}
+
+	r := newTestMonitorReconciler(t, server, cpu, slo)
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	cpu = reconcileMonitor(t, r, "cpu")
+	assertCondition(t, cpu.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionTrue, "DeletionBlocked")
+	assert.Equal(t, "Monitor is referenced by availability", datadoghqcomv1beta1.FindCondition(cpu.Status.Conditions, datadoghqcomv1beta1.ConditionDegraded).Message)
+	assert.Equal(t, "Warning DeletionBlocked Monitor is referenced by availability", <-recorder.Events)
+	assert.Contains(t, cpu.Finalizers, deletionFinalizer)
+
+	// An SLO being deleted still blocks until it's gone
+	slo.DeletionTimestamp = &now
+	assert.Nil(t, r.Update(context.Background(), slo))
+
+	cpu = reconcileMonitor(t, r, "cpu")
+	assert.Contains(t, cpu.Finalizers, deletionFinalizer)
+	_, ok := server.Monitor(cpuId)
+	assert.True(t, ok)
+
+	assert.Nil(t, r.Delete(context.Background(), slo))
+
+	cpu = reconcileMonitor(t, r, "cpu")
+	assert.NotContains(t, cpu.Finalizers, deletionFinalizer)
+	_, ok = server.Monitor(cpuId)
+	assert.False(t, ok)
+}
+
+func TestReferenceToOtherCredentials(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	other := referencedMonitor("other-org", 11, "avg(last_5m):avg:system.cpu.user{*} > 90")
+	other.Spec.CredentialsRef = "team-a"
+	composite := referencedMonitor("composite", 0, "${monitor:other-org} && ${monitor:other-org}")
+
+	r := newTestMonitorReconciler(t, server, other, composite)
+
+	key := types.NamespacedName{Namespace: "default", Name: "composite"}
+	result, err := r.Reconcile(ctrl.Request{NamespacedName: key})
+	assert.Nil(t, err)
+	assert.Zero(t, result.RequeueAfter)
+
+	assert.Nil(t, r.Get(context.Background(), key, composite))
+	assert.Zero(t, composite.Status.Id)
+	assertCondition(t, composite.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "InvalidReference")
+	assert.Empty(t, server.Monitors())
+}
+
+func TestPlanMonitor(t *testing.T) {
+	tests := []struct {
+		name string
+		// Prepares the resource and the monitor in Datadog
+		setup   func(instance *datadoghqcomv1beta1.DatadogMonitor, server *fake.Server, owned datadoghqcomv1beta1.DatadogMonitorSpec)
+		plan    datadoghqcomv1beta1.Plan
+		lookups int
+		drifted []string
+	}{
+		{
+			name: "creates a new monitor",
+			setup: func(instance *datadoghqcomv1beta1.DatadogMonitor, server *fake.Server, owned datadoghqcomv1beta1.DatadogMonitorSpec) {
+			},
+			plan:    datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionCreate},
+			lookups: 1,
+		},
+		{
+			name: "reuses the monitor found by its ownership tags",
+			setup: func(instance *datadoghqcomv1beta1.DatadogMonitor, server *fake.Server, owned datadoghqcomv1beta1.DatadogMonitorSpec) {
+				owned.Message = "Created before the ID was saved"
+				server.CreateMonitor(owned)
+			},
+			plan:    datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionUpdate, Changes: []string{"message"}},
+			lookups: 1,
+		},
+		{
+			name: "updates the monitor for a new spec",
+			setup: func(instance *datadoghqcomv1beta1.DatadogMonitor, server *fake.Server, owned datadoghqcomv1beta1.DatadogMonitorSpec) {
+				instance.Status.Id = server.CreateMonitor(owned)
+				instance.Spec.Query = "avg(last_5m):avg:system.cpu.user{*} > 95"
+				instance.Generation = 2
+			},
+			plan: datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionUpdate, Changes: []string{"query"}},
+		},
+		{
+			name: "only reports drift under Report",
+			setup: func(instance *datadoghqcomv1beta1.DatadogMonitor, server *fake.Server, owned datadoghqcomv1beta1.DatadogMonitorSpec) {
+				owned.Message = "Edited in the Datadog app"
+				instance.Status.Id = server.CreateMonitor(owned)
+				instance.Spec.DriftPolicy = datadoghqcomv1beta1.DriftPolicyReport
+			},
+			plan:    datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionNone},
+			drifted: []string{"message"},
+		},
+		{
+			name: "corrects drift under Correct",
+			setup: func(instance *datadoghqcomv1beta1.DatadogMonitor, server *fake.Server, owned datadoghqcomv1beta1.DatadogMonitorSpec) {
+				owned.Message = "Edited in the Datadog app"
+				instance.Status.Id = server.CreateMonitor(owned)
+				instance.Spec.DriftPolicy = datadoghqcomv1beta1.DriftPolicyCorrect
+			},
+			plan: datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionUpdate, Changes: []string{"message"}},
+		},
+		{
+			name: "only reports a monitor deleted in Datadog under Report",
+			setup: func(instance *datadoghqcomv1beta1.DatadogMonitor, server *fake.Server, owned datadoghqcomv1beta1.DatadogMonitorSpec) {
+				instance.Status.Id = server.CreateMonitor(owned)
+				server.DeleteMonitor(instance.Status.Id)
+				instance.Spec.DriftPolicy = datadoghqcomv1beta1.DriftPolicyReport
+			},
+			plan: datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionNone},
+		},
+		{
+			name: "recreates a monitor deleted in Datadog under Correct",
+			setup: func(instance *datadoghqcomv1beta1.DatadogMonitor, server *fake.Server, owned datadoghqcomv1beta1.DatadogMonitorSpec) {
+				instance.Status.Id = server.CreateMonitor(owned)
+				server.DeleteMonitor(instance.Status.Id)
+			},
+			plan:    datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionCreate},
+			lookups: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := fake.NewServer()
+			defer server.Close()
+
+			instance := adoptingMonitor("plan", "plan-uid", 0)
+			delete(instance.Annotations, datadoghqcomv1beta1.AdoptMonitorIdAnnotation)
+			instance.Annotations[datadoghqcomv1beta1.DryRunAnnotation] = "true"
+			instance.Generation = 1
+			instance.Status.ObservedGeneration = 1
+
+			r := newTestMonitorReconciler(t, server)
+			r.ResyncPeriod = time.Minute
+
+			owned := instance.Spec
+			owned.Tags = r.owner(instance).Tags()
+			test.setup(instance, server, owned)
+			assert.Nil(t, r.Create(context.Background(), instance))
+
+			instance = reconcileMonitor(t, r, "plan")
+
+			assert.Equal(t, &test.plan, instance.Status.Plan)
+			assert.Equal(t, test.drifted, instance.Status.DriftedFields)
+			assert.Equal(t, test.lookups, server.Requests("GET /monitor"))
+			assert.Zero(t, server.Requests("POST /monitor"))
+			assert.Zero(t, server.Requests("PUT /monitor/:id"))
+		})
+	}
+}
+
+func TestPlanMonitorDeletion(t *testing.T) {
+	for _, policy := range []string{datadoghqcomv1beta1.DeletionPolicyDelete, datadoghqcomv1beta1.DeletionPolicyOrphan} {
+		t.Run(policy, func(t *testing.T) {
+			server := fake.NewServer()
+			defer server.Close()
+
+			instance := adoptingMonitor("plan", "plan-uid", 0)
+			delete(instance.Annotations, datadoghqcomv1beta1.AdoptMonitorIdAnnotation)
+			instance.Finalizers = []string{deletionFinalizer}
+			instance.Spec.DeletionPolicy = policy
+
+			r := newTestMonitorReconciler(t, server)
+			r.DryRun = true
+
+			owned := instance.Spec
+			owned.Tags = r.owner(instance).Tags()
+			instance.Status.Id = server.CreateMonitor(owned)
+			assert.Nil(t, r.Create(context.Background(), instance))
+
+			assert.Nil(t, r.deleteMonitor(context.Background(), r.Log, r.Datadog.(*DatadogClients).Default, instance))
+
+			// The monitor is left untouched in Datadog either way
+			live, ok := server.Monitor(instance.Status.Id)
+			assert.True(t, ok)
+			assert.Equal(t, owned.Tags, live.Tags)
+			assert.Zero(t, server.Requests("DELETE /monitor/:id"))
+			assert.Zero(t, server.Requests("PUT /monitor/:id"))
+			assert.Empty(t, instance.Finalizers)
+
+			if policy == datadoghqcomv1beta1.DeletionPolicyOrphan {
+				assert.Nil(t, instance.Status.Plan)
+			} else {
+				assert.Equal(t, &datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionDelete}, instance.Status.Plan)
+			}
+		})
+	}
+}
+
+func TestDeletionPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		// The policy in the spec and the controller-wide default
+		spec       string
+		controller string
+		deleted    bool
+	}{
+		{"defaults to Delete", "", "", true},
+		{"uses the controller default", "", datadoghqcomv1beta1.DeletionPolicyOrphan, false},
+		{"prefers the spec to the controller default", datadoghqcomv1beta1.DeletionPolicyDelete, datadoghqcomv1beta1.DeletionPolicyOrphan, true},
+		{"orphans with Orphan in the spec", datadoghqcomv1beta1.DeletionPolicyOrphan, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := fake.NewServer()
+			defer server.Close()
+
+			instance := adoptingMonitor("deleted", "deleted-uid", 0)
+			delete(instance.Annotations, datadoghqcomv1beta1.AdoptMonitorIdAnnotation)
+			instance.Finalizers = []string{deletionFinalizer}
+			instance.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			instance.Spec.DeletionPolicy = test.spec
+
+			owned := instance.Spec
+			owned.Tags = []string{"team:platform"}
+			owned.Tags = append(owned.Tags, datadog.Owner{Namespace: "default", Name: "deleted", UID: "deleted-uid"}.Tags()...)
+			instance.Status.Id = server.CreateMonitor(owned)
+
+			r := newTestMonitorReconciler(t, server, instance)
+			r.DeletionPolicy = test.controller
+
+			instance = reconcileMonitor(t, r, "deleted")
+			assert.Empty(t, instance.Finalizers)
+
+			monitor, ok := server.Monitor(instance.Status.Id)
+			assert.Equal(t, !test.deleted, ok)
+			if test.deleted {
+				assert.Equal(t, 1, server.Requests("DELETE /monitor/:id"))
+			} else {
+				assert.Zero(t, server.Requests("DELETE /monitor/:id"))
+				assert.Equal(t, []string{"team:platform"}, monitor.Tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,425 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/go-logr/logr"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"strings"
+)
+
+// The longest name of a generated DatadogMonitor
+const maxMonitorNameLength = 253
+
+// DatadogMonitorTemplateReconciler reconciles a DatadogMonitorTemplate object
+// by generating a DatadogMonitor for each namespace, Deployment or Service it
+// selects
+type DatadogMonitorTemplateReconciler struct {
+	client.Client
+	stopContext
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// The name of the cluster, for the templates in monitor specs
+	ClusterName string
+	// Variables for the templates in monitor specs
+	TemplateVars map[string]string
+}
+
+// templateTarget is a namespace, Deployment or Service a DatadogMonitor is
+// generated for
+type templateTarget struct {
+	namespace string
+	meta      metav1.ObjectMeta
+}
+
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogmonitortemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogmonitortemplates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogmonitors,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces;services,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *DatadogMonitorTemplateReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx, cancel := r.newContext()
+	defer cancel()
+
+	log := r.Log.WithValues("template", req.Name)
+
+	instance := &datadoghqcomv1beta1.DatadogMonitorTemplate{}
+
+	log.V(1).Info("Getting resource from cluster")
+	if err := r.Get(ctx, req.NamespacedName, instance); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// The generated monitors are deleted by Kubernetes through their owner
+	// reference
+	if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	targets, err := r.targets(ctx, instance)
+	if err != nil {
+		log.Error(err, "Failed to list targets")
+		if setDegraded(&instance.Status.Conditions, "FailedList", err) {
+			if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+				return ctrl.Result{}, statusErr
+			}
+		}
+		return ctrl.Result{}, err
+	}
+
+	existing := &datadoghqcomv1beta1.DatadogMonitorList{}
+	if err := r.List(ctx, existing, client.MatchingLabels{datadoghqcomv1beta1.MonitorTemplateLabel: instance.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	owned := map[types.NamespacedName]*datadoghqcomv1beta1.DatadogMonitor{}
+	for i := range existing.Items {
+		if isOwnedBy(&existing.Items[i], instance) {
+			owned[types.NamespacedName{Namespace: existing.Items[i].Namespace, Name: existing.Items[i].Name}] = &existing.Items[i]
+		}
+	}
+
+	// Monitors that failed to render are kept as they are
+	keep := map[types.NamespacedName]bool{}
+	generated := 0
+	errs := []string{}
+	reason := "Generated"
+	// Whether an error could go away by retrying
+	retry := false
+
+	for _, target := range targets {
+		key := types.NamespacedName{Namespace: target.namespace, Name: generatedMonitorName(instance.Name, instance.Spec.Target, target.meta.Name)}
+		keep[key] = true
+
+		spec, err := instance.Spec.Monitor.Render(datadoghqcomv1beta1.TemplateData{
+			Namespace:   target.namespace,
+			Name:        target.meta.Name,
+			Labels:      target.meta.Labels,
+			Annotations: target.meta.Annotations,
+			Cluster:     r.ClusterName,
+			Vars:        r.TemplateVars,
+		})
+		if err != nil {
+			reason = "FailedTemplate"
+			errs = append(errs, fmt.Sprintf("%v: %v", key, err))
+			if owned[key] != nil {
+				generated++
+			}
+			continue
+		}
+
+		err = r.applyMonitor(ctx, log, instance, key, spec, owned[key])
+		if apierrors.IsAlreadyExists(err) {
+			// Not retried as it fails the same way until the template or the
+			// target changes, or the DatadogMonitor is deleted, see
+			// requestsForDeletedMonitor
+			message := fmt.Sprintf("A DatadogMonitor %v not generated from this template already exists", key)
+			log.Info(message)
+			r.Recorder.Eventf(instance, "Warning", "MonitorExists", message)
+			reason = "MonitorExists"
+			errs = append(errs, message)
+			continue
+		}
+		if err != nil {
+			reason = "FailedGenerate"
+			retry = true
+			errs = append(errs, fmt.Sprintf("%v: %v", key, err))
+			continue
+		}
+		generated++
+	}
+
+	for key, monitor := range owned {
+		if keep[key] {
+			continue
+		}
+
+		log.Info(fmt.Sprintf("Deleting monitor %v as it's no longer selected", key))
+		if err := r.Delete(ctx, monitor); err != nil && !apierrors.IsNotFound(err) {
+			reason = "FailedDelete"
+			retry = true
+			errs = append(errs, fmt.Sprintf("%v: %v", key, err))
+			continue
+		}
+		r.Recorder.Eventf(instance, "Normal", "SuccessfulDelete", fmt.Sprintf("Deleted monitor %v as it's no longer selected", key))
+	}
+
+	var generateErr error
+	if len(errs) > 0 {
+		generateErr = fmt.Errorf("%v", strings.Join(errs, ", "))
+		log.Info(fmt.Sprintf("Failed to generate monitors: %v", generateErr))
+	}
+
+	statusChanged := setSynced(&instance.Status.Conditions, reason, generateErr)
+	if statusChanged && generateErr != nil {
+		r.Recorder.Eventf(instance, "Warning", reason, generateErr.Error())
+	}
+	if statusChanged || instance.Status.ObservedGeneration != instance.ObjectMeta.Generation || instance.Status.Monitors != int32(generated) {
+		instance.Status.ObservedGeneration = instance.ObjectMeta.Generation
+		instance.Status.Monitors = int32(generated)
+		if err := r.updateStatus(ctx, log, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Templates that can't be rendered fail the same way until the template
+	// or a target changes, which reconciles it again
+	if !retry {
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, generateErr
+}
+
+// targets returns the namespaces, Deployments or Services the template
+// selects
+func (r *DatadogMonitorTemplateReconciler) targets(ctx context.Context, instance *datadoghqcomv1beta1.DatadogMonitorTemplate) ([]templateTarget, error) {
+	namespaceSelector, err := selectorFor(instance.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: namespaceSelector}); err != nil {
+		return nil, err
+	}
+
+	selector, err := selectorFor(instance.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []templateTarget{}
+	for _, namespace := range namespaces.Items {
+		// Nothing can be created in a namespace being deleted
+		if namespace.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+
+		switch instance.Spec.Target {
+		case datadoghqcomv1beta1.MonitorTemplateTargetDeployment:
+			deployments := &appsv1.DeploymentList{}
+			if err := r.List(ctx, deployments, client.InNamespace(namespace.Name), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+				return nil, err
+			}
+			for _, deployment := range deployments.Items {
+				targets = append(targets, templateTarget{namespace: namespace.Name, meta: deployment.ObjectMeta})
+			}
+		case datadoghqcomv1beta1.MonitorTemplateTargetService:
+			services := &corev1.ServiceList{}
+			if err := r.List(ctx, services, client.InNamespace(namespace.Name), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+				return nil, err
+			}
+			for _, service := range services.Items {
+				targets = append(targets, templateTarget{namespace: namespace.Name, meta: service.ObjectMeta})
+			}
+		default:
+			targets = append(targets, templateTarget{namespace: namespace.Name, meta: namespace.ObjectMeta})
+		}
+	}
+
+	return targets, nil
+}
+
+// applyMonitor creates the generated monitor or updates it if it differs
+// from the template
+func (r *DatadogMonitorTemplateReconciler) applyMonitor(ctx context.Context, log logr.Logger, instance *datadoghqcomv1beta1.DatadogMonitorTemplate, key types.NamespacedName, spec datadoghqcomv1beta1.DatadogMonitorSpec, monitor *datadoghqcomv1beta1.DatadogMonitor) error {
+	if monitor == nil {
+		monitor = &datadoghqcomv1beta1.DatadogMonitor{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+				Labels:    map[string]string{datadoghqcomv1beta1.MonitorTemplateLabel: instance.Name},
+			},
+			Spec: spec,
+		}
+		if err := ctrl.SetControllerReference(instance, monitor, r.Scheme); err != nil {
+			return err
+		}
+
+		log.Info(fmt.Sprintf("Creating monitor %v", key))
+		if err := r.Create(ctx, monitor); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(instance, "Normal", "SuccessfulCreate", fmt.Sprintf("Created monitor %v", key))
+
+		return nil
+	}
+
+	if equality.Semantic.DeepEqual(monitor.Spec, spec) {
+		return nil
+	}
+
+	log.Info(fmt.Sprintf("Updating monitor %v", key))
+	monitor.Spec = spec
+	if err := r.Update(ctx, monitor); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(instance, "Normal", "SuccessfulUpdate", fmt.Sprintf("Updated monitor %v", key))
+
+	return nil
+}
+
+func (r *DatadogMonitorTemplateReconciler) updateStatus(ctx context.Context, log logr.Logger, instance *datadoghqcomv1beta1.DatadogMonitorTemplate) error {
+	if err := r.Status().Update(ctx, instance); err != nil {
+		log.Error(err, "Failed to update status")
+		return err
+	}
+
+	return nil
+}
+
+// selectorFor converts a label selector, where nil selects everything
+func selectorFor(selector *metav1.LabelSelector) (labels.Selector, error) {
+	if selector == nil {
+		return labels.Everything(), nil
+	}
+
+	return metav1.LabelSelectorAsSelector(selector)
+}
+
+// isOwnedBy reports whether a monitor was generated from the template
+func isOwnedBy(monitor *datadoghqcomv1beta1.DatadogMonitor, instance *datadoghqcomv1beta1.DatadogMonitorTemplate) bool {
+	owner := metav1.GetControllerOf(monitor)
+	return owner != nil && owner.UID == instance.UID
+}
+
+// generatedMonitorName returns the name of the DatadogMonitor generated from
+// a template for a target
+func generatedMonitorName(template string, target string, targetName string) string {
+	if target == datadoghqcomv1beta1.MonitorTemplateTargetDeployment || target == datadoghqcomv1beta1.MonitorTemplateTargetService {
+		return shortenMonitorName(template + "-" + targetName)
+	}
+
+	return template
+}
+
+// shortenMonitorName shortens the name of a generated DatadogMonitor that is
+// too long with a hash, so it stays unique
+func shortenMonitorName(name string) string {
+	if len(name) <= maxMonitorNameLength {
+		return name
+	}
+
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))[:10]
+	return name[:maxMonitorNameLength-len(hash)-1] + "-" + hash
+}
+
+// requestsForTargets maps a changed namespace, Deployment or Service to the
+// templates that could select it
+func (r *DatadogMonitorTemplateReconciler) requestsForTargets(target string) handler.ToRequestsFunc {
+	return func(object handler.MapObject) []reconcile.Request {
+		requests := []reconcile.Request{}
+
+		templates := &datadoghqcomv1beta1.DatadogMonitorTemplateList{}
+		if err := r.List(context.Background(), templates); err != nil {
+			r.Log.Error(err, "Failed to list templates")
+			return requests
+		}
+
+		for _, template := range templates.Items {
+			// Namespaces are selected by every template
+			templateTarget := template.Spec.Target
+			if templateTarget == "" {
+				templateTarget = datadoghqcomv1beta1.MonitorTemplateTargetNamespace
+			}
+			if target == datadoghqcomv1beta1.MonitorTemplateTargetNamespace || target == templateTarget {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: template.Name}})
+			}
+		}
+
+		return requests
+	}
+}
+
+// requestsForDeletedMonitor maps a deleted DatadogMonitor to the templates
+// that could generate a monitor with its name, so a monitor skipped because
+// one with its name already existed is generated once that one is gone
+func (r *DatadogMonitorTemplateReconciler) requestsForDeletedMonitor(e event.DeleteEvent, queue workqueue.RateLimitingInterface) {
+	templates := &datadoghqcomv1beta1.DatadogMonitorTemplateList{}
+	if err := r.List(context.Background(), templates); err != nil {
+		r.Log.Error(err, "Failed to list templates")
+		return
+	}
+
+	name := e.Meta.GetName()
+	for _, template := range templates.Items {
+		if name == template.Name || strings.HasPrefix(name, template.Name+"-") {
+			queue.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: template.Name}})
+		}
+	}
+}
+
+// targetChanged filters out the frequent status updates of namespaces,
+// Deployments and Services, only their metadata and spec are used by templates
+func targetChanged(e event.UpdateEvent) bool {
+	switch e.ObjectNew.(type) {
+	case *corev1.Namespace, *appsv1.Deployment, *corev1.Service:
+		return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() ||
+			!reflect.DeepEqual(e.MetaOld.GetAnnotations(), e.MetaNew.GetAnnotations()) ||
+			!reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels())
+	}
+
+	return true
+}
+
+func (r *DatadogMonitorTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&datadoghqcomv1beta1.DatadogMonitorTemplate{}).
+		Owns(&datadoghqcomv1beta1.DatadogMonitor{}).
+		Watches(&source.Kind{Type: &datadoghqcomv1beta1.DatadogMonitor{}}, handler.Funcs{
+			DeleteFunc: r.requestsForDeletedMonitor,
+		}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.requestsForTargets(datadoghqcomv1beta1.MonitorTemplateTargetNamespace),
+		}).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.requestsForTargets(datadoghqcomv1beta1.MonitorTemplateTargetDeployment),
+		}).
+		Watches(&source.Kind{Type: &corev1.Service{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.requestsForTargets(datadoghqcomv1beta1.MonitorTemplateTargetService),
+		}).
+		WithEventFilter(predicate.Funcs{UpdateFunc: targetChanged}).
+		Complete(r)
+}
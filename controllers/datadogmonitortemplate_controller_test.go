@@ -0,0 +1,81 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"time"
+)
+
+var _ = Describe("DatadogMonitorTemplate controller", func() {
+	const timeout = 10 * time.Second
+
+	It("generates a monitor for each selected namespace", func() {
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-template-team", Labels: map[string]string{"baseline-alerts": "true", "team": "payments"}},
+		}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+		template := &datadoghqcomv1beta1.DatadogMonitorTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-template"},
+			Spec: datadoghqcomv1beta1.DatadogMonitorTemplateSpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"baseline-alerts": "true"}},
+				Monitor: datadoghqcomv1beta1.DatadogMonitorSpec{
+					Name:    "[[ .Labels.team ]] pods restarting",
+					Type:    "metric alert",
+					Query:   "max(last_10m):sum:kubernetes.containers.restarts{kube_namespace:[[ .Namespace ]]} > 5",
+					Message: "Pods are restarting",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, template)).To(Succeed())
+
+		key := types.NamespacedName{Namespace: namespace.Name, Name: template.Name}
+		monitor := &datadoghqcomv1beta1.DatadogMonitor{}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, key, monitor)
+		}, timeout).Should(Succeed())
+
+		Expect(monitor.Spec.Name).To(Equal("payments pods restarting"))
+		Expect(monitor.Spec.Query).To(Equal("max(last_10m):sum:kubernetes.containers.restarts{kube_namespace:test-template-team} > 5"))
+		Expect(monitor.Labels[datadoghqcomv1beta1.MonitorTemplateLabel]).To(Equal(template.Name))
+
+		Eventually(func() error {
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: namespace.Name}, namespace); err != nil {
+				return err
+			}
+			delete(namespace.Labels, "baseline-alerts")
+			return k8sClient.Update(ctx, namespace)
+		}, timeout).Should(Succeed())
+
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, key, monitor)
+			return apierrors.IsNotFound(err) || !monitor.DeletionTimestamp.IsZero()
+		}, timeout).Should(BeTrue())
+
+		Expect(k8sClient.Delete(ctx, template)).To(Succeed())
+	})
+})
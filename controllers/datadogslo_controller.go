@@ -0,0 +1,338 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/max-rocket-internet/datadog-controller/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"time"
+)
+
+// DatadogSLOReconciler reconciles a DatadogSLO object
+type DatadogSLOReconciler struct {
+	client.Client
+	stopContext
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Datadog  SLOClients
+	// How long to wait before applying a spec Datadog rejected again. 0
+	// disables it.
+	ResyncPeriod time.Duration
+	// Only plan changes to SLOs in Datadog without making them
+	DryRun bool
+}
+
+const (
+	sloDeletionFinalizer = "datadogslos.finalizers.datadoghq.com"
+)
+
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogslos,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogslos/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogmonitors,verbs=get;list;watch
+
+func (r *DatadogSLOReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx, cancel := r.newContext()
+	defer cancel()
+
+	log := r.Log.WithValues("slo", req.NamespacedName)
+
+	instance := &datadoghqcomv1beta1.DatadogSLO{}
+
+	log.V(1).Info("Getting resource from cluster")
+	err := r.Get(ctx, req.NamespacedName, instance)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	dd, err := r.Datadog.SLOsFor(ctx, r, instance.Namespace, instance.Spec.CredentialsRef)
+	updateStatus := func() error { return r.updateStatus(ctx, log, instance) }
+	if result, done, err := resultForClientError(log, err, instance, instance.Status.Id != "", &instance.Status.Conditions, updateStatus); done {
+		return result, err
+	}
+
+	if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+		return resultForError(log, r.deleteSLO(ctx, log, dd, instance))
+	}
+
+	monitorIds, err := r.resolveMonitorIds(ctx, instance)
+	if isReferenceError(err) {
+		// Reconciled again once the resource or the referenced monitor changes
+		log.Info(fmt.Sprintf("Invalid reference: %v", err))
+
+		if setSynced(&instance.Status.Conditions, "InvalidReference", err) {
+			r.Recorder.Eventf(instance, "Warning", "InvalidReference", fmt.Sprint(err))
+			if err := r.updateStatus(ctx, log, instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		log.Info(fmt.Sprintf("Waiting for referenced monitor: %v", err))
+
+		if setSynced(&instance.Status.Conditions, "MonitorNotReady", err) {
+			if err := r.updateStatus(ctx, log, instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		return ctrl.Result{RequeueAfter: dependencyRequeueDelay}, nil
+	}
+
+	dryRun := isDryRun(r.DryRun, instance)
+
+	if instance.Status.Plan != nil && !dryRun {
+		log.V(1).Info("Clearing plan as dry-run is disabled")
+		instance.Status.Plan = nil
+		if err := r.updateStatus(ctx, log, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if dryRun {
+		err = r.planSLO(ctx, log, instance, monitorIds)
+	} else if instance.Status.Id == "" {
+		err = r.createSLO(ctx, log, dd, instance, monitorIds)
+	} else if instance.ObjectMeta.Generation != instance.Status.ObservedGeneration || !reflect.DeepEqual(monitorIds, instance.Status.MonitorIds) {
+		err = r.updateSLO(ctx, log, dd, instance, monitorIds)
+	} else {
+		log.V(1).Info("Skipping as generation is not new")
+	}
+
+	if err != nil {
+		return resultForSpecError(log, err, jitter(r.ResyncPeriod))
+	}
+
+	if !utils.ContainsString(instance.ObjectMeta.Finalizers, sloDeletionFinalizer) {
+		patch := client.MergeFrom(instance.DeepCopy())
+		instance.ObjectMeta.Finalizers = append(instance.ObjectMeta.Finalizers, sloDeletionFinalizer)
+		log.V(1).Info("Adding finalizer")
+		if err := r.Patch(ctx, instance, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// resolveMonitorIds returns the IDs of the monitors of a monitor based SLO,
+// followed by the IDs read from each DatadogMonitor in monitor_refs
+func (r *DatadogSLOReconciler) resolveMonitorIds(ctx context.Context, instance *datadoghqcomv1beta1.DatadogSLO) ([]int64, error) {
+	var monitorIds []int64
+	monitorIds = append(monitorIds, instance.Spec.MonitorIds...)
+
+	for _, ref := range instance.Spec.MonitorRefs {
+		monitorId, err := monitorIdFromRef(ctx, r, instance.Namespace, instance.Spec.CredentialsRef, ref)
+		if err != nil {
+			return nil, err
+		}
+		monitorIds = append(monitorIds, monitorId)
+	}
+
+	return monitorIds, nil
+}
+
+func (r *DatadogSLOReconciler) createSLO(ctx context.Context, log logr.Logger, dd datadog.SLOAPI, instance *datadoghqcomv1beta1.DatadogSLO, monitorIds []int64) error {
+	log.Info("Creating SLO")
+
+	sloId, err := dd.CreateSLO(ctx, instance.Spec, monitorIds)
+	if err != nil {
+		log.Error(err, "SLO failed to create")
+		r.Recorder.Eventf(instance, "Warning", "FailedCreate", fmt.Sprint(err))
+
+		setSynced(&instance.Status.Conditions, "FailedCreate", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
+	}
+
+	log.V(1).Info(fmt.Sprintf("SLO created with ID %v", sloId))
+	r.Recorder.Eventf(instance, "Normal", "SuccessfulCreate", fmt.Sprintf("SLO created with ID %v", sloId))
+
+	instance.Status.Id = sloId
+	instance.Status.Url = dd.SLOURL(sloId)
+	instance.Status.MonitorIds = monitorIds
+	instance.Status.ObservedGeneration = instance.ObjectMeta.Generation
+	setSynced(&instance.Status.Conditions, "Created", nil)
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+// recreateSLO creates an SLO again that was deleted in Datadog. The ID of the
+// deleted SLO is kept until the new one is created.
+func (r *DatadogSLOReconciler) recreateSLO(ctx context.Context, log logr.Logger, dd datadog.SLOAPI, instance *datadoghqcomv1beta1.DatadogSLO, monitorIds []int64) error {
+	message := fmt.Sprintf("SLO %v was deleted in Datadog, recreating it", instance.Status.Id)
+	log.Info(message)
+	r.Recorder.Eventf(instance, "Warning", "Recreating", message)
+
+	return r.createSLO(ctx, log, dd, instance, monitorIds)
+}
+
+func (r *DatadogSLOReconciler) updateSLO(ctx context.Context, log logr.Logger, dd datadog.SLOAPI, instance *datadoghqcomv1beta1.DatadogSLO, monitorIds []int64) error {
+	log.Info("Updating SLO")
+
+	if err := dd.UpdateSLO(ctx, instance.Status.Id, instance.Spec, monitorIds); err != nil {
+		if datadog.IsNotFound(err) {
+			return r.recreateSLO(ctx, log, dd, instance, monitorIds)
+		}
+
+		log.Error(err, "SLO update failed")
+		r.Recorder.Eventf(instance, "Warning", "FailedUpdate", fmt.Sprint(err))
+
+		setSynced(&instance.Status.Conditions, "FailedUpdate", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
+	}
+
+	log.V(1).Info(fmt.Sprintf("SLO updated with ID %v", instance.Status.Id))
+	r.Recorder.Eventf(instance, "Normal", "SuccessfulUpdate", fmt.Sprintf("SLO updated with ID %v", instance.Status.Id))
+
+	instance.Status.MonitorIds = monitorIds
+	instance.Status.ObservedGeneration = instance.ObjectMeta.Generation
+	setSynced(&instance.Status.Conditions, "Updated", nil)
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+func (r *DatadogSLOReconciler) deleteSLO(ctx context.Context, log logr.Logger, dd datadog.SLOAPI, instance *datadoghqcomv1beta1.DatadogSLO) error {
+	log.V(1).Info("Deleting SLO")
+
+	if !utils.ContainsString(instance.ObjectMeta.Finalizers, sloDeletionFinalizer) {
+		return nil
+	}
+
+	if instance.Status.Id != "" && isDryRun(r.DryRun, instance) {
+		updateStatus := func() error { return r.updateStatus(ctx, log, instance) }
+		if err := planDeletion(log, r.Recorder, instance, "SLO", instance.Status.Id, &instance.Status.Plan, &instance.Status.Conditions, updateStatus); err != nil {
+			return err
+		}
+	} else if instance.Status.Id == "" {
+		log.V(1).Info("Skipping deletion as SLO was never created")
+	} else if err := dd.DeleteSLO(ctx, instance.Status.Id); err != nil {
+		log.Error(err, "Failed to delete SLO from datadog")
+		r.Recorder.Eventf(instance, "Warning", "FailedDelete", fmt.Sprint(err))
+
+		setCondition(&instance.Status.Conditions, datadoghqcomv1beta1.ConditionReady, false, "FailedDelete", err.Error())
+		setDegraded(&instance.Status.Conditions, "FailedDelete", err)
+		if statusErr := r.updateStatus(ctx, log, instance); statusErr != nil {
+			return statusErr
+		}
+
+		return err
+	} else {
+		log.Info("Deleted SLO")
+	}
+
+	patch := client.MergeFrom(instance.DeepCopy())
+	instance.ObjectMeta.Finalizers = utils.RemoveString(instance.ObjectMeta.Finalizers, sloDeletionFinalizer)
+	log.V(1).Info("Removing finalizer")
+
+	return r.Patch(ctx, instance, patch)
+}
+
+// planSLO records in the status whether applying the spec would create or
+// update the SLO, without writing to Datadog. SLOs aren't read from
+// Datadog so changes made outside the controller aren't planned.
+func (r *DatadogSLOReconciler) planSLO(ctx context.Context, log logr.Logger, instance *datadoghqcomv1beta1.DatadogSLO, monitorIds []int64) error {
+	plan := datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionNone}
+
+	if instance.Status.Id == "" {
+		plan.Action = datadoghqcomv1beta1.PlanActionCreate
+	} else {
+		if instance.ObjectMeta.Generation != instance.Status.ObservedGeneration {
+			plan.Changes = append(plan.Changes, "spec")
+		}
+		if !reflect.DeepEqual(monitorIds, instance.Status.MonitorIds) {
+			plan.Changes = append(plan.Changes, "monitor_ids")
+		}
+		if len(plan.Changes) > 0 {
+			plan.Action = datadoghqcomv1beta1.PlanActionUpdate
+		}
+	}
+
+	log.V(1).Info(planMessage("SLO", plan))
+
+	if !setPlan(r.Recorder, instance, "SLO", &instance.Status.Plan, &instance.Status.Conditions, plan) {
+		return nil
+	}
+
+	return r.updateStatus(ctx, log, instance)
+}
+
+// updateStatus writes the status back to the cluster through the status subresource
+func (r *DatadogSLOReconciler) updateStatus(ctx context.Context, log logr.Logger, instance *datadoghqcomv1beta1.DatadogSLO) error {
+	if err := r.Status().Update(ctx, instance); err != nil {
+		log.Error(err, "Failed to update status")
+		return err
+	}
+
+	return nil
+}
+
+// requestsForMonitor maps a changed DatadogMonitor to the SLOs that reference
+// it in monitor_refs, so they are applied as soon as it's created in Datadog
+// and follow it when it's recreated with another ID
+func (r *DatadogSLOReconciler) requestsForMonitor(object handler.MapObject) []reconcile.Request {
+	requests := []reconcile.Request{}
+
+	slos := &datadoghqcomv1beta1.DatadogSLOList{}
+	if err := r.List(context.Background(), slos, client.InNamespace(object.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "Failed to list SLOs to find references")
+		return requests
+	}
+
+	for _, slo := range slos.Items {
+		if utils.ContainsString(slo.Spec.MonitorRefs, object.Meta.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: slo.Namespace, Name: slo.Name}})
+		}
+	}
+
+	return requests
+}
+
+func (r *DatadogSLOReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&datadoghqcomv1beta1.DatadogSLO{}).
+		Watches(&source.Kind{Type: &datadoghqcomv1beta1.DatadogMonitor{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.requestsForMonitor),
+		}).
+		Complete(r)
+}
@@ -0,0 +1,130 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+)
+
+// testSLO returns the monitor based DatadogSLO "availability" of monitors
+func testSLO(monitorRefs ...string) *datadoghqcomv1beta1.DatadogSLO {
+	return &datadoghqcomv1beta1.DatadogSLO{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "availability", Generation: 1},
+		Spec: datadoghqcomv1beta1.DatadogSLOSpec{
+			Name:        "availability",
+			Type:        "monitor",
+			MonitorRefs: monitorRefs,
+			Thresholds:  []datadoghqcomv1beta1.DatadogSLOThreshold{{Timeframe: "7d", Target: 99.9}},
+		},
+	}
+}
+
+// reconcileSLO reconciles the DatadogSLO "availability" and returns it as
+// it's stored afterwards
+func reconcileSLO(t *testing.T, r *DatadogSLOReconciler) (*datadoghqcomv1beta1.DatadogSLO, ctrl.Result) {
+	t.Helper()
+
+	key := types.NamespacedName{Namespace: "default", Name: "availability"}
+	result, err := r.Reconcile(ctrl.Request{NamespacedName: key})
+	assert.Nil(t, err)
+
+	instance := &datadoghqcomv1beta1.DatadogSLO{}
+	assert.Nil(t, r.Get(context.Background(), key, instance))
+
+	return instance, result
+}
+
+func TestSLOMonitorRefs(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	cpuSpec := datadoghqcomv1beta1.DatadogMonitorSpec{Name: "cpu", Type: "metric alert", Query: "avg(last_5m):avg:system.cpu.user{*} > 90"}
+	cpu := referencedMonitor("cpu", 0, cpuSpec.Query)
+
+	r := newTestSLOReconciler(t, server, cpu, testSLO("cpu"))
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	// Retried until the referenced monitor is created in Datadog
+	instance, result := reconcileSLO(t, r)
+	assert.Equal(t, dependencyRequeueDelay, result.RequeueAfter)
+	assert.Empty(t, instance.Status.Id)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "MonitorNotReady")
+	assert.Zero(t, server.Requests("POST /slo"))
+
+	// The SLO is reconciled again once the monitor changes
+	requests := r.requestsForMonitor(handler.MapObject{Meta: cpu, Object: cpu})
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "availability"}}}, requests)
+
+	cpu.Status.Id = server.CreateMonitor(cpuSpec)
+	assert.Nil(t, r.Status().Update(context.Background(), cpu))
+
+	instance, result = reconcileSLO(t, r)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, []int64{cpu.Status.Id}, instance.Status.MonitorIds)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionTrue, "Created")
+	assert.Contains(t, <-recorder.Events, "Normal SuccessfulCreate SLO created with ID")
+	slo, ok := server.SLO(instance.Status.Id)
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{float64(cpu.Status.Id)}, slo["monitor_ids"])
+
+	// Nothing is written while the generation and the monitor IDs are applied
+	reconcileSLO(t, r)
+	assert.Zero(t, server.Requests("PUT /slo/:id"))
+
+	// A monitor recreated with another ID updates the SLO without a new
+	// generation
+	cpu.Status.Id = server.CreateMonitor(cpuSpec)
+	assert.Nil(t, r.Status().Update(context.Background(), cpu))
+
+	instance, _ = reconcileSLO(t, r)
+	assert.Equal(t, 1, server.Requests("PUT /slo/:id"))
+	assert.Equal(t, int64(1), instance.Status.ObservedGeneration)
+	assert.Equal(t, []int64{cpu.Status.Id}, instance.Status.MonitorIds)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionTrue, "Updated")
+	assert.Contains(t, <-recorder.Events, "Normal SuccessfulUpdate SLO updated with ID")
+	slo, _ = server.SLO(instance.Status.Id)
+	assert.Equal(t, []interface{}{float64(cpu.Status.Id)}, slo["monitor_ids"])
+}
+
+func TestSLOInvalidReference(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	cpu := referencedMonitor("cpu", 11, "avg(last_5m):avg:system.cpu.user{*} > 90")
+	cpu.Spec.CredentialsRef = "team-a"
+
+	r := newTestSLOReconciler(t, server, cpu, testSLO("cpu"))
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	// A monitor of another organization can't be used, so it's not retried
+	instance, result := reconcileSLO(t, r)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Empty(t, instance.Status.Id)
+	assertCondition(t, instance.Status.Conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "InvalidReference")
+	assert.Equal(t, `Warning InvalidReference DatadogMonitor default/cpu has credentials_ref "team-a" instead of ""`, <-recorder.Events)
+	assert.Zero(t, server.Requests("POST /slo"))
+}
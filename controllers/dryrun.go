@@ -0,0 +1,105 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-logr/logr"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"reflect"
+	"strings"
+)
+
+var (
+	plannedChangesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "datadog_controller",
+		Subsystem: "dry_run",
+		Name:      "planned",
+		Help:      "Count of changes planned in dry-run mode instead of being made in Datadog",
+	}, []string{
+		"kind",
+		"action",
+	})
+)
+
+// isDryRun reports whether changes to a resource are only planned, either
+// because the controller runs in dry-run mode or the resource has the dry-run
+// annotation
+func isDryRun(dryRun bool, object metav1.Object) bool {
+	return dryRun || object.GetAnnotations()[datadoghqcomv1beta1.DryRunAnnotation] == "true"
+}
+
+// planDeletion records the deletion of a resource in dry-run mode. Deletion
+// isn't blocked in dry-run mode: the finalizer is still removed, but the
+// resource is left in Datadog and the deletion is only recorded in the plan
+// and an event.
+func planDeletion(log logr.Logger, recorder record.EventRecorder, object runtime.Object, kind string, id interface{}, current **datadoghqcomv1beta1.Plan, conditions *[]datadoghqcomv1beta1.Condition, updateStatus func() error) error {
+	log.Info(fmt.Sprintf("Leaving %v %v in Datadog as dry-run is enabled", kind, id))
+
+	if !setPlan(recorder, object, kind, current, conditions, datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionDelete}) {
+		return nil
+	}
+
+	return updateStatus()
+}
+
+// setPlan records a plan in the status of a resource and reports whether it
+// changed. Ready and Synced are only true when nothing would change. A new
+// plan is also recorded as an event.
+func setPlan(recorder record.EventRecorder, object runtime.Object, kind string, current **datadoghqcomv1beta1.Plan, conditions *[]datadoghqcomv1beta1.Condition, plan datadoghqcomv1beta1.Plan) bool {
+	// Plans read back from the status have no empty changes
+	if len(plan.Changes) == 0 {
+		plan.Changes = nil
+	}
+
+	message := planMessage(kind, plan)
+	changed := false
+
+	if *current == nil || !reflect.DeepEqual(**current, plan) {
+		recorder.Eventf(object, "Normal", "DryRun", message)
+		plannedChangesCounter.WithLabelValues(kind, plan.Action).Inc()
+
+		*current = &plan
+		changed = true
+	}
+
+	inSync := plan.Action == datadoghqcomv1beta1.PlanActionNone
+	changed = setCondition(conditions, datadoghqcomv1beta1.ConditionSynced, inSync, "DryRun", message) || changed
+	changed = setCondition(conditions, datadoghqcomv1beta1.ConditionReady, inSync, "DryRun", message) || changed
+	changed = setCondition(conditions, datadoghqcomv1beta1.ConditionDegraded, false, "DryRun", "") || changed
+
+	return changed
+}
+
+// planMessage describes a plan, e.g. "Dry run: would update monitor: query, tags"
+func planMessage(kind string, plan datadoghqcomv1beta1.Plan) string {
+	if plan.Action == datadoghqcomv1beta1.PlanActionNone {
+		return fmt.Sprintf("Dry run: %v is in sync", kind)
+	}
+
+	message := fmt.Sprintf("Dry run: would %v %v", strings.ToLower(plan.Action), kind)
+	if len(plan.Changes) > 0 {
+		message = fmt.Sprintf("%v: %v", message, strings.Join(plan.Changes, ", "))
+	}
+
+	return message
+}
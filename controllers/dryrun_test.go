@@ -0,0 +1,70 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"testing"
+)
+
+func TestPlanMessage(t *testing.T) {
+	tests := []struct {
+		plan    datadoghqcomv1beta1.Plan
+		message string
+	}{
+		{datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionNone}, "Dry run: monitor is in sync"},
+		{datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionCreate}, "Dry run: would create monitor"},
+		{datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionUpdate, Changes: []string{"query", "tags"}}, "Dry run: would update monitor: query, tags"},
+		{datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionDelete}, "Dry run: would delete monitor"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.message, planMessage("monitor", test.plan))
+	}
+}
+
+func TestSetPlan(t *testing.T) {
+	instance := &datadoghqcomv1beta1.DatadogMonitor{}
+	recorder := record.NewFakeRecorder(10)
+	conditions := &instance.Status.Conditions
+
+	update := datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionUpdate, Changes: []string{"query"}}
+	assert.True(t, setPlan(recorder, instance, "monitor", &instance.Status.Plan, conditions, update))
+	assert.Equal(t, &update, instance.Status.Plan)
+	assert.Equal(t, "Normal DryRun Dry run: would update monitor: query", <-recorder.Events)
+	assertCondition(t, *conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionFalse, "DryRun")
+	assertCondition(t, *conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionFalse, "DryRun")
+	assertCondition(t, *conditions, datadoghqcomv1beta1.ConditionDegraded, metav1.ConditionFalse, "DryRun")
+
+	// The same plan is only recorded once
+	assert.False(t, setPlan(recorder, instance, "monitor", &instance.Status.Plan, conditions, update))
+	assert.Empty(t, recorder.Events)
+
+	// Empty changes equal the missing changes of a plan read back from the status
+	none := datadoghqcomv1beta1.Plan{Action: datadoghqcomv1beta1.PlanActionNone, Changes: []string{}}
+	assert.True(t, setPlan(recorder, instance, "monitor", &instance.Status.Plan, conditions, none))
+	assert.Nil(t, instance.Status.Plan.Changes)
+	assert.Equal(t, "Normal DryRun Dry run: monitor is in sync", <-recorder.Events)
+	assertCondition(t, *conditions, datadoghqcomv1beta1.ConditionSynced, metav1.ConditionTrue, "DryRun")
+	assertCondition(t, *conditions, datadoghqcomv1beta1.ConditionReady, metav1.ConditionTrue, "DryRun")
+
+	none.Changes = nil
+	assert.False(t, setPlan(recorder, instance, "monitor", &instance.Status.Plan, conditions, none))
+}
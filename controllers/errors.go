@@ -0,0 +1,52 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"time"
+)
+
+// resultForError returns the result of a reconcile that failed with err.
+// Requests rate limited by Datadog are retried once the limit resets instead
+// of with the error backoff of controller-runtime, which would retry them
+// within milliseconds.
+func resultForError(log logr.Logger, err error) (ctrl.Result, error) {
+	if retryAfter, ok := datadog.RetryAfter(err); ok {
+		log.Info(fmt.Sprintf("Rate limited by Datadog, retrying in %v", retryAfter))
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
+	}
+
+	return ctrl.Result{}, err
+}
+
+// resultForSpecError is resultForError for errors applying a spec to
+// Datadog. Specs Datadog rejects, e.g. for an invalid query, fail the same way
+// until they change, so they are only retried after resync, if set, instead
+// of with the error backoff. The error is already recorded in the conditions
+// and events of the resource.
+func resultForSpecError(log logr.Logger, err error, resync time.Duration) (ctrl.Result, error) {
+	if datadog.IsPermanent(err) {
+		log.Info(fmt.Sprintf("Not retrying until the spec changes: %v", err))
+		return ctrl.Result{RequeueAfter: resync}, nil
+	}
+
+	return resultForError(log, err)
+}
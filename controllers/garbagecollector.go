@@ -0,0 +1,214 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+const (
+	// Orphaned monitors are only logged and counted
+	GarbageCollectionPolicyReport = "Report"
+	// Orphaned monitors are deleted from Datadog
+	GarbageCollectionPolicyDelete = "Delete"
+)
+
+var (
+	orphanedMonitorsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "datadog_controller",
+		Subsystem: "monitor",
+		Name:      "orphaned",
+		Help:      "Number of monitors in Datadog tagged with this cluster that no DatadogMonitor owns, as of the last garbage collection",
+	})
+
+	collectedMonitorsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "datadog_controller",
+		Subsystem: "monitor",
+		Name:      "garbage_collected",
+		Help:      "Count of orphaned monitors deleted from Datadog by the garbage collector, by result",
+	}, []string{
+		"result",
+	})
+)
+
+// MonitorGarbageCollector periodically looks for monitors in Datadog that
+// are tagged with this cluster but have no DatadogMonitor, e.g. because a
+// resource was deleted while the controller was down or its finalizer was
+// removed by hand. Monitors are collected in the organization the controller
+// is configured with and in each organization set with a DatadogCredentials.
+type MonitorGarbageCollector struct {
+	client.Client
+	stopContext
+	Log     logr.Logger
+	Datadog MonitorClients
+	// Only monitors tagged with this cluster name are collected
+	ClusterName string
+	// How often to look for orphaned monitors
+	Interval time.Duration
+	// Report or Delete
+	Policy string
+	// Only report orphaned monitors, whatever the policy
+	DryRun bool
+}
+
+// Start runs the garbage collector until stop is closed. It's started by
+// the manager once its caches are synced, and only on the leader.
+func (g *MonitorGarbageCollector) Start(stop <-chan struct{}) error {
+	g.stop = stop
+
+	wait.JitterUntil(func() {
+		ctx, cancel := g.newContext()
+		defer cancel()
+
+		if err := g.collect(ctx); err != nil {
+			g.Log.Error(err, "Failed to collect orphaned monitors")
+		}
+	}, g.Interval, resyncJitterFactor, true, stop)
+
+	return nil
+}
+
+// organizationMonitors are the monitors tagged with the cluster in one
+// organization
+type organizationMonitors struct {
+	org      string
+	dd       datadog.MonitorAPI
+	monitors []datadoghqcomv1beta1.DatadogMonitorSpec
+}
+
+// collect finds the orphaned monitors and reports or deletes them
+func (g *MonitorGarbageCollector) collect(ctx context.Context) error {
+	g.Log.V(1).Info("Looking for orphaned monitors")
+
+	apis, err := g.monitorAPIs(ctx)
+	if err != nil {
+		return err
+	}
+
+	clusterTag := datadog.Owner{Cluster: g.ClusterName}.Tags()
+	organizations := []organizationMonitors{}
+	for _, organization := range apis {
+		monitors, err := organization.dd.ListMonitors(ctx, datadog.MonitorFilter{Tags: clusterTag})
+		if err != nil {
+			// The other organizations are still collected
+			g.Log.Error(err, fmt.Sprintf("Failed to list monitors of organization %v", organization.org))
+			continue
+		}
+		organization.monitors = monitors
+		organizations = append(organizations, organization)
+	}
+
+	// Listed after the monitors, so monitors created in between have a resource
+	resources := &datadoghqcomv1beta1.DatadogMonitorList{}
+	if err := g.List(ctx, resources); err != nil {
+		return err
+	}
+
+	// UIDs are unique across organizations, so a resource owns its monitor
+	// whichever organization it's in
+	owners := map[string]bool{}
+	for _, resource := range resources.Items {
+		owners[string(resource.UID)] = true
+	}
+
+	orphans := 0
+	for _, organization := range organizations {
+		for _, monitor := range organization.monitors {
+			owner := datadog.OwnerOf(monitor)
+			if owner.UID == "" || owners[owner.UID] {
+				continue
+			}
+
+			orphans++
+
+			if g.Policy != GarbageCollectionPolicyDelete || g.DryRun {
+				g.Log.Info(fmt.Sprintf("Monitor %v is orphaned, its DatadogMonitor %v/%v no longer exists", monitor.Id, owner.Namespace, owner.Name))
+				continue
+			}
+
+			g.Log.Info(fmt.Sprintf("Deleting orphaned monitor %v of DatadogMonitor %v/%v", monitor.Id, owner.Namespace, owner.Name))
+			err := organization.dd.DeleteMonitor(ctx, monitor.Id)
+			if datadog.IsNotFound(err) {
+				// Already deleted, e.g. by hand or by a previous collection
+				// whose response was lost
+				g.Log.Info(fmt.Sprintf("Orphaned monitor %v was already deleted", monitor.Id))
+			} else if err != nil {
+				g.Log.Error(err, fmt.Sprintf("Failed to delete orphaned monitor %v", monitor.Id))
+				collectedMonitorsCounter.WithLabelValues("failed").Inc()
+				continue
+			}
+
+			collectedMonitorsCounter.WithLabelValues("deleted").Inc()
+			orphans--
+		}
+	}
+
+	orphanedMonitorsGauge.Set(float64(orphans))
+
+	return nil
+}
+
+// monitorAPIs returns the monitor API of the organization the controller is
+// configured with and of each organization set with a DatadogCredentials,
+// once per organization. Their monitors aren't listed yet.
+func (g *MonitorGarbageCollector) monitorAPIs(ctx context.Context) ([]organizationMonitors, error) {
+	dd, err := g.Datadog.MonitorsFor(ctx, g, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := dd.Organization(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	apis := []organizationMonitors{{org: org, dd: dd}}
+	seen := map[string]bool{org: true}
+
+	credentials := &datadoghqcomv1beta1.DatadogCredentialsList{}
+	if err := g.List(ctx, credentials); err != nil {
+		return nil, err
+	}
+
+	for _, item := range credentials.Items {
+		dd, err := g.Datadog.MonitorsFor(ctx, g, item.Namespace, item.Name)
+		if err == nil {
+			org, err = dd.Organization(ctx)
+		}
+		if err != nil {
+			// Its monitors are collected once its keys can be used again
+			g.Log.Error(err, fmt.Sprintf("Skipping organization of DatadogCredentials %v/%v", item.Namespace, item.Name))
+			continue
+		}
+
+		if !seen[org] {
+			seen[org] = true
+			apis = append(apis, organizationMonitors{org: org, dd: dd})
+		}
+	}
+
+	return apis, nil
+}
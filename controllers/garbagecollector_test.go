@@ -0,0 +1,189 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+// ownedMonitor creates a monitor in the fake Datadog API tagged as owned by
+// a DatadogMonitor of a cluster
+func ownedMonitor(server *fake.Server, owner datadog.Owner) int64 {
+	return server.CreateMonitor(datadoghqcomv1beta1.DatadogMonitorSpec{
+		Name:  owner.Name,
+		Type:  "metric alert",
+		Query: "avg(last_5m):avg:system.cpu.user{*} > 90",
+		Tags:  append([]string{"team:platform"}, owner.Tags()...),
+	})
+}
+
+// newTestGarbageCollector returns a MonitorGarbageCollector for the cluster
+// "test" with a fake client holding objects. The default organization is
+// served by server and DatadogCredentials by credentialsServer.
+func newTestGarbageCollector(t *testing.T, server *fake.Server, credentialsServer *fake.Server, objects ...runtime.Object) *MonitorGarbageCollector {
+	r := newTestMonitorReconciler(t, server, objects...)
+
+	clients := r.Datadog.(*DatadogClients)
+	clients.Options = []datadog.Option{datadog.WithBaseURL(credentialsServer.URL)}
+
+	return &MonitorGarbageCollector{
+		Client:      r.Client,
+		Log:         ctrl.Log.WithName("controllers").WithName("MonitorGarbageCollector"),
+		Datadog:     clients,
+		ClusterName: "test",
+		Policy:      GarbageCollectionPolicyReport,
+	}
+}
+
+func TestCollectMonitors(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		dryRun  bool
+		deleted bool
+	}{
+		{"reports orphans under Report", GarbageCollectionPolicyReport, false, false},
+		{"deletes orphans under Delete", GarbageCollectionPolicyDelete, false, true},
+		{"only reports orphans in dry-run mode", GarbageCollectionPolicyDelete, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := fake.NewServer()
+			defer server.Close()
+
+			live := adoptingMonitor("live", "live-uid", 0)
+			delete(live.Annotations, datadoghqcomv1beta1.AdoptMonitorIdAnnotation)
+
+			liveId := ownedMonitor(server, datadog.Owner{Cluster: "test", Namespace: "default", Name: "live", UID: "live-uid"})
+			orphanId := ownedMonitor(server, datadog.Owner{Cluster: "test", Namespace: "default", Name: "deleted", UID: "deleted-uid"})
+			otherClusterId := ownedMonitor(server, datadog.Owner{Cluster: "other", Namespace: "default", Name: "deleted", UID: "other-uid"})
+			unownedId := ownedMonitor(server, datadog.Owner{})
+
+			g := newTestGarbageCollector(t, server, server, live)
+			g.Policy = test.policy
+			g.DryRun = test.dryRun
+
+			deleted := testutil.ToFloat64(collectedMonitorsCounter.WithLabelValues("deleted"))
+
+			assert.Nil(t, g.collect(context.Background()))
+
+			for _, id := range []int64{liveId, otherClusterId, unownedId} {
+				_, ok := server.Monitor(id)
+				assert.True(t, ok)
+			}
+
+			_, ok := server.Monitor(orphanId)
+			assert.Equal(t, !test.deleted, ok)
+
+			if test.deleted {
+				assert.Equal(t, 1, server.Requests("DELETE /monitor/:id"))
+				assert.Equal(t, deleted+1, testutil.ToFloat64(collectedMonitorsCounter.WithLabelValues("deleted")))
+				assert.Equal(t, float64(0), testutil.ToFloat64(orphanedMonitorsGauge))
+			} else {
+				assert.Zero(t, server.Requests("DELETE /monitor/:id"))
+				assert.Equal(t, float64(1), testutil.ToFloat64(orphanedMonitorsGauge))
+			}
+		})
+	}
+}
+
+func TestCollectMonitorsOfCredentials(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	teamServer := fake.NewServer()
+	defer teamServer.Close()
+
+	credentials, secret := teamCredentials("team-a", "datadoghq.com")
+	otherCredentials, otherSecret := teamCredentials("team-a-again", "datadoghq.com")
+
+	orphanId := ownedMonitor(server, datadog.Owner{Cluster: "test", Namespace: "default", Name: "deleted", UID: "deleted-uid"})
+	teamOrphanId := ownedMonitor(teamServer, datadog.Owner{Cluster: "test", Namespace: "default", Name: "team-deleted", UID: "team-deleted-uid"})
+
+	g := newTestGarbageCollector(t, server, teamServer, credentials, secret, otherCredentials, otherSecret)
+	g.Policy = GarbageCollectionPolicyDelete
+
+	assert.Nil(t, g.collect(context.Background()))
+
+	_, ok := server.Monitor(orphanId)
+	assert.False(t, ok)
+	_, ok = teamServer.Monitor(teamOrphanId)
+	assert.False(t, ok)
+
+	// DatadogCredentials of the same organization are listed once, even with
+	// other keys
+	assert.Equal(t, 1, teamServer.Requests("GET /monitor"))
+}
+
+// deletedMonitorsAPI is a MonitorAPI whose monitors are deleted by someone
+// else before the garbage collector deletes them
+type deletedMonitorsAPI struct {
+	datadog.MonitorAPI
+}
+
+func (a deletedMonitorsAPI) DeleteMonitor(ctx context.Context, monitorId int64) error {
+	if err := a.MonitorAPI.DeleteMonitor(ctx, monitorId); err != nil {
+		return err
+	}
+
+	return &datadog.APIError{Method: "DELETE", Path: fmt.Sprintf("/monitor/%v", monitorId), StatusCode: http.StatusNotFound, Errors: []string{"Monitor not found"}}
+}
+
+// deletedMonitorsClients returns deletedMonitorsAPI for the default organization
+type deletedMonitorsClients struct {
+	*DatadogClients
+}
+
+func (c deletedMonitorsClients) MonitorsFor(ctx context.Context, k8sClient client.Client, namespace string, credentialsRef string) (datadog.MonitorAPI, error) {
+	dd, err := c.DatadogClients.MonitorsFor(ctx, k8sClient, namespace, credentialsRef)
+	if err != nil {
+		return nil, err
+	}
+
+	return deletedMonitorsAPI{dd}, nil
+}
+
+func TestCollectDeletedMonitors(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	ownedMonitor(server, datadog.Owner{Cluster: "test", Namespace: "default", Name: "deleted", UID: "deleted-uid"})
+
+	g := newTestGarbageCollector(t, server, server)
+	g.Datadog = deletedMonitorsClients{g.Datadog.(*DatadogClients)}
+	g.Policy = GarbageCollectionPolicyDelete
+
+	deleted := testutil.ToFloat64(collectedMonitorsCounter.WithLabelValues("deleted"))
+	failed := testutil.ToFloat64(collectedMonitorsCounter.WithLabelValues("failed"))
+
+	// A monitor that is already gone is collected
+	assert.Nil(t, g.collect(context.Background()))
+	assert.Equal(t, deleted+1, testutil.ToFloat64(collectedMonitorsCounter.WithLabelValues("deleted")))
+	assert.Equal(t, failed, testutil.ToFloat64(collectedMonitorsCounter.WithLabelValues("failed")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(orphanedMonitorsGauge))
+}
@@ -0,0 +1,217 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/utils"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Labels of unified service tagging, used in the scope of APM monitors
+const (
+	serviceTagLabel = "tags.datadoghq.com/service"
+	envTagLabel     = "tags.datadoghq.com/env"
+)
+
+// workload is a Deployment, StatefulSet or Service with monitor presets
+type workload struct {
+	kind        string
+	namespace   string
+	name        string
+	labels      map[string]string
+	annotations map[string]string
+}
+
+// scope returns the tags selecting the metrics of the workload
+func (w workload) scope() string {
+	tag := map[string]string{
+		"Deployment":  "kube_deployment",
+		"StatefulSet": "kube_stateful_set",
+		"Service":     "kube_service",
+	}[w.kind]
+
+	return fmt.Sprintf("kube_namespace:%v,%v:%v", w.namespace, tag, w.name)
+}
+
+// serviceScope returns the tags selecting the APM metrics of the workload
+func (w workload) serviceScope() string {
+	service := w.name
+	if label := w.labels[serviceTagLabel]; label != "" {
+		service = label
+	}
+
+	scope := "service:" + service
+	if env := w.labels[envTagLabel]; env != "" {
+		scope += ",env:" + env
+	}
+
+	return scope
+}
+
+// monitorPreset is a monitor that can be added to a workload with an
+// annotation instead of writing a DatadogMonitor
+type monitorPreset struct {
+	// The kinds of workloads the preset applies to
+	kinds []string
+	// What the monitor alerts on, completing "Deployment shop/web ..."
+	description string
+	monitorType string
+	// The query of the monitor without the comparison with the threshold
+	query func(w workload) string
+	// The default critical threshold
+	critical float64
+}
+
+var monitorPresets = map[string]monitorPreset{
+	"restarts": {
+		kinds:       []string{"Deployment", "StatefulSet"},
+		description: "is restarting",
+		monitorType: "query alert",
+		query: func(w workload) string {
+			return fmt.Sprintf("change(max(last_5m),last_5m):sum:kubernetes.containers.restarts{%v}", w.scope())
+		},
+		critical: 5,
+	},
+	"unavailable-replicas": {
+		kinds:       []string{"Deployment", "StatefulSet"},
+		description: "has unavailable replicas",
+		monitorType: "query alert",
+		query: func(w workload) string {
+			if w.kind == "StatefulSet" {
+				return fmt.Sprintf("min(last_15m):max:kubernetes_state.statefulset.replicas_desired{%v} - max:kubernetes_state.statefulset.replicas_ready{%v}", w.scope(), w.scope())
+			}
+			return fmt.Sprintf("min(last_15m):max:kubernetes_state.deployment.replicas_unavailable{%v}", w.scope())
+		},
+		critical: 0,
+	},
+	"memory": {
+		kinds:       []string{"Deployment", "StatefulSet"},
+		description: "is close to its memory limit",
+		monitorType: "metric alert",
+		query: func(w workload) string {
+			return fmt.Sprintf("avg(last_10m):max:kubernetes.memory.usage_pct{%v}", w.scope())
+		},
+		critical: 0.9,
+	},
+	"error-rate": {
+		kinds:       []string{"Deployment", "StatefulSet", "Service"},
+		description: "has a high error rate",
+		monitorType: "query alert",
+		query: func(w workload) string {
+			return fmt.Sprintf("sum(last_10m):sum:trace.http.request.errors{%v}.as_count() / sum:trace.http.request.hits{%v}.as_count()", w.serviceScope(), w.serviceScope())
+		},
+		critical: 0.05,
+	},
+}
+
+// presetNames returns the names of the presets, for error messages
+func presetNames() []string {
+	names := []string{}
+	for name := range monitorPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// presetSpecs returns the specs of the monitors the annotations of a
+// workload ask for, by preset name
+func presetSpecs(w workload) (map[string]datadoghqcomv1beta1.DatadogMonitorSpec, error) {
+	specs := map[string]datadoghqcomv1beta1.DatadogMonitorSpec{}
+
+	for _, name := range strings.Split(w.annotations[datadoghqcomv1beta1.MonitorPresetsAnnotation], ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		preset, ok := monitorPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown monitor preset %q in %v annotation, must be one of: %v", name, datadoghqcomv1beta1.MonitorPresetsAnnotation, strings.Join(presetNames(), ", "))
+		}
+
+		spec, err := preset.spec(name, w)
+		if err != nil {
+			return nil, err
+		}
+		specs[name] = spec
+	}
+
+	return specs, nil
+}
+
+// spec returns the spec of the monitor of the preset for a workload, with the
+// thresholds from its annotations
+func (p monitorPreset) spec(name string, w workload) (datadoghqcomv1beta1.DatadogMonitorSpec, error) {
+	if !utils.ContainsString(p.kinds, w.kind) {
+		return datadoghqcomv1beta1.DatadogMonitorSpec{}, fmt.Errorf("Monitor preset %q can't be used on a %v", name, w.kind)
+	}
+
+	critical, err := presetThreshold(w, name, "critical", p.critical)
+	if err != nil {
+		return datadoghqcomv1beta1.DatadogMonitorSpec{}, err
+	}
+	warning, err := presetThreshold(w, name, "warning", 0)
+	if err != nil {
+		return datadoghqcomv1beta1.DatadogMonitorSpec{}, err
+	}
+
+	title := fmt.Sprintf("%v %v/%v %v", w.kind, w.namespace, w.name, p.description)
+	message := fmt.Sprintf("%v.\n\nGenerated from the %v annotation of the %v.", title, datadoghqcomv1beta1.MonitorPresetsAnnotation, w.kind)
+	if notify := w.annotations[datadoghqcomv1beta1.MonitorNotifyAnnotation]; notify != "" {
+		message += " " + notify
+	}
+
+	spec := datadoghqcomv1beta1.DatadogMonitorSpec{
+		Name:    title,
+		Type:    p.monitorType,
+		Query:   fmt.Sprintf("%v > %v", p.query(w), strconv.FormatFloat(critical, 'f', -1, 64)),
+		Message: message,
+		Tags:    []string{"kube_namespace:" + w.namespace},
+	}
+	spec.Options.Thresholds.Critical = critical
+	spec.Options.Thresholds.Warning = warning
+
+	if errs := spec.Validate(); len(errs) > 0 {
+		return spec, fmt.Errorf("Monitor preset %q: %v", name, errs.ToAggregate())
+	}
+
+	return spec, nil
+}
+
+// presetThreshold reads a threshold of a preset from the annotations of a
+// workload, e.g. datadoghq.com/monitor.restarts.critical
+func presetThreshold(w workload, preset string, threshold string, defaultValue float64) (float64, error) {
+	annotation := datadoghqcomv1beta1.MonitorPresetAnnotationPrefix + preset + "." + threshold
+
+	value, ok := w.annotations[annotation]
+	if !ok {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %v annotation %q: must be a number", annotation, value)
+	}
+
+	return parsed, nil
+}
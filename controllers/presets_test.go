@@ -0,0 +1,194 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// presetWorkload returns a workload "shop/web" asking for presets
+func presetWorkload(kind string, presets string) workload {
+	return workload{
+		kind:        kind,
+		namespace:   "shop",
+		name:        "web",
+		labels:      map[string]string{},
+		annotations: map[string]string{datadoghqcomv1beta1.MonitorPresetsAnnotation: presets},
+	}
+}
+
+func TestPresetSpecs(t *testing.T) {
+	tests := []struct {
+		name     string
+		workload workload
+		// The queries of the specs by preset name
+		queries map[string]string
+		err     string
+	}{
+		{
+			name:     "no presets",
+			workload: presetWorkload("Deployment", " , "),
+			queries:  map[string]string{},
+		},
+		{
+			name:     "presets of a Deployment",
+			workload: presetWorkload("Deployment", "restarts, unavailable-replicas"),
+			queries: map[string]string{
+				"restarts":             "change(max(last_5m),last_5m):sum:kubernetes.containers.restarts{kube_namespace:shop,kube_deployment:web} > 5",
+				"unavailable-replicas": "min(last_15m):max:kubernetes_state.deployment.replicas_unavailable{kube_namespace:shop,kube_deployment:web} > 0",
+			},
+		},
+		{
+			name:     "unavailable replicas of a StatefulSet",
+			workload: presetWorkload("StatefulSet", "unavailable-replicas"),
+			queries: map[string]string{
+				"unavailable-replicas": "min(last_15m):max:kubernetes_state.statefulset.replicas_desired{kube_namespace:shop,kube_stateful_set:web} - max:kubernetes_state.statefulset.replicas_ready{kube_namespace:shop,kube_stateful_set:web} > 0",
+			},
+		},
+		{
+			name:     "error rate of a Service named like the service",
+			workload: presetWorkload("Service", "error-rate"),
+			queries: map[string]string{
+				"error-rate": "sum(last_10m):sum:trace.http.request.errors{service:web}.as_count() / sum:trace.http.request.hits{service:web}.as_count() > 0.05",
+			},
+		},
+		{
+			name: "error rate of a Service with unified service tagging labels",
+			workload: func() workload {
+				w := presetWorkload("Service", "error-rate")
+				w.labels[serviceTagLabel] = "storefront"
+				w.labels[envTagLabel] = "prod"
+				return w
+			}(),
+			queries: map[string]string{
+				"error-rate": "sum(last_10m):sum:trace.http.request.errors{service:storefront,env:prod}.as_count() / sum:trace.http.request.hits{service:storefront,env:prod}.as_count() > 0.05",
+			},
+		},
+		{
+			name: "thresholds from annotations",
+			workload: func() workload {
+				w := presetWorkload("Deployment", "restarts")
+				w.annotations["datadoghq.com/monitor.restarts.critical"] = "10"
+				w.annotations["datadoghq.com/monitor.restarts.warning"] = "2.5"
+				return w
+			}(),
+			queries: map[string]string{
+				"restarts": "change(max(last_5m),last_5m):sum:kubernetes.containers.restarts{kube_namespace:shop,kube_deployment:web} > 10",
+			},
+		},
+		{
+			name:     "unknown preset",
+			workload: presetWorkload("Deployment", "restarts,cpu"),
+			err:      `Unknown monitor preset "cpu" in datadoghq.com/monitors annotation, must be one of: error-rate, memory, restarts, unavailable-replicas`,
+		},
+		{
+			name:     "preset of another kind",
+			workload: presetWorkload("Service", "restarts"),
+			err:      `Monitor preset "restarts" can't be used on a Service`,
+		},
+		{
+			name: "non-numeric threshold",
+			workload: func() workload {
+				w := presetWorkload("Deployment", "restarts")
+				w.annotations["datadoghq.com/monitor.restarts.critical"] = "high"
+				return w
+			}(),
+			err: `Invalid datadoghq.com/monitor.restarts.critical annotation "high": must be a number`,
+		},
+		{
+			name: "warning above critical",
+			workload: func() workload {
+				w := presetWorkload("Deployment", "restarts")
+				w.annotations["datadoghq.com/monitor.restarts.warning"] = "10"
+				return w
+			}(),
+			err: `Monitor preset "restarts": spec.options.thresholds.warning`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			specs, err := presetSpecs(test.workload)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+
+			assert.Nil(t, err)
+			queries := map[string]string{}
+			for name, spec := range specs {
+				queries[name] = spec.Query
+			}
+			assert.Equal(t, test.queries, queries)
+		})
+	}
+}
+
+func TestPresetSpec(t *testing.T) {
+	w := presetWorkload("Deployment", "restarts")
+	w.annotations["datadoghq.com/monitor.restarts.warning"] = "2"
+	w.annotations[datadoghqcomv1beta1.MonitorNotifyAnnotation] = "@slack-shop"
+
+	spec, err := monitorPresets["restarts"].spec("restarts", w)
+	assert.Nil(t, err)
+	assert.Equal(t, "Deployment shop/web is restarting", spec.Name)
+	assert.Equal(t, "query alert", spec.Type)
+	assert.Equal(t, "Deployment shop/web is restarting.\n\nGenerated from the datadoghq.com/monitors annotation of the Deployment. @slack-shop", spec.Message)
+	assert.Equal(t, []string{"kube_namespace:shop"}, spec.Tags)
+	assert.Equal(t, float64(5), spec.Options.Thresholds.Critical)
+	assert.Equal(t, float64(2), spec.Options.Thresholds.Warning)
+}
+
+func TestPresetDefaults(t *testing.T) {
+	// Every preset is valid with its default thresholds on each of its kinds
+	for name, preset := range monitorPresets {
+		for _, kind := range preset.kinds {
+			spec, err := preset.spec(name, presetWorkload(kind, name))
+			assert.Nil(t, err, "%v on a %v", name, kind)
+			assert.Empty(t, spec.Validate(), "%v on a %v", name, kind)
+		}
+	}
+}
+
+func TestPresetThreshold(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        float64
+		err         bool
+	}{
+		{"defaults without the annotation", map[string]string{}, 5, false},
+		{"reads a number", map[string]string{"datadoghq.com/monitor.restarts.critical": "0.25"}, 0.25, false},
+		{"reads zero", map[string]string{"datadoghq.com/monitor.restarts.critical": "0"}, 0, false},
+		{"ignores other thresholds", map[string]string{"datadoghq.com/monitor.restarts.warning": "2"}, 5, false},
+		{"rejects anything else", map[string]string{"datadoghq.com/monitor.restarts.critical": "5%"}, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := presetWorkload("Deployment", "restarts")
+			w.annotations = test.annotations
+
+			value, err := presetThreshold(w, "restarts", "critical", 5)
+			assert.Equal(t, test.err, err != nil)
+			assert.Equal(t, test.want, value)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"regexp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+const (
+	// How long to wait before checking again for a referenced resource that isn't ready
+	dependencyRequeueDelay = 30 * time.Second
+
+	referenceKindMonitor = "monitor"
+	referenceKindSLO     = "slo"
+)
+
+// Matches a reference to another resource in a monitor query, e.g.
+// `${monitor:my-monitor}` or `${slo:my-slo}`
+var referencePattern = regexp.MustCompile(`\$\{(monitor|slo):([a-z0-9]([-a-z0-9.]*[a-z0-9])?)\}`)
+
+type reference struct {
+	Kind string
+	Name string
+}
+
+// referenceError is a reference that can't be resolved until the resource or
+// the referenced resource changes, e.g. because they belong to different
+// Datadog organizations
+type referenceError struct {
+	message string
+}
+
+func (e *referenceError) Error() string {
+	return e.message
+}
+
+// isReferenceError reports whether the error is a referenceError, so waiting
+// for the referenced resource doesn't help
+func isReferenceError(err error) bool {
+	var refErr *referenceError
+	return errors.As(err, &refErr)
+}
+
+// queryReferences returns the resources referenced in a monitor query
+func queryReferences(query string) []reference {
+	references := []reference{}
+
+	for _, match := range referencePattern.FindAllStringSubmatch(query, -1) {
+		references = append(references, reference{Kind: match[1], Name: match[2]})
+	}
+
+	return references
+}
+
+// referencesResource reports whether a monitor query references the
+// resource of the given kind and name
+func referencesResource(query string, kind string, name string) bool {
+	for _, ref := range queryReferences(query) {
+		if ref.Kind == kind && ref.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveQuery replaces the references in a monitor query with the Datadog
+// IDs of the referenced resources in the given namespace. It fails if any of
+// them doesn't exist, isn't created in Datadog yet or uses other credentials.
+func resolveQuery(ctx context.Context, c client.Client, namespace string, credentialsRef string, query string) (string, error) {
+	var resolveErr error
+
+	resolved := referencePattern.ReplaceAllStringFunc(query, func(placeholder string) string {
+		if resolveErr != nil {
+			return placeholder
+		}
+
+		match := referencePattern.FindStringSubmatch(placeholder)
+
+		if match[1] == referenceKindSLO {
+			sloId, err := sloIdFromRef(ctx, c, namespace, credentialsRef, match[2])
+			resolveErr = err
+			return sloId
+		}
+
+		monitorId, err := monitorIdFromRef(ctx, c, namespace, credentialsRef, match[2])
+		resolveErr = err
+		return fmt.Sprint(monitorId)
+	})
+
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+
+	return resolved, nil
+}
+
+// monitorIdFromRef returns the Datadog ID of a DatadogMonitor in the given
+// namespace. It fails if the monitor doesn't exist or isn't created in Datadog
+// yet. IDs are only unique within a Datadog organization, so the monitor must
+// use the same credentials_ref as the resource referencing it.
+func monitorIdFromRef(ctx context.Context, c client.Client, namespace string, credentialsRef string, ref string) (int64, error) {
+	monitor := &datadoghqcomv1beta1.DatadogMonitor{}
+	name := types.NamespacedName{Namespace: namespace, Name: ref}
+
+	if err := c.Get(ctx, name, monitor); err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, fmt.Errorf("DatadogMonitor %v not found", name)
+		}
+		return 0, err
+	}
+
+	if monitor.Spec.CredentialsRef != credentialsRef {
+		return 0, &referenceError{fmt.Sprintf("DatadogMonitor %v has credentials_ref %q instead of %q", name, monitor.Spec.CredentialsRef, credentialsRef)}
+	}
+
+	if monitor.Status.Id == 0 {
+		return 0, fmt.Errorf("DatadogMonitor %v is not created in Datadog yet", name)
+	}
+
+	return monitor.Status.Id, nil
+}
+
+// sloIdFromRef returns the Datadog ID of a DatadogSLO in the given namespace.
+// It fails if the SLO doesn't exist, isn't created in Datadog yet or uses
+// another credentials_ref.
+func sloIdFromRef(ctx context.Context, c client.Client, namespace string, credentialsRef string, ref string) (string, error) {
+	slo := &datadoghqcomv1beta1.DatadogSLO{}
+	name := types.NamespacedName{Namespace: namespace, Name: ref}
+
+	if err := c.Get(ctx, name, slo); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("DatadogSLO %v not found", name)
+		}
+		return "", err
+	}
+
+	if slo.Spec.CredentialsRef != credentialsRef {
+		return "", &referenceError{fmt.Sprintf("DatadogSLO %v has credentials_ref %q instead of %q", name, slo.Spec.CredentialsRef, credentialsRef)}
+	}
+
+	if slo.Status.Id == "" {
+		return "", fmt.Errorf("DatadogSLO %v is not created in Datadog yet", name)
+	}
+
+	return slo.Status.Id, nil
+}
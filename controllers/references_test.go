@@ -0,0 +1,181 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+)
+
+func TestQueryReferences(t *testing.T) {
+	tests := []struct {
+		query      string
+		references []reference
+	}{
+		{"avg(last_5m):avg:system.cpu.user{*} > 90", []reference{}},
+		{"${monitor:web-cpu} && ${monitor:web.memory-2}", []reference{{Kind: "monitor", Name: "web-cpu"}, {Kind: "monitor", Name: "web.memory-2"}}},
+		{"${slo:checkout} || !${monitor:a}", []reference{{Kind: "slo", Name: "checkout"}, {Kind: "monitor", Name: "a"}}},
+		// Names must be valid resource names and kinds must be known
+		{"${monitor:Web} && ${monitor:-web} && ${downtime:web} && ${monitor:}", []reference{}},
+		{"$monitor:web && {monitor:web}", []reference{}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.references, queryReferences(test.query), test.query)
+	}
+}
+
+func TestReferencesResource(t *testing.T) {
+	query := "${monitor:web-cpu} && ${slo:web}"
+
+	assert.True(t, referencesResource(query, referenceKindMonitor, "web-cpu"))
+	assert.True(t, referencesResource(query, referenceKindSLO, "web"))
+	assert.False(t, referencesResource(query, referenceKindMonitor, "web"))
+	assert.False(t, referencesResource(query, referenceKindSLO, "web-cpu"))
+	assert.False(t, referencesResource(query, referenceKindMonitor, "web-c"))
+}
+
+// referencedMonitor returns a DatadogMonitor in the default namespace that
+// was created in Datadog with the given ID, or not yet if it's 0
+func referencedMonitor(name string, monitorId int64, query string) *datadoghqcomv1beta1.DatadogMonitor {
+	return &datadoghqcomv1beta1.DatadogMonitor{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: datadoghqcomv1beta1.DatadogMonitorSpec{
+			Name:  name,
+			Type:  "composite",
+			Query: query,
+		},
+		Status: datadoghqcomv1beta1.DatadogMonitorStatus{Id: monitorId},
+	}
+}
+
+func TestResolveQuery(t *testing.T) {
+	assert.Nil(t, datadoghqcomv1beta1.AddToScheme(scheme.Scheme))
+
+	slo := &datadoghqcomv1beta1.DatadogSLO{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"},
+		Status:     datadoghqcomv1beta1.DatadogSLOStatus{Id: "abc123"},
+	}
+	c := fakeclient.NewFakeClientWithScheme(scheme.Scheme,
+		referencedMonitor("cpu", 11, "avg(last_5m):avg:system.cpu.user{*} > 90"),
+		referencedMonitor("memory", 12, "avg(last_5m):avg:system.mem.used{*} > 90"),
+		referencedMonitor("pending", 0, "avg(last_5m):avg:system.load.1{*} > 5"),
+		slo,
+	)
+	ctx := context.Background()
+
+	resolved, err := resolveQuery(ctx, c, "default", "", "${monitor:cpu} && !${monitor:memory} && ${monitor:cpu}")
+	assert.Nil(t, err)
+	assert.Equal(t, "11 && !12 && 11", resolved)
+
+	resolved, err = resolveQuery(ctx, c, "default", "", "error_budget(\"${slo:checkout}\").over(\"7d\") > 10")
+	assert.Nil(t, err)
+	assert.Equal(t, "error_budget(\"abc123\").over(\"7d\") > 10", resolved)
+
+	resolved, err = resolveQuery(ctx, c, "default", "", "avg(last_5m):avg:system.cpu.user{*} > 90")
+	assert.Nil(t, err)
+	assert.Equal(t, "avg(last_5m):avg:system.cpu.user{*} > 90", resolved)
+
+	_, err = resolveQuery(ctx, c, "default", "", "${monitor:cpu} && ${monitor:pending}")
+	assert.EqualError(t, err, "DatadogMonitor default/pending is not created in Datadog yet")
+
+	_, err = resolveQuery(ctx, c, "default", "", "${monitor:cpu} && ${monitor:missing}")
+	assert.EqualError(t, err, "DatadogMonitor default/missing not found")
+
+	_, err = resolveQuery(ctx, c, "other", "", "${monitor:cpu}")
+	assert.EqualError(t, err, "DatadogMonitor other/cpu not found")
+
+	_, err = resolveQuery(ctx, c, "default", "", "error_budget(\"${slo:missing}\").over(\"7d\") > 10")
+	assert.EqualError(t, err, "DatadogSLO default/missing not found")
+}
+
+func TestResolveQueryOtherCredentials(t *testing.T) {
+	assert.Nil(t, datadoghqcomv1beta1.AddToScheme(scheme.Scheme))
+
+	teamA := referencedMonitor("team-a", 11, "avg(last_5m):avg:system.cpu.user{*} > 90")
+	teamA.Spec.CredentialsRef = "team-a"
+	slo := &datadoghqcomv1beta1.DatadogSLO{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"},
+		Status:     datadoghqcomv1beta1.DatadogSLOStatus{Id: "abc123"},
+	}
+	c := fakeclient.NewFakeClientWithScheme(scheme.Scheme,
+		teamA,
+		referencedMonitor("default-org", 12, "avg(last_5m):avg:system.mem.used{*} > 90"),
+		slo,
+	)
+	ctx := context.Background()
+
+	resolved, err := resolveQuery(ctx, c, "default", "team-a", "${monitor:team-a}")
+	assert.Nil(t, err)
+	assert.Equal(t, "11", resolved)
+
+	_, err = resolveQuery(ctx, c, "default", "team-a", "${monitor:team-a} && ${monitor:default-org}")
+	assert.EqualError(t, err, `DatadogMonitor default/default-org has credentials_ref "" instead of "team-a"`)
+	assert.True(t, isReferenceError(err))
+
+	_, err = resolveQuery(ctx, c, "default", "", "${monitor:team-a}")
+	assert.EqualError(t, err, `DatadogMonitor default/team-a has credentials_ref "team-a" instead of ""`)
+	assert.True(t, isReferenceError(err))
+
+	_, err = resolveQuery(ctx, c, "default", "team-a", "error_budget(\"${slo:checkout}\").over(\"7d\") > 10")
+	assert.EqualError(t, err, `DatadogSLO default/checkout has credentials_ref "" instead of "team-a"`)
+	assert.True(t, isReferenceError(err))
+
+	// Waiting for a resource to be created isn't a reference error
+	_, err = resolveQuery(ctx, c, "default", "team-a", "${monitor:missing}")
+	assert.False(t, isReferenceError(err))
+}
+
+func TestRequestsForReferences(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	cpu := referencedMonitor("cpu", 11, "avg(last_5m):avg:system.cpu.user{*} > 90")
+	composite := referencedMonitor("composite", 13, "${monitor:cpu} && ${monitor:memory}")
+	budget := referencedMonitor("budget", 14, "error_budget(\"${slo:checkout}\").over(\"7d\") > 10")
+	r := newTestMonitorReconciler(t, server, cpu, composite, budget)
+
+	request := func(name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}}
+	}
+
+	// A referenced monitor changed
+	requests := r.requestsForReferences(referenceKindMonitor)(handler.MapObject{Meta: cpu, Object: cpu})
+	assert.Equal(t, []reconcile.Request{request("composite")}, requests)
+
+	// A composite changed, e.g. it's deleted and no longer blocks its references
+	requests = r.requestsForReferences(referenceKindMonitor)(handler.MapObject{Meta: composite, Object: composite})
+	assert.Equal(t, []reconcile.Request{request("cpu"), request("memory")}, requests)
+
+	slo := &datadoghqcomv1beta1.DatadogSLO{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"}}
+	requests = r.requestsForReferences(referenceKindSLO)(handler.MapObject{Meta: slo, Object: slo})
+	assert.Equal(t, []reconcile.Request{request("budget")}, requests)
+
+	// An SLO using monitors changed, e.g. it's deleted and no longer blocks them
+	slo.Spec.MonitorRefs = []string{"cpu"}
+	requests = r.requestsForReferences(referenceKindSLO)(handler.MapObject{Meta: slo, Object: slo})
+	assert.Equal(t, []reconcile.Request{request("budget"), request("cpu")}, requests)
+}
@@ -24,6 +24,7 @@ import (
 	. "github.com/onsi/gomega"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
@@ -31,6 +32,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -40,6 +47,8 @@ import (
 var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
+var fakeDatadog *fake.Server
+var stopManager chan struct{}
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -54,7 +63,8 @@ var _ = BeforeSuite(func(done Done) {
 
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths: []string{filepath.Join("..", "config", "crd", "bases")},
+		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
 	}
 
 	var err error
@@ -71,11 +81,98 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).ToNot(HaveOccurred())
 	Expect(k8sClient).ToNot(BeNil())
 
+	By("starting the controllers against a fake Datadog API")
+	fakeDatadog = fake.NewServer()
+	datadogOptions := []datadog.Option{datadog.WithBaseURL(fakeDatadog.URL)}
+	datadogApi, err := datadog.NewForCredentials("INFO", datadog.Credentials{}, datadogOptions...)
+	Expect(err).ToNot(HaveOccurred())
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: scheme.Scheme, MetricsBindAddress: "0"})
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&DatadogMonitorReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("DatadogMonitor"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("datadog-controller"),
+		Datadog:  &DatadogClients{Default: datadogApi, LogLevel: "INFO", Options: datadogOptions},
+	}).SetupWithManager(mgr)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&DatadogMonitorTemplateReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("DatadogMonitorTemplate"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("datadog-controller"),
+	}).SetupWithManager(mgr)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&WorkloadMonitorReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("DeploymentMonitors"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("datadog-controller"),
+		Kind:     "Deployment",
+	}).SetupWithManager(mgr)
+	Expect(err).ToNot(HaveOccurred())
+
+	stopManager = make(chan struct{})
+	go func() {
+		defer GinkgoRecover()
+		Expect(mgr.Start(stopManager)).To(Succeed())
+	}()
+
 	close(done)
 }, 60)
 
 var _ = AfterSuite(func() {
 	By("tearing down the test environment")
+	close(stopManager)
+	fakeDatadog.Close()
 	err := testEnv.Stop()
 	Expect(err).ToNot(HaveOccurred())
 })
+
+// newTestMonitorReconciler returns a DatadogMonitorReconciler with a fake
+// client holding objects and a client of the fake Datadog API server, for
+// tests of single reconciles that don't need the test environment
+func newTestMonitorReconciler(t *testing.T, server *fake.Server, objects ...runtime.Object) *DatadogMonitorReconciler {
+	assert.Nil(t, datadoghqcomv1beta1.AddToScheme(scheme.Scheme))
+
+	datadogApi, err := datadog.NewForCredentials("INFO", datadog.Credentials{}, datadog.WithBaseURL(server.URL))
+	assert.Nil(t, err)
+
+	return &DatadogMonitorReconciler{
+		Client:   fakeclient.NewFakeClientWithScheme(scheme.Scheme, objects...),
+		Log:      ctrl.Log.WithName("controllers").WithName("DatadogMonitor"),
+		Scheme:   scheme.Scheme,
+		Recorder: record.NewFakeRecorder(100),
+		Datadog:  &DatadogClients{Default: datadogApi, LogLevel: "INFO"},
+	}
+}
+
+// newTestDowntimeReconciler is newTestMonitorReconciler for DatadogDowntimes
+func newTestDowntimeReconciler(t *testing.T, server *fake.Server, objects ...runtime.Object) *DatadogDowntimeReconciler {
+	r := newTestMonitorReconciler(t, server, objects...)
+
+	return &DatadogDowntimeReconciler{
+		Client:   r.Client,
+		Log:      ctrl.Log.WithName("controllers").WithName("DatadogDowntime"),
+		Scheme:   r.Scheme,
+		Recorder: r.Recorder,
+		Datadog:  r.Datadog.(*DatadogClients),
+	}
+}
+
+// newTestSLOReconciler is newTestMonitorReconciler for DatadogSLOs
+func newTestSLOReconciler(t *testing.T, server *fake.Server, objects ...runtime.Object) *DatadogSLOReconciler {
+	r := newTestMonitorReconciler(t, server, objects...)
+
+	return &DatadogSLOReconciler{
+		Client:   r.Client,
+		Log:      ctrl.Log.WithName("controllers").WithName("DatadogSLO"),
+		Scheme:   r.Scheme,
+		Recorder: r.Recorder,
+		Datadog:  r.Datadog.(*DatadogClients),
+	}
+}
@@ -0,0 +1,226 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"strings"
+)
+
+// WorkloadMonitorReconciler generates a DatadogMonitor for each monitor
+// preset in the annotations of a Deployment, StatefulSet or Service. The
+// monitors are owned by the workload so they are deleted along with it, and
+// are applied to Datadog by the DatadogMonitorReconciler.
+type WorkloadMonitorReconciler struct {
+	client.Client
+	stopContext
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Deployment, StatefulSet or Service
+	Kind string
+}
+
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+// +kubebuilder:rbac:groups=datadoghq.com,resources=datadogmonitors,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *WorkloadMonitorReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx, cancel := r.newContext()
+	defer cancel()
+
+	log := r.Log.WithValues(strings.ToLower(r.Kind), req.NamespacedName)
+
+	instance, err := r.newObject()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	log.V(1).Info("Getting resource from cluster")
+	if err := r.Get(ctx, req.NamespacedName, instance); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	meta := instance.(metav1.Object)
+
+	// The generated monitors are deleted by Kubernetes through their owner
+	// reference
+	if !meta.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	existing := &datadoghqcomv1beta1.DatadogMonitorList{}
+	if err := r.List(ctx, existing, client.InNamespace(req.Namespace), client.HasLabels{datadoghqcomv1beta1.MonitorPresetLabel}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	owned := map[string]*datadoghqcomv1beta1.DatadogMonitor{}
+	for i := range existing.Items {
+		if owner := metav1.GetControllerOf(&existing.Items[i]); owner != nil && owner.UID == meta.GetUID() {
+			owned[existing.Items[i].Labels[datadoghqcomv1beta1.MonitorPresetLabel]] = &existing.Items[i]
+		}
+	}
+
+	specs, err := presetSpecs(workload{
+		kind:        r.Kind,
+		namespace:   meta.GetNamespace(),
+		name:        meta.GetName(),
+		labels:      meta.GetLabels(),
+		annotations: meta.GetAnnotations(),
+	})
+	if err != nil {
+		// The existing monitors are kept until the annotations are fixed
+		log.Info(fmt.Sprintf("Invalid monitor annotations: %v", err))
+		r.Recorder.Eventf(instance, "Warning", "FailedMonitorPreset", fmt.Sprint(err))
+		return ctrl.Result{}, nil
+	}
+
+	for preset, spec := range specs {
+		err := r.applyMonitor(ctx, log, instance, preset, spec, owned[preset])
+		if apierrors.IsAlreadyExists(err) {
+			// Retried once the workload changes
+			message := fmt.Sprintf("A DatadogMonitor %v not generated for preset %v already exists", presetMonitorName(r.Kind, meta.GetName(), preset), preset)
+			log.Info(message)
+			r.Recorder.Eventf(instance, "Warning", "FailedMonitorPreset", message)
+			continue
+		}
+		if err != nil {
+			log.Error(err, fmt.Sprintf("Failed to apply monitor preset %v", preset))
+			r.Recorder.Eventf(instance, "Warning", "FailedMonitorPreset", fmt.Sprintf("Failed to apply monitor preset %v: %v", preset, err))
+			return ctrl.Result{}, err
+		}
+	}
+
+	for preset, monitor := range owned {
+		if _, ok := specs[preset]; ok {
+			continue
+		}
+
+		log.Info(fmt.Sprintf("Deleting monitor %v as preset %v was removed", monitor.Name, preset))
+		if err := r.Delete(ctx, monitor); err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Eventf(instance, "Normal", "SuccessfulDelete", fmt.Sprintf("Deleted monitor %v as preset %v was removed", monitor.Name, preset))
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// applyMonitor creates the DatadogMonitor of a preset or updates it if it
+// differs from the preset
+func (r *WorkloadMonitorReconciler) applyMonitor(ctx context.Context, log logr.Logger, instance runtime.Object, preset string, spec datadoghqcomv1beta1.DatadogMonitorSpec, monitor *datadoghqcomv1beta1.DatadogMonitor) error {
+	meta := instance.(metav1.Object)
+
+	if monitor == nil {
+		monitor = &datadoghqcomv1beta1.DatadogMonitor{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: meta.GetNamespace(),
+				Name:      presetMonitorName(r.Kind, meta.GetName(), preset),
+				Labels:    map[string]string{datadoghqcomv1beta1.MonitorPresetLabel: preset},
+			},
+			Spec: spec,
+		}
+		if err := ctrl.SetControllerReference(meta, monitor, r.Scheme); err != nil {
+			return err
+		}
+
+		log.Info(fmt.Sprintf("Creating monitor %v for preset %v", monitor.Name, preset))
+		if err := r.Create(ctx, monitor); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(instance, "Normal", "SuccessfulCreate", fmt.Sprintf("Created monitor %v for preset %v", monitor.Name, preset))
+
+		return nil
+	}
+
+	if equality.Semantic.DeepEqual(monitor.Spec, spec) {
+		return nil
+	}
+
+	log.Info(fmt.Sprintf("Updating monitor %v for preset %v", monitor.Name, preset))
+	monitor.Spec = spec
+	if err := r.Update(ctx, monitor); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(instance, "Normal", "SuccessfulUpdate", fmt.Sprintf("Updated monitor %v for preset %v", monitor.Name, preset))
+
+	return nil
+}
+
+// presetMonitorName returns the name of the DatadogMonitor generated for a
+// preset of a workload, e.g. deployment-web-restarts
+func presetMonitorName(kind string, name string, preset string) string {
+	return shortenMonitorName(fmt.Sprintf("%v-%v-%v", strings.ToLower(kind), name, preset))
+}
+
+// newObject returns an empty object of the kind of workload
+func (r *WorkloadMonitorReconciler) newObject() (runtime.Object, error) {
+	switch r.Kind {
+	case "Deployment":
+		return &appsv1.Deployment{}, nil
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}, nil
+	case "Service":
+		return &corev1.Service{}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported workload kind %q", r.Kind)
+}
+
+// annotationsChanged filters out the frequent status updates of workloads,
+// only the labels and annotations are used for presets
+func annotationsChanged(e event.UpdateEvent) bool {
+	if _, ok := e.ObjectNew.(*datadoghqcomv1beta1.DatadogMonitor); ok {
+		return true
+	}
+
+	return !reflect.DeepEqual(e.MetaOld.GetAnnotations(), e.MetaNew.GetAnnotations()) ||
+		!reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels())
+}
+
+func (r *WorkloadMonitorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	object, err := r.newObject()
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(strings.ToLower(r.Kind) + "-monitors").
+		For(object).
+		Owns(&datadoghqcomv1beta1.DatadogMonitor{}).
+		WithEventFilter(predicate.Funcs{UpdateFunc: annotationsChanged}).
+		Complete(r)
+}
@@ -0,0 +1,92 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"time"
+)
+
+var _ = Describe("Workload monitor controller", func() {
+	const timeout = 10 * time.Second
+
+	It("generates monitors for the presets in the annotations of a Deployment", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Namespace: "default", Name: "test-web"}
+		podLabels := map[string]string{"app": "test-web"}
+
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+				Annotations: map[string]string{
+					datadoghqcomv1beta1.MonitorPresetsAnnotation:                            "restarts,unavailable-replicas",
+					datadoghqcomv1beta1.MonitorPresetAnnotationPrefix + "restarts.critical": "10",
+					datadoghqcomv1beta1.MonitorNotifyAnnotation:                             "@slack-my-team",
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx"}}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+
+		restartsKey := types.NamespacedName{Namespace: key.Namespace, Name: "deployment-test-web-restarts"}
+		monitor := &datadoghqcomv1beta1.DatadogMonitor{}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, restartsKey, monitor)
+		}, timeout).Should(Succeed())
+
+		Expect(monitor.Spec.Query).To(Equal("change(max(last_5m),last_5m):sum:kubernetes.containers.restarts{kube_namespace:default,kube_deployment:test-web} > 10"))
+		Expect(monitor.Spec.Message).To(ContainSubstring("@slack-my-team"))
+		Expect(metav1.IsControlledBy(monitor, deployment)).To(BeTrue())
+
+		Eventually(func() int64 {
+			_ = k8sClient.Get(ctx, restartsKey, monitor)
+			return monitor.Status.Id
+		}, timeout).ShouldNot(BeZero())
+
+		Eventually(func() error {
+			if err := k8sClient.Get(ctx, key, deployment); err != nil {
+				return err
+			}
+			deployment.Annotations[datadoghqcomv1beta1.MonitorPresetsAnnotation] = "unavailable-replicas"
+			return k8sClient.Update(ctx, deployment)
+		}, timeout).Should(Succeed())
+
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, restartsKey, monitor)
+			return apierrors.IsNotFound(err) || !monitor.DeletionTimestamp.IsZero()
+		}, timeout).Should(BeTrue())
+
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: key.Namespace, Name: "deployment-test-web-unavailable-replicas"}, monitor)).To(Succeed())
+
+		Expect(k8sClient.Delete(ctx, deployment)).To(Succeed())
+	})
+})
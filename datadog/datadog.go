@@ -1,19 +1,23 @@
 package datadog
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-logr/logr"
 	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
 	"github.com/max-rocket-internet/datadog-controller/datadog/restclient"
 	"github.com/max-rocket-internet/datadog-controller/utils"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,73 +26,211 @@ type ApiKeyValidationResponse struct {
 	Error []string `json:"errors"`
 }
 
+type OrganizationResponse struct {
+	Orgs []struct {
+		PublicId string `json:"public_id"`
+	} `json:"orgs"`
+}
+
 type MonitorDeleteResponse struct {
 	DeletedMonitorId int      `json:"deleted_monitor_id"`
 	Error            []string `json:"errors"`
 }
 
+type MonitorValidationResponse struct {
+	Errors []string `json:"errors"`
+}
+
+// A monitor as returned by the Datadog API, including its current state
+type Monitor struct {
+	v1beta1.DatadogMonitorSpec
+	// One of OK, Alert, Warn, No Data, Skipped, Ignored or Unknown
+	OverallState string       `json:"overall_state"`
+	State        MonitorState `json:"state"`
+}
+
+type MonitorState struct {
+	Groups map[string]MonitorGroupState `json:"groups"`
+}
+
+type MonitorGroupState struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Unix timestamp of when the group last triggered
+	LastTriggeredTs int64 `json:"last_triggered_ts"`
+}
+
+// Filters for listing monitors. Empty fields match all monitors.
+type MonitorFilter struct {
+	// Only monitors whose name contains this string
+	Name string
+	// Only monitors with all of these monitor tags, e.g. "service:my-service"
+	Tags []string
+	// Only monitors whose query contains this string. Datadog can't filter on
+	// the query so this is applied after listing.
+	Query string
+}
+
 type Config struct {
-	datadogApiKey      string
-	datadogAppKey      string
 	DatadogHost        string
 	datadogApiEndpoint string
 	LogLevel           string
 	apiBase            string
+	// How long a call to the API may take, including retries. Callers can
+	// set a shorter deadline on the context of a call.
+	RequestTimeout time.Duration
+}
+
+// Credentials of a Datadog organization
+type Credentials struct {
+	ApiKey string
+	AppKey string
+	// The Datadog site of the organization, e.g. datadoghq.com. Defaults to DATADOG_HOST.
+	Host string
 }
 
 type Datadog struct {
 	Log  logr.Logger
 	Conf *Config
+	// The http.Header with the keys sent with every request. It's shared by
+	// copies of the client so they all use rotated keys.
+	headers *atomic.Value
+	// The rate limits of the organization, shared by copies of the client
+	limits *rateLimits
+	// The organization once it's read from Datadog, shared by copies of the
+	// client
+	org     *atomic.Value
+	client  *restclient.Client
+	metrics *metrics
+}
+
+// MonitorAPI creates, reads, updates and deletes monitors, including their ownership tags
+type MonitorAPI interface {
+	GetMonitor(ctx context.Context, MonitorId int64) (Monitor, error)
+	ListMonitors(ctx context.Context, Filter MonitorFilter) ([]v1beta1.DatadogMonitorSpec, error)
+	SearchMonitors(ctx context.Context, Query string) ([]v1beta1.DatadogMonitorSpec, error)
+	FindOwnedMonitor(ctx context.Context, owner Owner) (int64, error)
+	CreateMonitor(ctx context.Context, MonitorSpec v1beta1.DatadogMonitorSpec, owner Owner) (int64, error)
+	UpdateMonitor(ctx context.Context, MonitorId int64, MonitorSpec v1beta1.DatadogMonitorSpec, owner Owner) error
+	DeleteMonitor(ctx context.Context, MonitorId int64) error
+	DisownMonitor(ctx context.Context, MonitorId int64) error
+	ValidateMonitor(ctx context.Context, MonitorSpec v1beta1.DatadogMonitorSpec) ([]string, error)
+	// The URL of the monitor in the Datadog app
+	MonitorURL(MonitorId int64) string
+	// Identifies the organization the monitors belong to
+	Organization(ctx context.Context) (string, error)
+}
+
+// DowntimeAPI schedules and cancels downtimes of a monitor
+type DowntimeAPI interface {
+	CreateDowntime(ctx context.Context, DowntimeSpec v1beta1.DatadogDowntimeSpec, MonitorId int64) (int64, error)
+	UpdateDowntime(ctx context.Context, DowntimeId int64, DowntimeSpec v1beta1.DatadogDowntimeSpec, MonitorId int64) error
+	DeleteDowntime(ctx context.Context, DowntimeId int64) error
 }
 
-func newConfig() (*Config, error) {
+// SLOAPI manages metric and monitor SLOs
+type SLOAPI interface {
+	CreateSLO(ctx context.Context, SLOSpec v1beta1.DatadogSLOSpec, MonitorIds []int64) (string, error)
+	UpdateSLO(ctx context.Context, SLOId string, SLOSpec v1beta1.DatadogSLOSpec, MonitorIds []int64) error
+	DeleteSLO(ctx context.Context, SLOId string) error
+	// The URL of the SLO in the Datadog app
+	SLOURL(SLOId string) string
+}
+
+// Option configures a client
+type Option func(*options)
+
+type options struct {
+	baseURL    string
+	restclient []restclient.Option
+	registerer prometheus.Registerer
+}
+
+// WithBaseURL sends requests to another URL than the API of the Datadog
+// site, e.g. a fake of the API
+func WithBaseURL(baseURL string) Option {
+	return func(o *options) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithHTTPClient makes requests with httpClient, e.g. a mock, instead of
+// retrying them with the retry policy
+func WithHTTPClient(httpClient restclient.HTTPClient) Option {
+	return func(o *options) {
+		o.restclient = append(o.restclient, restclient.WithHTTPClient(httpClient))
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(policy restclient.RetryPolicy) Option {
+	return func(o *options) {
+		o.restclient = append(o.restclient, restclient.WithRetryPolicy(policy))
+	}
+}
+
+// WithRegisterer sets the registry the metrics of the client are registered
+// with, prometheus.DefaultRegisterer by default
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(o *options) {
+		o.registerer = registerer
+	}
+}
+
+func credentialsFromEnv() (Credentials, error) {
+	creds := Credentials{}
+
 	if err := utils.CheckRequiredEnvVars([]string{"DD_CLIENT_API_KEY", "DD_CLIENT_APP_KEY"}); err != nil {
-		return nil, err
+		return creds, err
 	}
 
+	creds.ApiKey, _ = utils.GetEnvString("DD_CLIENT_API_KEY")
+	creds.AppKey, _ = utils.GetEnvString("DD_CLIENT_APP_KEY")
+
+	return creds, nil
+}
+
+func configFor(Host string) *Config {
 	c := &Config{}
-	c.datadogApiKey, _ = utils.GetEnvString("DD_CLIENT_API_KEY")
-	c.datadogAppKey, _ = utils.GetEnvString("DD_CLIENT_APP_KEY")
-	c.DatadogHost, _ = utils.GetEnvString("DATADOG_HOST", "datadoghq.eu")
+	c.DatadogHost = Host
+	if c.DatadogHost == "" {
+		c.DatadogHost, _ = utils.GetEnvString("DATADOG_HOST", "datadoghq.eu")
+	}
 	c.LogLevel, _ = utils.GetEnvString("LOG_LEVEL", "INFO")
 	c.apiBase, _ = utils.GetEnvString("API_BASE", "/api/v1")
 	c.datadogApiEndpoint = fmt.Sprintf("https://api.%s%s", c.DatadogHost, c.apiBase)
 
-	return c, nil
+	requestTimeout, _ := utils.GetEnvString("DATADOG_REQUEST_TIMEOUT", "1m")
+	c.RequestTimeout, _ = time.ParseDuration(requestTimeout)
+	if c.RequestTimeout <= 0 {
+		c.RequestTimeout = time.Minute
+	}
+
+	return c
 }
 
 var (
 	httpUserAgent = "github/max-rocket-internet/datadog-controller/1.0"
-	httpHeaders   = http.Header{}
-
-	apiLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
-		Namespace: "datadog_controller",
-		Subsystem: "api",
-		Name:      "latency",
-		Help:      "Latency of the Datadog API",
-	}, []string{
-		"endpoint",
-		"response",
-	})
-
-	monitorEventCounter = promauto.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "datadog_controller",
-		Subsystem: "monitor",
-		Name:      "event",
-		Help:      "Count of monitor create/delete events",
-	}, []string{
-		"action",
-	})
 )
 
-func (d Datadog) validateApiKey() error {
+func headersFor(Creds Credentials) http.Header {
+	headers := http.Header{}
+	headers.Add("DD-API-KEY", Creds.ApiKey)
+	headers.Add("DD-APPLICATION-KEY", Creds.AppKey)
+
+	return headers
+}
+
+func (d Datadog) validateApiKey(ctx context.Context, headers http.Header) error {
 	d.Log.V(1).Info("Testing API token")
 
 	response := ApiKeyValidationResponse{}
 
-	results, _, err := d.apiRequest("GET", "/validate", nil)
-	if err != nil {
-		return fmt.Errorf("Error validating API key: %v", err)
+	// Invalid keys get an error response, the body says whether the key is valid
+	results, _, err := d.request(ctx, headers, "GET", "/validate", nil)
+	var apiErr *APIError
+	if err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode < 500) {
+		return fmt.Errorf("Error validating API key: %w", err)
 	}
 
 	err = json.Unmarshal(results, &response)
@@ -104,18 +246,18 @@ func (d Datadog) validateApiKey() error {
 	}
 }
 
-func (d Datadog) DeleteMonitor(MonitorId int64) error {
+func (d Datadog) DeleteMonitor(ctx context.Context, MonitorId int64) error {
 	d.Log.V(1).Info(fmt.Sprintf("Deleting monitor %v", MonitorId))
 
 	response := MonitorDeleteResponse{}
 
-	results, responseCode, err := d.apiRequest("DELETE", fmt.Sprintf("/monitor/%v", MonitorId), nil)
-	if err != nil {
-		return err
+	results, _, err := d.apiRequest(ctx, "DELETE", fmt.Sprintf("/monitor/%v", MonitorId), nil)
+	if IsNotFound(err) {
+		d.Log.V(1).Info(fmt.Sprintf("Monitor %v already deleted", MonitorId))
+		return nil
 	}
-
-	if responseCode != 404 && responseCode != 200 {
-		return fmt.Errorf("Error deleting monitor: %v", string(results))
+	if err != nil {
+		return fmt.Errorf("Error deleting monitor: %w", err)
 	}
 
 	err = json.Unmarshal(results, &response)
@@ -123,116 +265,351 @@ func (d Datadog) DeleteMonitor(MonitorId int64) error {
 		return err
 	}
 
-	monitorEventCounter.WithLabelValues("deleted").Inc()
+	d.metrics.monitorEvents.WithLabelValues("deleted").Inc()
 
 	return nil
 }
 
-func (d Datadog) CreateMonitor(MonitorSpec v1beta1.DatadogMonitorSpec) (int64, error) {
-	d.Log.V(1).Info(fmt.Sprintf("Creating monitor '%v'", MonitorSpec.Name))
+func (d Datadog) GetMonitor(ctx context.Context, MonitorId int64) (Monitor, error) {
+	d.Log.V(1).Info(fmt.Sprintf("Getting monitor %v", MonitorId))
 
-	requestBody, _ := json.Marshal(MonitorSpec)
+	monitor := Monitor{}
 
-	results, responseCode, err := d.apiRequest("POST", "/monitor", requestBody)
+	results, _, err := d.apiRequest(ctx, "GET", fmt.Sprintf("/monitor/%v?group_states=all", MonitorId), nil)
 	if err != nil {
-		monitorEventCounter.WithLabelValues("failed").Inc()
-		return 0, err
+		return monitor, fmt.Errorf("Error getting monitor '%v': %w", MonitorId, err)
 	}
 
-	if responseCode == 400 {
-		monitorEventCounter.WithLabelValues("failed").Inc()
-		return 0, fmt.Errorf("Error creating monitor '%v': %v", MonitorSpec.Name, string(results))
+	err = json.Unmarshal(results, &monitor)
+	if err != nil {
+		return monitor, err
+	}
+
+	return monitor, nil
+}
+
+func (d Datadog) ListMonitors(ctx context.Context, Filter MonitorFilter) ([]v1beta1.DatadogMonitorSpec, error) {
+	d.Log.V(1).Info(fmt.Sprintf("Listing monitors with filter %+v", Filter))
+
+	monitors := []v1beta1.DatadogMonitorSpec{}
+	pageSize := 1000
+
+	params := url.Values{}
+	params.Set("page_size", strconv.Itoa(pageSize))
+	if Filter.Name != "" {
+		params.Set("name", Filter.Name)
+	}
+	if len(Filter.Tags) > 0 {
+		params.Set("monitor_tags", strings.Join(Filter.Tags, ","))
+	}
+
+	for page := 0; ; page++ {
+		params.Set("page", strconv.Itoa(page))
+
+		results, _, err := d.apiRequest(ctx, "GET", "/monitor?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("Error listing monitors: %w", err)
+		}
+
+		pageMonitors := []v1beta1.DatadogMonitorSpec{}
+		err = json.Unmarshal(results, &pageMonitors)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, monitor := range pageMonitors {
+			if strings.Contains(monitor.Query, Filter.Query) {
+				monitors = append(monitors, monitor)
+			}
+		}
+
+		if len(pageMonitors) < pageSize {
+			break
+		}
+	}
+
+	return monitors, nil
+}
+
+// SearchMonitors finds monitors with the monitor search of Datadog, e.g.
+// `tag:"service:web"`. Only the ID, name, type, query and tags of the
+// monitors are set.
+func (d Datadog) SearchMonitors(ctx context.Context, Query string) ([]v1beta1.DatadogMonitorSpec, error) {
+	d.Log.V(1).Info(fmt.Sprintf("Searching monitors with query '%v'", Query))
+
+	monitors := []v1beta1.DatadogMonitorSpec{}
+	perPage := 100
+
+	params := url.Values{}
+	params.Set("query", Query)
+	params.Set("per_page", strconv.Itoa(perPage))
+
+	for page := 0; ; page++ {
+		params.Set("page", strconv.Itoa(page))
+
+		results, _, err := d.apiRequest(ctx, "GET", "/monitor/search?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("Error searching monitors: %w", err)
+		}
+
+		response := struct {
+			Monitors []v1beta1.DatadogMonitorSpec `json:"monitors"`
+			Metadata struct {
+				PageCount int `json:"page_count"`
+			} `json:"metadata"`
+		}{}
+		err = json.Unmarshal(results, &response)
+		if err != nil {
+			return nil, err
+		}
+
+		monitors = append(monitors, response.Monitors...)
+
+		if len(response.Monitors) == 0 || page+1 >= response.Metadata.PageCount {
+			break
+		}
+	}
+
+	return monitors, nil
+}
+
+// CreateMonitor creates a monitor tagged with the ownership tags of owner
+func (d Datadog) CreateMonitor(ctx context.Context, MonitorSpec v1beta1.DatadogMonitorSpec, owner Owner) (int64, error) {
+	d.Log.V(1).Info(fmt.Sprintf("Creating monitor '%v'", MonitorSpec.Name))
+
+	requestBody, _ := monitorRequestBody(withOwnerTags(MonitorSpec, owner))
+
+	results, _, err := d.apiRequest(ctx, "POST", "/monitor", requestBody)
+	if err != nil {
+		d.metrics.monitorEvents.WithLabelValues("failed").Inc()
+		return 0, fmt.Errorf("Error creating monitor '%v': %w", MonitorSpec.Name, err)
 	}
 
 	requestRespone := v1beta1.DatadogMonitorSpec{}
 	err = json.Unmarshal(results, &requestRespone)
 	if err != nil {
-		monitorEventCounter.WithLabelValues("failed").Inc()
+		d.metrics.monitorEvents.WithLabelValues("failed").Inc()
 		return 0, err
 	}
 
-	monitorEventCounter.WithLabelValues("created").Inc()
+	d.metrics.monitorEvents.WithLabelValues("created").Inc()
 
 	return requestRespone.Id, nil
 }
 
-func (d Datadog) UpdateMonitor(MonitorId int64, MonitorSpec v1beta1.DatadogMonitorSpec) error {
+// UpdateMonitor replaces a monitor, including its ownership tags with those
+// of owner
+func (d Datadog) UpdateMonitor(ctx context.Context, MonitorId int64, MonitorSpec v1beta1.DatadogMonitorSpec, owner Owner) error {
 	d.Log.V(1).Info(fmt.Sprintf("Updating monitor '%v'", MonitorId))
 
-	requestBody, _ := json.Marshal(MonitorSpec)
+	requestBody, _ := monitorRequestBody(withOwnerTags(MonitorSpec, owner))
 
-	results, responseCode, err := d.apiRequest("PUT", fmt.Sprintf("/monitor/%v", MonitorId), requestBody)
+	results, _, err := d.apiRequest(ctx, "PUT", fmt.Sprintf("/monitor/%v", MonitorId), requestBody)
 	if err != nil {
-		monitorEventCounter.WithLabelValues("failed").Inc()
-		return err
-	}
-
-	if responseCode == 400 {
-		monitorEventCounter.WithLabelValues("failed").Inc()
-		return fmt.Errorf("Error updating monitor '%v': %v", MonitorId, string(results))
+		d.metrics.monitorEvents.WithLabelValues("failed").Inc()
+		return fmt.Errorf("Error updating monitor '%v': %w", MonitorId, err)
 	}
 
 	requestRespone := v1beta1.DatadogMonitorSpec{}
 	err = json.Unmarshal(results, &requestRespone)
 	if err != nil {
-		monitorEventCounter.WithLabelValues("failed").Inc()
+		d.metrics.monitorEvents.WithLabelValues("failed").Inc()
 		return err
 	}
 
-	monitorEventCounter.WithLabelValues("updated").Inc()
+	d.metrics.monitorEvents.WithLabelValues("updated").Inc()
 
 	return nil
 }
 
-func (d Datadog) apiRequest(RequestMethod string, RequestPath string, RequestBody []byte) ([]byte, int, error) {
-	start := time.Now()
-	resp, err := restclient.Do(RequestMethod, d.Conf.datadogApiEndpoint+RequestPath, RequestBody, httpHeaders)
-	apiLatency.WithLabelValues(RequestPath, strconv.Itoa(resp.StatusCode)).Observe(time.Since(start).Seconds())
+// ValidateMonitor checks a monitor with Datadog without creating it. It
+// returns the reasons Datadog rejects the monitor, if any. An error is only
+// returned when the monitor could not be checked.
+func (d Datadog) ValidateMonitor(ctx context.Context, MonitorSpec v1beta1.DatadogMonitorSpec) ([]string, error) {
+	d.Log.V(1).Info("Validating monitor")
+
+	requestBody, _ := monitorRequestBody(MonitorSpec)
+
+	_, _, err := d.apiRequest(ctx, "POST", "/monitor/validate", requestBody)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == 400 {
+		return apiErr.Errors, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Error validating monitor: %w", err)
+	}
+
+	return nil, nil
+}
+
+// MonitorURL returns the URL of a monitor in the Datadog app
+func (d Datadog) MonitorURL(MonitorId int64) string {
+	return fmt.Sprintf("https://app.%v/monitors/%v", d.Conf.DatadogHost, MonitorId)
+}
+
+// Fields of the spec that only configure the controller are cleared so they
+// are never sent to Datadog
+func monitorRequestBody(MonitorSpec v1beta1.DatadogMonitorSpec) ([]byte, error) {
+	MonitorSpec.DriftPolicy = ""
+	MonitorSpec.DeletionPolicy = ""
+	MonitorSpec.CredentialsRef = ""
+
+	return json.Marshal(MonitorSpec)
+}
+
+func (d Datadog) apiRequest(ctx context.Context, RequestMethod string, RequestPath string, RequestBody []byte) ([]byte, int, error) {
+	return d.request(ctx, d.headers.Load().(http.Header), RequestMethod, RequestPath, RequestBody)
+}
+
+func (d Datadog) request(ctx context.Context, headers http.Header, RequestMethod string, RequestPath string, RequestBody []byte) ([]byte, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Conf.RequestTimeout)
+	defer cancel()
+
+	endpoint := restclient.Endpoint(RequestMethod, RequestPath)
+
+	wait, err := d.limits.wait(ctx, endpoint)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if wait > 0 {
+		d.Log.V(1).Info(fmt.Sprintf("Not making %v request for path %v as the rate limit resets in %v", RequestMethod, RequestPath, wait))
+		d.metrics.rateLimited.WithLabelValues(endpoint).Inc()
+		return nil, http.StatusTooManyRequests, &RateLimitError{Method: RequestMethod, Path: RequestPath, RetryAfter: wait}
+	}
+
+	resp, err := d.client.Do(ctx, RequestMethod, RequestPath, RequestBody, headers)
 	if err != nil {
 		d.Log.Error(err, fmt.Sprintf("Error making %v request for path %v", RequestMethod, RequestPath))
-		return nil, resp.StatusCode, err
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
 	d.Log.V(1).Info(fmt.Sprintf("API response %v: %v (%v)", resp.StatusCode, RequestPath, RequestMethod))
 
-	body, err := ioutil.ReadAll(resp.Body)
+	reset := d.limits.update(endpoint, resp.Header)
 
-	// Need to handle error: {\"errors\":[\"Can not create duplicate monitors: Rate limit of 5 requests in 600 seconds reached. Please try again later.\"]}
-	if resp.StatusCode == 429 {
-		return nil, resp.StatusCode, fmt.Errorf("Error in apiRequest to %v (%v) %v: %v", RequestPath, RequestMethod, resp.StatusCode, string(body))
-	}
+	body, err := ioutil.ReadAll(resp.Body)
 
 	if err != nil {
 		d.Log.Error(err, fmt.Sprintf("Error reading response body from %v request for path %v", RequestMethod, RequestPath))
 		return nil, resp.StatusCode, err
 	}
 
+	// e.g. {"errors":["Can not create duplicate monitors: Rate limit of 5 requests in 600 seconds reached. Please try again later."]}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if reset == 0 {
+			reset = defaultRateLimitReset
+		}
+		d.Log.Info(fmt.Sprintf("Rate limited by Datadog for %v request for path %v: %v", RequestMethod, RequestPath, string(body)))
+		d.metrics.rateLimited.WithLabelValues(endpoint).Inc()
+		return nil, resp.StatusCode, &RateLimitError{Method: RequestMethod, Path: RequestPath, RetryAfter: reset}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, resp.StatusCode, newAPIError(RequestMethod, RequestPath, resp.StatusCode, body)
+	}
+
 	return body, resp.StatusCode, nil
 }
 
-func New(logLevel string) (Datadog, error) {
+func New(logLevel string, opts ...Option) (Datadog, error) {
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 	// How to set logLevel here?
 
+	creds, err := credentialsFromEnv()
+	if err != nil {
+		return Datadog{}, err
+	}
+
+	return NewForCredentials(logLevel, creds, opts...)
+}
+
+// NewForCredentials returns a client for the Datadog organization the
+// credentials belong to, checking the API key is valid
+func NewForCredentials(logLevel string, Creds Credentials, opts ...Option) (Datadog, error) {
 	d := Datadog{}
-	d.Log = ctrl.Log.WithName("datadog-api")
+	d.Conf = configFor(Creds.Host)
+	d.Log = ctrl.Log.WithName("datadog-api").WithValues("host", d.Conf.DatadogHost)
 
-	config, err := newConfig()
-	if err != nil {
-		return d, err
+	o := options{
+		baseURL:    d.Conf.datadogApiEndpoint,
+		registerer: prometheus.DefaultRegisterer,
+	}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	httpHeaders.Add("DD-API-KEY", config.datadogApiKey)
-	httpHeaders.Add("DD-APPLICATION-KEY", config.datadogAppKey)
-	httpHeaders.Add("Content-Type", "application/json")
-	httpHeaders.Add("User-Agent", httpUserAgent)
+	headers := http.Header{}
+	headers.Add("Content-Type", "application/json")
+	headers.Add("User-Agent", httpUserAgent)
+
+	d.client = restclient.New(append([]restclient.Option{
+		restclient.WithBaseURL(o.baseURL),
+		restclient.WithHeaders(headers),
+		restclient.WithLogger(d.Log),
+		restclient.WithRegisterer(o.registerer),
+	}, o.restclient...)...)
+	d.metrics = newMetrics(o.registerer)
 
-	d.Conf = config
+	d.headers = &atomic.Value{}
+	d.headers.Store(headersFor(Creds))
+	d.limits = newRateLimits()
+	d.org = &atomic.Value{}
 
-	if err = d.validateApiKey(); err != nil {
+	if err := d.validateApiKey(context.Background(), headersFor(Creds)); err != nil {
 		return d, err
 	}
 
 	return d, nil
 }
+
+// Organization identifies the Datadog organization of the client by its site
+// and public ID. The ID is read from Datadog once, so clients with different
+// keys of the same organization are recognised as the same.
+func (d Datadog) Organization(ctx context.Context) (string, error) {
+	if org, _ := d.org.Load().(string); org != "" {
+		return org, nil
+	}
+
+	response := OrganizationResponse{}
+
+	results, _, err := d.apiRequest(ctx, "GET", "/org", nil)
+	if err != nil {
+		return "", fmt.Errorf("Error reading organization: %w", err)
+	}
+
+	err = json.Unmarshal(results, &response)
+	if err != nil {
+		return "", fmt.Errorf("Error unmarshalling organization response: %v", err)
+	}
+
+	if len(response.Orgs) == 0 || response.Orgs[0].PublicId == "" {
+		return "", fmt.Errorf("Error reading organization: no organization in response")
+	}
+
+	org := fmt.Sprintf("%v/%v", d.Conf.DatadogHost, response.Orgs[0].PublicId)
+	d.org.Store(org)
+
+	return org, nil
+}
+
+// SetCredentials replaces the keys used by the client and all copies of it.
+// The new keys are validated first and are not used if they are invalid. The
+// host of the credentials is ignored as the client stays with its site.
+func (d Datadog) SetCredentials(ctx context.Context, Creds Credentials) error {
+	headers := headersFor(Creds)
+
+	if err := d.validateApiKey(ctx, headers); err != nil {
+		return err
+	}
+
+	d.headers.Store(headers)
+	// Read again in case the keys are of another organization
+	d.org.Store("")
+	d.Log.Info("Datadog keys replaced")
+
+	return nil
+}
@@ -2,9 +2,10 @@ package datadog
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
 	"github.com/max-rocket-internet/datadog-controller/datadog/mocks"
-	"github.com/max-rocket-internet/datadog-controller/datadog/restclient"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"net/http"
@@ -13,7 +14,6 @@ import (
 )
 
 func init() {
-	restclient.Client = &mocks.MockClient{}
 	os.Setenv("DD_CLIENT_API_KEY", "INVALID_API_KEY")
 	os.Setenv("DD_CLIENT_APP_KEY", "INVALID_APP_KEY")
 }
@@ -31,7 +31,7 @@ func TestDeleteMonitor(t *testing.T) {
 	responseJson := `{"deleted_monitor_id": 12345}`
 	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
 
-	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
 		body := responseJsonBody
 
 		if req.URL.Path == "/api/v1/validate" {
@@ -44,10 +44,10 @@ func TestDeleteMonitor(t *testing.T) {
 		}, nil
 	}
 
-	datadogApi, err := New("INFO")
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
 	assert.Nil(t, err)
 
-	err = datadogApi.DeleteMonitor(12345)
+	err = datadogApi.DeleteMonitor(context.Background(), 12345)
 	assert.Nil(t, err)
 }
 
@@ -55,7 +55,7 @@ func TestDeleteMonitorFail(t *testing.T) {
 	responseJson := `{"deleted_monitor_id": 12345}`
 	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
 
-	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
 		body := responseJsonBody
 
 		if req.URL.Path == "/api/v1/validate" {
@@ -68,10 +68,10 @@ func TestDeleteMonitorFail(t *testing.T) {
 		}, nil
 	}
 
-	datadogApi, err := New("INFO")
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
 	assert.Nil(t, err)
 
-	err = datadogApi.DeleteMonitor(12345)
+	err = datadogApi.DeleteMonitor(context.Background(), 12345)
 	assert.NotNil(t, err)
 }
 
@@ -85,7 +85,7 @@ func TestCreateMonitor(t *testing.T) {
 	responseJson := `{"id": 12345}`
 	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
 
-	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
 		body := responseJsonBody
 
 		if req.URL.Path == "/api/v1/validate" {
@@ -98,10 +98,10 @@ func TestCreateMonitor(t *testing.T) {
 		}, nil
 	}
 
-	datadogApi, err := New("INFO")
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
 	assert.Nil(t, err)
 
-	monitorId, err := datadogApi.CreateMonitor(newMonitor)
+	monitorId, err := datadogApi.CreateMonitor(context.Background(), newMonitor, Owner{})
 	assert.EqualValues(t, monitorId, 12345)
 	assert.Nil(t, err)
 }
@@ -116,7 +116,7 @@ func TestCreateMonitorFail(t *testing.T) {
 	responseJson := `{"id": 12345}`
 	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
 
-	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
 		body := responseJsonBody
 
 		if req.URL.Path == "/api/v1/validate" {
@@ -129,10 +129,10 @@ func TestCreateMonitorFail(t *testing.T) {
 		}, nil
 	}
 
-	datadogApi, err := New("INFO")
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
 	assert.Nil(t, err)
 
-	_, err = datadogApi.CreateMonitor(newMonitor)
+	_, err = datadogApi.CreateMonitor(context.Background(), newMonitor, Owner{})
 	assert.NotNil(t, err)
 }
 
@@ -146,7 +146,7 @@ func TestUpdateMonitor(t *testing.T) {
 	responseJson := `{"id": 12345}`
 	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
 
-	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
 		body := responseJsonBody
 
 		if req.URL.Path == "/api/v1/validate" {
@@ -159,10 +159,10 @@ func TestUpdateMonitor(t *testing.T) {
 		}, nil
 	}
 
-	datadogApi, err := New("INFO")
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
 	assert.Nil(t, err)
 
-	err = datadogApi.UpdateMonitor(12345, updatedMonitor)
+	err = datadogApi.UpdateMonitor(context.Background(), 12345, updatedMonitor, Owner{})
 	assert.Nil(t, err)
 }
 
@@ -176,7 +176,7 @@ func TestUpdateMonitorFail(t *testing.T) {
 	responseJson := `{"id": 12345}`
 	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
 
-	mocks.GetDoFunc = func(req *http.Request) (*http.Response, error) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
 		body := responseJsonBody
 
 		if req.URL.Path == "/api/v1/validate" {
@@ -189,9 +189,256 @@ func TestUpdateMonitorFail(t *testing.T) {
 		}, nil
 	}
 
-	datadogApi, err := New("INFO")
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
 	assert.Nil(t, err)
 
-	err = datadogApi.UpdateMonitor(12345, updatedMonitor)
+	err = datadogApi.UpdateMonitor(context.Background(), 12345, updatedMonitor, Owner{})
 	assert.NotNil(t, err)
 }
+
+func TestGetMonitor(t *testing.T) {
+	responseJson := `{"id": 12345, "name": "test-get", "query": "test-query", "type": "query alert", "options": {"thresholds": {"critical": 1.5}}, "overall_state": "Alert", "state": {"groups": {"host:a": {"name": "host:a", "status": "Alert", "last_triggered_ts": 1600000000}}}}`
+	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
+
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := responseJsonBody
+
+		if req.URL.Path == "/api/v1/validate" {
+			body = ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson)))
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       body,
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	monitor, err := datadogApi.GetMonitor(context.Background(), 12345)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 12345, monitor.Id)
+	assert.Equal(t, "test-get", monitor.Name)
+	assert.Equal(t, 1.5, monitor.Options.Thresholds.Critical)
+	assert.Equal(t, "Alert", monitor.OverallState)
+	assert.EqualValues(t, 1600000000, monitor.State.Groups["host:a"].LastTriggeredTs)
+}
+
+func TestGetMonitorNotFound(t *testing.T) {
+	responseJson := `{"errors": ["Monitor not found"]}`
+	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
+
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := responseJsonBody
+		statusCode := 404
+
+		if req.URL.Path == "/api/v1/validate" {
+			body = ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson)))
+			statusCode = 200
+		}
+
+		return &http.Response{
+			StatusCode: statusCode,
+			Body:       body,
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	_, err = datadogApi.GetMonitor(context.Background(), 12345)
+	assert.True(t, IsNotFound(err))
+}
+
+func TestListMonitors(t *testing.T) {
+	responseJson := `[{"id": 1, "name": "first", "query": "avg:cpu{service:a} > 1"}, {"id": 2, "name": "second", "query": "avg:mem{service:b} > 1"}]`
+	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
+
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := responseJsonBody
+
+		if req.URL.Path == "/api/v1/validate" {
+			body = ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson)))
+		} else {
+			assert.Equal(t, "env:test", req.URL.Query().Get("monitor_tags"))
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       body,
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	monitors, err := datadogApi.ListMonitors(context.Background(), MonitorFilter{Tags: []string{"env:test"}, Query: "service:b"})
+	assert.Nil(t, err)
+	assert.Len(t, monitors, 1)
+	assert.EqualValues(t, 2, monitors[0].Id)
+}
+
+func TestValidateMonitor(t *testing.T) {
+	responseJson := `{"errors": ["The value provided for parameter 'query' is invalid"]}`
+
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
+		statusCode := 400
+
+		if req.URL.Path == "/api/v1/validate" {
+			body = ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson)))
+			statusCode = 200
+		} else {
+			assert.Equal(t, "/api/v1/monitor/validate", req.URL.Path)
+		}
+
+		return &http.Response{
+			StatusCode: statusCode,
+			Body:       body,
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	reasons, err := datadogApi.ValidateMonitor(context.Background(), v1beta1.DatadogMonitorSpec{Query: "avg(last_5m):foo{*} >"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"The value provided for parameter 'query' is invalid"}, reasons)
+}
+
+func TestNewForCredentials(t *testing.T) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		assert.Equal(t, "api.datadoghq.com", req.URL.Host)
+		assert.Equal(t, "other-api-key", req.Header.Get("DD-API-KEY"))
+		assert.Equal(t, "other-app-key", req.Header.Get("DD-APPLICATION-KEY"))
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson))),
+		}, nil
+	}
+
+	datadogApi, err := NewForCredentials("INFO", Credentials{ApiKey: "other-api-key", AppKey: "other-app-key", Host: "datadoghq.com"}, WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+	assert.Equal(t, "datadoghq.com", datadogApi.Conf.DatadogHost)
+}
+
+func TestOrganization(t *testing.T) {
+	// Keys of the organization abc123, apart from third-api-key
+	orgRequests := 0
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := apiKeyValidResponseJson
+		if req.URL.Path == "/api/v1/org" {
+			orgRequests++
+			publicId := "abc123"
+			if req.Header.Get("DD-API-KEY") == "third-api-key" {
+				publicId = "def456"
+			}
+			body = fmt.Sprintf(`{"orgs":[{"public_id":"%v","name":"Org"}]}`, publicId)
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+		}, nil
+	}
+
+	organization := func(creds Credentials) string {
+		datadogApi, err := NewForCredentials("INFO", creds, WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+		assert.Nil(t, err)
+		org, err := datadogApi.Organization(context.Background())
+		assert.Nil(t, err)
+		return org
+	}
+
+	org := organization(Credentials{ApiKey: "api-key", AppKey: "app-key", Host: "datadoghq.eu"})
+	assert.Equal(t, "datadoghq.eu/abc123", org)
+	assert.Equal(t, org, organization(Credentials{ApiKey: "other-api-key", AppKey: "other-app-key", Host: "datadoghq.eu"}))
+	assert.NotEqual(t, org, organization(Credentials{ApiKey: "third-api-key", AppKey: "app-key", Host: "datadoghq.eu"}))
+	assert.NotEqual(t, org, organization(Credentials{ApiKey: "api-key", AppKey: "app-key", Host: "datadoghq.com"}))
+
+	// The organization is read once per client
+	datadogApi, err := NewForCredentials("INFO", Credentials{ApiKey: "api-key", AppKey: "app-key"}, WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+	orgRequests = 0
+	_, err = datadogApi.Organization(context.Background())
+	assert.Nil(t, err)
+	_, err = datadogApi.Organization(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, orgRequests)
+}
+
+func TestOrganizationError(t *testing.T) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/api/v1/org" {
+			return &http.Response{
+				StatusCode: 403,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"errors":["Forbidden"]}`))),
+			}, nil
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson))),
+		}, nil
+	}
+
+	datadogApi, err := NewForCredentials("INFO", Credentials{ApiKey: "api-key", AppKey: "app-key"}, WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	_, err = datadogApi.Organization(context.Background())
+	assert.Error(t, err)
+}
+
+func TestSetCredentials(t *testing.T) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		valid := req.Header.Get("DD-APPLICATION-KEY") != "invalid-app-key"
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(fmt.Sprintf(`{"valid": %v}`, valid)))),
+		}, nil
+	}
+
+	datadogApi, err := NewForCredentials("INFO", Credentials{ApiKey: "api-key", AppKey: "app-key"}, WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+	copied := datadogApi
+
+	assert.Nil(t, datadogApi.SetCredentials(context.Background(), Credentials{ApiKey: "api-key", AppKey: "rotated-app-key"}))
+	assert.Equal(t, "rotated-app-key", copied.headers.Load().(http.Header).Get("DD-APPLICATION-KEY"))
+
+	assert.NotNil(t, datadogApi.SetCredentials(context.Background(), Credentials{ApiKey: "api-key", AppKey: "invalid-app-key"}))
+	assert.Equal(t, "rotated-app-key", copied.headers.Load().(http.Header).Get("DD-APPLICATION-KEY"))
+}
+
+func TestClientsWithSeparateHTTPClients(t *testing.T) {
+	mockClient := func(monitorJson string) *mocks.MockClient {
+		return &mocks.MockClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				body := monitorJson
+				if req.URL.Path == "/api/v1/validate" {
+					body = apiKeyValidResponseJson
+				}
+
+				return &http.Response{
+					StatusCode: 200,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			},
+		}
+	}
+
+	firstApi, err := New("INFO", WithHTTPClient(mockClient(`{"id": 1, "name": "first"}`)))
+	assert.Nil(t, err)
+	secondApi, err := New("INFO", WithHTTPClient(mockClient(`{"id": 2, "name": "second"}`)))
+	assert.Nil(t, err)
+
+	monitor, err := firstApi.GetMonitor(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "first", monitor.Name)
+
+	monitor, err = secondApi.GetMonitor(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "second", monitor.Name)
+}
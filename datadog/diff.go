@@ -0,0 +1,143 @@
+package datadog
+
+import (
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DiffMonitor compares the spec of a monitor with the monitor currently in
+// Datadog and returns the names of the fields that differ.
+//
+// Datadog fills in defaults for everything that isn't sent and the controller
+// never sends empty fields. Options left empty in the spec are compared with
+// their default in monitorOptionDefaults, other empty fields are not compared.
+// Multi is compared with what Datadog derives from a grouped query. The
+// ownership tags the controller adds are ignored.
+func DiffMonitor(desired v1beta1.DatadogMonitorSpec, live v1beta1.DatadogMonitorSpec) []string {
+	drifted := []string{}
+
+	if desired.Name != "" && desired.Name != live.Name {
+		drifted = append(drifted, "name")
+	}
+
+	if desired.Message != "" && strings.TrimSpace(desired.Message) != strings.TrimSpace(live.Message) {
+		drifted = append(drifted, "message")
+	}
+
+	if desired.Query != "" && normaliseQuery(desired.Query) != normaliseQuery(live.Query) {
+		drifted = append(drifted, "query")
+	}
+
+	if desired.Type != "" && normaliseType(desired.Type) != normaliseType(live.Type) {
+		drifted = append(drifted, "type")
+	}
+
+	if desired.Query != "" && expectedMulti(desired) != live.Multi {
+		drifted = append(drifted, "multi")
+	}
+
+	if desired.Priority != 0 && desired.Priority != live.Priority {
+		drifted = append(drifted, "priority")
+	}
+
+	if len(desired.Tags) > 0 && !equalTags(desired.Tags, WithoutOwnerTags(live.Tags)) {
+		drifted = append(drifted, "tags")
+	}
+
+	diffFields("options", reflect.ValueOf(desired.Options), reflect.ValueOf(live.Options), &drifted)
+
+	return drifted
+}
+
+// The options Datadog sets when they aren't sent. A monitor edited in the
+// Datadog app, e.g. to notify on missing data, drifts from a spec leaving them
+// empty. Options not listed have defaults that depend on the monitor, e.g. its
+// type or timeframe, and are only compared when set in the spec.
+var monitorOptionDefaults = map[string]interface{}{
+	"options.escalation_message":   "",
+	"options.evaluation_delay":     int64(0),
+	"options.include_tags":         true,
+	"options.locked":               false,
+	"options.min_failure_duration": int64(0),
+	"options.notify_audit":         false,
+	"options.notify_no_data":       false,
+	"options.renotify_interval":    int64(0),
+	"options.timeout_h":            int64(0),
+}
+
+func diffFields(path string, desired reflect.Value, live reflect.Value, drifted *[]string) {
+	for i := 0; i < desired.NumField(); i++ {
+		field := desired.Type().Field(i)
+		name := path + "." + strings.Split(field.Tag.Get("json"), ",")[0]
+
+		if field.Type.Kind() == reflect.Struct {
+			diffFields(name, desired.Field(i), live.Field(i), drifted)
+			continue
+		}
+
+		want := desired.Field(i).Interface()
+		if desired.Field(i).IsZero() {
+			defaultValue, ok := monitorOptionDefaults[name]
+			if !ok {
+				continue
+			}
+			want = defaultValue
+		}
+
+		if !reflect.DeepEqual(want, live.Field(i).Interface()) {
+			*drifted = append(*drifted, name)
+		}
+	}
+}
+
+// Datadog reformats the whitespace of queries it stores
+func normaliseQuery(query string) string {
+	return strings.Join(strings.Fields(query), "")
+}
+
+// Datadog sets multi for monitors whose query is grouped, e.g. by {host} in a
+// metric query or .by("host") in a check or log query
+func expectedMulti(spec v1beta1.DatadogMonitorSpec) bool {
+	query := normaliseQuery(spec.Query)
+	return spec.Multi || strings.Contains(query, "}by{") || strings.Contains(query, ".by(")
+}
+
+// Datadog stores "metric alert" monitors as "query alert"
+func normaliseType(monitorType string) string {
+	if monitorType == "metric alert" {
+		return "query alert"
+	}
+	return monitorType
+}
+
+// Datadog lowercases tags, so tags with uppercase letters in the spec are
+// compared regardless of case
+func equalTags(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sortedA := lowerStrings(a)
+	sortedB := lowerStrings(b)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func lowerStrings(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+
+	return lowered
+}
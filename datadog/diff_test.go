@@ -0,0 +1,106 @@
+package datadog
+
+import (
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiffMonitor(t *testing.T) {
+	desired := v1beta1.DatadogMonitorSpec{}
+	desired.Name = "test-diff"
+	desired.Message = "test-message"
+	desired.Query = "avg(last_5m):sum:system.net.bytes_rcvd{host:host0} > 100"
+	desired.Type = "metric alert"
+	desired.Tags = []string{"env:test", "service:test"}
+	desired.Options.Thresholds.Critical = 100
+
+	live := desired
+	live.Message = "test-message\n"
+	live.Query = "avg(last_5m):sum:system.net.bytes_rcvd{host:host0}>100"
+	live.Type = "query alert"
+	live.Tags = []string{"service:test", "env:test"}
+	live.Options.IncludeTags = true
+	live.Options.NewHostDelay = 300
+	live.Options.RequireFullWindow = true
+
+	assert.Empty(t, DiffMonitor(desired, live))
+
+	live.Name = "edited-in-ui"
+	live.Options.Thresholds.Critical = 200
+	live.Tags = []string{"env:test"}
+
+	assert.Equal(t, []string{"name", "tags", "options.thresholds.critical"}, DiffMonitor(desired, live))
+}
+
+func TestDiffMonitorMixedCaseTags(t *testing.T) {
+	desired := v1beta1.DatadogMonitorSpec{}
+	desired.Name = "test-diff"
+	desired.Tags = []string{"Team:Platform", "env:Prod"}
+
+	live := desired
+	live.Options.IncludeTags = true
+	live.Tags = []string{"env:prod", "team:platform", "datadog-controller-uid:abc"}
+
+	assert.Empty(t, DiffMonitor(desired, live))
+
+	live.Tags = []string{"env:prod", "team:payments"}
+
+	assert.Equal(t, []string{"tags"}, DiffMonitor(desired, live))
+}
+
+func TestDiffMonitorDefaultOptions(t *testing.T) {
+	desired := v1beta1.DatadogMonitorSpec{}
+	desired.Name = "test-diff"
+	desired.Query = "avg(last_5m):sum:system.net.bytes_rcvd{host:host0} > 100"
+	desired.Options.NoDataTimeframe = 10
+
+	live := desired
+	live.Options.IncludeTags = true
+	live.Options.NewHostDelay = 300
+
+	assert.Empty(t, DiffMonitor(desired, live))
+
+	live.Options.IncludeTags = false
+	live.Options.NotifyNoData = true
+	live.Options.RenotifyInterval = 60
+	live.Options.NoDataTimeframe = 20
+
+	assert.Equal(t, []string{"options.include_tags", "options.no_data_timeframe", "options.notify_no_data", "options.renotify_interval"}, DiffMonitor(desired, live))
+
+	desired.Options.NotifyNoData = true
+	desired.Options.RenotifyInterval = 60
+
+	assert.Equal(t, []string{"options.include_tags", "options.no_data_timeframe"}, DiffMonitor(desired, live))
+}
+
+func TestDiffMonitorMulti(t *testing.T) {
+	desired := v1beta1.DatadogMonitorSpec{}
+	desired.Name = "test-diff"
+	desired.Query = "avg(last_5m):sum:system.net.bytes_rcvd{*} by {host} > 100"
+
+	live := desired
+	live.Multi = true
+	live.Options.IncludeTags = true
+
+	assert.Empty(t, DiffMonitor(desired, live))
+
+	live.Multi = false
+
+	assert.Equal(t, []string{"multi"}, DiffMonitor(desired, live))
+
+	desired.Query = "\"http.can_connect\".over(\"*\").by(\"host\").last(2).count_by_status()"
+	live.Query = desired.Query
+	live.Multi = true
+
+	assert.Empty(t, DiffMonitor(desired, live))
+
+	desired.Query = "avg(last_5m):sum:system.net.bytes_rcvd{host:host0} > 100"
+	live.Query = desired.Query
+
+	assert.Equal(t, []string{"multi"}, DiffMonitor(desired, live))
+
+	desired.Multi = true
+
+	assert.Empty(t, DiffMonitor(desired, live))
+}
@@ -0,0 +1,128 @@
+package datadog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+)
+
+type downtimeRecurrence struct {
+	Period           int32    `json:"period,omitempty"`
+	Type             string   `json:"type"`
+	Rrule            string   `json:"rrule,omitempty"`
+	UntilDate        int64    `json:"until_date,omitempty"`
+	UntilOccurrences int32    `json:"until_occurrences,omitempty"`
+	WeekDays         []string `json:"week_days,omitempty"`
+}
+
+// A downtime as sent to the Datadog API, which uses unix timestamps
+type downtime struct {
+	Id          int64               `json:"id,omitempty"`
+	Scope       []string            `json:"scope"`
+	MonitorTags []string            `json:"monitor_tags,omitempty"`
+	MonitorId   int64               `json:"monitor_id,omitempty"`
+	Start       int64               `json:"start,omitempty"`
+	End         int64               `json:"end,omitempty"`
+	Message     string              `json:"message,omitempty"`
+	Recurrence  *downtimeRecurrence `json:"recurrence,omitempty"`
+}
+
+func (d Datadog) CreateDowntime(ctx context.Context, DowntimeSpec v1beta1.DatadogDowntimeSpec, MonitorId int64) (int64, error) {
+	d.Log.V(1).Info("Creating downtime")
+
+	requestBody, err := downtimeRequestBody(DowntimeSpec, MonitorId)
+	if err != nil {
+		return 0, err
+	}
+
+	results, _, err := d.apiRequest(ctx, "POST", "/downtime", requestBody)
+	if err != nil {
+		d.metrics.downtimeEvents.WithLabelValues("failed").Inc()
+		return 0, fmt.Errorf("Error creating downtime: %w", err)
+	}
+
+	requestResponse := downtime{}
+	err = json.Unmarshal(results, &requestResponse)
+	if err != nil {
+		d.metrics.downtimeEvents.WithLabelValues("failed").Inc()
+		return 0, err
+	}
+
+	d.metrics.downtimeEvents.WithLabelValues("created").Inc()
+
+	return requestResponse.Id, nil
+}
+
+func (d Datadog) UpdateDowntime(ctx context.Context, DowntimeId int64, DowntimeSpec v1beta1.DatadogDowntimeSpec, MonitorId int64) error {
+	d.Log.V(1).Info(fmt.Sprintf("Updating downtime '%v'", DowntimeId))
+
+	requestBody, err := downtimeRequestBody(DowntimeSpec, MonitorId)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = d.apiRequest(ctx, "PUT", fmt.Sprintf("/downtime/%v", DowntimeId), requestBody)
+	if err != nil {
+		d.metrics.downtimeEvents.WithLabelValues("failed").Inc()
+		return fmt.Errorf("Error updating downtime '%v': %w", DowntimeId, err)
+	}
+
+	d.metrics.downtimeEvents.WithLabelValues("updated").Inc()
+
+	return nil
+}
+
+func (d Datadog) DeleteDowntime(ctx context.Context, DowntimeId int64) error {
+	d.Log.V(1).Info(fmt.Sprintf("Deleting downtime %v", DowntimeId))
+
+	_, _, err := d.apiRequest(ctx, "DELETE", fmt.Sprintf("/downtime/%v", DowntimeId), nil)
+	if IsNotFound(err) {
+		d.Log.V(1).Info(fmt.Sprintf("Downtime %v already deleted", DowntimeId))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error deleting downtime: %w", err)
+	}
+
+	d.metrics.downtimeEvents.WithLabelValues("deleted").Inc()
+
+	return nil
+}
+
+func downtimeRequestBody(DowntimeSpec v1beta1.DatadogDowntimeSpec, MonitorId int64) ([]byte, error) {
+	request := downtime{
+		Scope:       DowntimeSpec.Scope,
+		MonitorTags: DowntimeSpec.MonitorTags,
+		MonitorId:   MonitorId,
+		Message:     DowntimeSpec.Message,
+	}
+
+	if len(request.Scope) == 0 {
+		request.Scope = []string{"*"}
+	}
+
+	if DowntimeSpec.Start != nil {
+		request.Start = DowntimeSpec.Start.Unix()
+	}
+
+	if DowntimeSpec.End != nil {
+		request.End = DowntimeSpec.End.Unix()
+	}
+
+	if recurrence := DowntimeSpec.Recurrence; recurrence != nil {
+		request.Recurrence = &downtimeRecurrence{
+			Period:           recurrence.Period,
+			Type:             recurrence.Type,
+			Rrule:            recurrence.Rrule,
+			UntilOccurrences: recurrence.UntilOccurrences,
+			WeekDays:         recurrence.WeekDays,
+		}
+
+		if recurrence.UntilDate != nil {
+			request.Recurrence.UntilDate = recurrence.UntilDate.Unix()
+		}
+	}
+
+	return json.Marshal(request)
+}
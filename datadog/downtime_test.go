@@ -0,0 +1,101 @@
+package datadog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog/mocks"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCreateDowntime(t *testing.T) {
+	start := metav1.NewTime(time.Unix(1600000000, 0))
+	newDowntime := v1beta1.DatadogDowntimeSpec{}
+	newDowntime.Start = &start
+	newDowntime.Message = "test-message"
+
+	responseJson := `{"id": 12345}`
+	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
+
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := responseJsonBody
+
+		if req.URL.Path == "/api/v1/validate" {
+			body = ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson)))
+		} else {
+			request := downtime{}
+			requestBody, _ := ioutil.ReadAll(req.Body)
+			assert.Nil(t, json.Unmarshal(requestBody, &request))
+			assert.Equal(t, []string{"*"}, request.Scope)
+			assert.EqualValues(t, 1600000000, request.Start)
+			assert.EqualValues(t, 67890, request.MonitorId)
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       body,
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	downtimeId, err := datadogApi.CreateDowntime(context.Background(), newDowntime, 67890)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 12345, downtimeId)
+}
+
+func TestCreateDowntimeFail(t *testing.T) {
+	responseJson := `{"errors": ["Invalid scope"]}`
+	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
+
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := responseJsonBody
+		statusCode := 400
+
+		if req.URL.Path == "/api/v1/validate" {
+			body = ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson)))
+			statusCode = 200
+		}
+
+		return &http.Response{
+			StatusCode: statusCode,
+			Body:       body,
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	_, err = datadogApi.CreateDowntime(context.Background(), v1beta1.DatadogDowntimeSpec{}, 0)
+	assert.NotNil(t, err)
+}
+
+func TestDeleteDowntime(t *testing.T) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := ioutil.NopCloser(bytes.NewReader([]byte{}))
+		statusCode := 204
+
+		if req.URL.Path == "/api/v1/validate" {
+			body = ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson)))
+			statusCode = 200
+		}
+
+		return &http.Response{
+			StatusCode: statusCode,
+			Body:       body,
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	err = datadogApi.DeleteDowntime(context.Background(), 12345)
+	assert.Nil(t, err)
+}
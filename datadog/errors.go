@@ -0,0 +1,79 @@
+package datadog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is an error response from the Datadog API
+type APIError struct {
+	Method string
+	Path   string
+	// The HTTP status code of the response
+	StatusCode int
+	// The messages in the errors of the response, or the body if it has none
+	Errors []string
+	// Whether the request may succeed if it's made again unchanged. Errors
+	// in the request itself, e.g. an invalid query, are not retryable.
+	Retryable bool
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%v %v returned %v: %v", e.Method, e.Path, e.StatusCode, strings.Join(e.Errors, ", "))
+}
+
+// newAPIError reads the errors from the body of a failed response
+func newAPIError(RequestMethod string, RequestPath string, StatusCode int, Body []byte) *APIError {
+	apiErr := &APIError{
+		Method:     RequestMethod,
+		Path:       RequestPath,
+		StatusCode: StatusCode,
+		Retryable:  retryableStatus(StatusCode),
+	}
+
+	response := struct {
+		Errors []string `json:"errors"`
+	}{}
+
+	if err := json.Unmarshal(Body, &response); err == nil && len(response.Errors) > 0 {
+		apiErr.Errors = response.Errors
+	} else if body := strings.TrimSpace(string(Body)); body != "" {
+		apiErr.Errors = []string{body}
+	} else {
+		apiErr.Errors = []string{http.StatusText(StatusCode)}
+	}
+
+	return apiErr
+}
+
+// Client errors other than these are caused by the request and fail again
+// when it's retried. Keys can be rotated, so 401 and 403 are retried.
+func retryableStatus(StatusCode int) bool {
+	if StatusCode < 400 || StatusCode >= 500 {
+		return true
+	}
+
+	switch StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+
+	return false
+}
+
+// IsNotFound reports whether the error is a Datadog API response for
+// something that doesn't exist
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsPermanent reports whether the error is a Datadog API response rejecting
+// the request itself, so making the same request again fails the same way
+func IsPermanent(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && !apiErr.Retryable
+}
@@ -0,0 +1,59 @@
+package datadog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog/mocks"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestNewAPIError(t *testing.T) {
+	apiErr := newAPIError("POST", "/monitor", 400, []byte(`{"errors": ["The value provided for parameter 'query' is invalid"]}`))
+	assert.Equal(t, []string{"The value provided for parameter 'query' is invalid"}, apiErr.Errors)
+	assert.False(t, apiErr.Retryable)
+	assert.Equal(t, "POST /monitor returned 400: The value provided for parameter 'query' is invalid", apiErr.Error())
+
+	apiErr = newAPIError("PUT", "/monitor/12345", 502, []byte("<html>Bad Gateway</html>"))
+	assert.Equal(t, []string{"<html>Bad Gateway</html>"}, apiErr.Errors)
+	assert.True(t, apiErr.Retryable)
+
+	apiErr = newAPIError("GET", "/monitor/12345", 403, nil)
+	assert.Equal(t, []string{"Forbidden"}, apiErr.Errors)
+	assert.True(t, apiErr.Retryable)
+}
+
+func TestIsPermanent(t *testing.T) {
+	assert.True(t, IsPermanent(fmt.Errorf("Error creating monitor: %w", newAPIError("POST", "/monitor", 400, nil))))
+	assert.False(t, IsPermanent(newAPIError("POST", "/monitor", 500, nil)))
+	assert.False(t, IsPermanent(fmt.Errorf("connection refused")))
+}
+
+func TestCreateMonitorServerError(t *testing.T) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/api/v1/validate" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson))),
+			}, nil
+		}
+
+		return &http.Response{
+			StatusCode: 503,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"errors": ["Service unavailable"]}`))),
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	monitorId, err := datadogApi.CreateMonitor(context.Background(), v1beta1.DatadogMonitorSpec{Name: "test-create"}, Owner{})
+	assert.Equal(t, int64(0), monitorId)
+	assert.NotNil(t, err)
+	assert.False(t, IsPermanent(err))
+	assert.Contains(t, err.Error(), "Service unavailable")
+}
@@ -0,0 +1,815 @@
+// Package fake is an in-process fake of the parts of the Datadog API the
+// controller uses, for tests and for running the controller locally without
+// a Datadog organization
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+const apiBase = "/api/v1"
+
+// Server is a fake Datadog API. Objects are kept in memory and responses,
+// including errors, have the shape of the responses of Datadog.
+type Server struct {
+	// The base URL of the API, e.g. for datadog.WithBaseURL
+	URL string
+	// The keys requests must be made with. Any keys are accepted if empty.
+	APIKey string
+	AppKey string
+	// The public ID of the organization. Each server is a different
+	// organization.
+	OrgId string
+	// How long new monitors take to show up in /monitor/search, like the
+	// search index of Datadog that lags behind writes. Zero indexes them
+	// right away.
+	SearchIndexDelay time.Duration
+
+	server *httptest.Server
+
+	mu        sync.Mutex
+	lastId    int64
+	monitors  map[int64]object
+	downtimes map[int64]object
+	slos      map[string]object
+	limits    map[string]*rateLimit
+	requests  map[string]int
+}
+
+// An object as sent to and returned by the API
+type object map[string]interface{}
+
+// The defaults of the monitor options that don't depend on the monitor
+var monitorOptionDefaults = object{
+	"include_tags":        true,
+	"locked":              false,
+	"new_host_delay":      300,
+	"notify_audit":        false,
+	"notify_no_data":      false,
+	"require_full_window": true,
+}
+
+type rateLimit struct {
+	limit  int
+	period time.Duration
+	start  time.Time
+	used   int
+}
+
+// servers counts the servers started, to give each its own organization
+var servers int64
+
+// NewServer starts a fake Datadog API. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		OrgId:     fmt.Sprintf("fake%08x", atomic.AddInt64(&servers, 1)),
+		monitors:  map[int64]object{},
+		downtimes: map[int64]object{},
+		slos:      map[string]object{},
+		limits:    map[string]*rateLimit{},
+		requests:  map[string]int{},
+	}
+
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL + apiBase
+
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// SetRateLimit limits the requests to an endpoint, e.g. "POST /monitor" or
+// "GET /monitor/:id", to limit requests per period
+func (s *Server) SetRateLimit(endpoint string, limit int, period time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limits[endpoint] = &rateLimit{limit: limit, period: period}
+}
+
+// Requests returns the number of requests made to an endpoint, e.g.
+// "POST /monitor", including rejected requests
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[endpoint]
+}
+
+// Monitor returns a monitor as Datadog stores it
+func (s *Server) Monitor(id int64) (v1beta1.DatadogMonitorSpec, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	monitor := v1beta1.DatadogMonitorSpec{}
+	stored, ok := s.monitors[id]
+	if ok {
+		stored.decode(&monitor)
+	}
+
+	return monitor, ok
+}
+
+// Monitors returns all monitors ordered by ID
+func (s *Server) Monitors() []v1beta1.DatadogMonitorSpec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	monitors := []v1beta1.DatadogMonitorSpec{}
+	for _, id := range s.monitorIds() {
+		monitor := v1beta1.DatadogMonitorSpec{}
+		s.monitors[id].decode(&monitor)
+		monitors = append(monitors, monitor)
+	}
+
+	return monitors
+}
+
+// CreateMonitor creates a monitor as if it was created outside the
+// controller, e.g. in the Datadog app, and returns its ID
+func (s *Server) CreateMonitor(spec v1beta1.DatadogMonitorSpec) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, _ := json.Marshal(spec)
+	monitor, _ := parseMonitor(body)
+	s.storeMonitor(monitor)
+
+	return s.lastId
+}
+
+// DeleteMonitor deletes a monitor as if it was deleted outside the
+// controller, e.g. in the Datadog app
+func (s *Server) DeleteMonitor(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.monitors, id)
+}
+
+// Downtime returns a downtime as Datadog stores it
+func (s *Server) Downtime(id int64) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	downtime, ok := s.downtimes[id]
+	return downtime.copy(), ok
+}
+
+// SLO returns an SLO as Datadog stores it
+func (s *Server) SLO(id string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slo, ok := s.slos[id]
+	return slo.copy(), ok
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, apiBase)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	endpoint := endpointFor(r.Method, segments)
+	s.requests[endpoint]++
+
+	if !s.authorized(r, path == "/validate") {
+		writeErrors(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	if !s.allow(w, endpoint) {
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch {
+	case endpoint == "GET /validate":
+		writeJson(w, http.StatusOK, object{"valid": true})
+	case endpoint == "GET /org":
+		writeJson(w, http.StatusOK, object{"orgs": []object{{"public_id": s.OrgId, "name": "Fake"}}})
+	case endpoint == "GET /monitor":
+		s.listMonitors(w, r)
+	case endpoint == "POST /monitor":
+		s.createMonitor(w, body)
+	case endpoint == "POST /monitor/validate":
+		s.validateMonitor(w, body)
+	case endpoint == "GET /monitor/search":
+		s.searchMonitors(w, r)
+	case segments[0] == "monitor" && len(segments) == 2:
+		s.monitor(w, r.Method, segments[1], body)
+	case endpoint == "POST /downtime":
+		s.createDowntime(w, body)
+	case segments[0] == "downtime" && len(segments) == 2:
+		s.downtime(w, r.Method, segments[1], body)
+	case endpoint == "POST /slo":
+		s.createSLO(w, body)
+	case segments[0] == "slo" && len(segments) == 2:
+		s.slo(w, r.Method, segments[1], body)
+	default:
+		writeErrors(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// authorized checks the keys of a request. Validating the API key doesn't
+// need the application key.
+func (s *Server) authorized(r *http.Request, apiKeyOnly bool) bool {
+	if s.APIKey != "" && r.Header.Get("DD-API-KEY") != s.APIKey {
+		return false
+	}
+
+	return apiKeyOnly || s.AppKey == "" || r.Header.Get("DD-APPLICATION-KEY") == s.AppKey
+}
+
+// allow applies the rate limit of an endpoint, setting the X-RateLimit
+// headers. It reports false if the request was rate limited.
+func (s *Server) allow(w http.ResponseWriter, endpoint string) bool {
+	limit, ok := s.limits[endpoint]
+	if !ok {
+		return true
+	}
+
+	now := time.Now()
+	if now.Sub(limit.start) >= limit.period {
+		limit.start = now
+		limit.used = 0
+	}
+
+	allowed := limit.used < limit.limit
+	if allowed {
+		limit.used++
+	}
+
+	reset := math.Ceil(limit.period.Seconds() - now.Sub(limit.start).Seconds())
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.limit))
+	w.Header().Set("X-RateLimit-Period", strconv.Itoa(int(limit.period.Seconds())))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(limit.limit-limit.used))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(reset)))
+
+	if !allowed {
+		writeErrors(w, http.StatusTooManyRequests, fmt.Sprintf("Rate limit of %v requests in %v seconds reached. Please try again later.", limit.limit, int(limit.period.Seconds())))
+	}
+
+	return allowed
+}
+
+func (s *Server) nextId() int64 {
+	s.lastId++
+	return s.lastId
+}
+
+func (s *Server) monitorIds() []int64 {
+	ids := []int64{}
+	for id := range s.monitors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+func (s *Server) createMonitor(w http.ResponseWriter, body []byte) {
+	monitor, errs := parseMonitor(body)
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs...)
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.storeMonitor(monitor))
+}
+
+func (s *Server) storeMonitor(monitor object) object {
+	now := time.Now().UTC().Format(time.RFC3339)
+	monitor["id"] = s.nextId()
+	monitor["created"] = now
+	monitor["modified"] = now
+	monitor["overall_state"] = "No Data"
+	s.monitors[s.lastId] = monitor
+
+	return monitor
+}
+
+func (s *Server) validateMonitor(w http.ResponseWriter, body []byte) {
+	if _, errs := parseMonitor(body); len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs...)
+		return
+	}
+
+	writeJson(w, http.StatusOK, object{})
+}
+
+func (s *Server) monitor(w http.ResponseWriter, method string, idSegment string, body []byte) {
+	id, err := strconv.ParseInt(idSegment, 10, 64)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, fmt.Sprintf("Invalid monitor id: %v", idSegment))
+		return
+	}
+
+	monitor, ok := s.monitors[id]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "Monitor not found")
+		return
+	}
+
+	switch method {
+	case "GET":
+		response := monitor.copy()
+		response["state"] = object{"groups": object{}}
+		writeJson(w, http.StatusOK, response)
+	case "PUT":
+		update, errs := parseMonitor(body)
+		if len(errs) > 0 {
+			writeErrors(w, http.StatusBadRequest, errs...)
+			return
+		}
+		if update["type"] != monitor["type"] {
+			writeErrors(w, http.StatusBadRequest, "The type of a monitor can't be changed")
+			return
+		}
+		for key, value := range update {
+			monitor[key] = value
+		}
+		monitor["modified"] = time.Now().UTC().Format(time.RFC3339)
+		writeJson(w, http.StatusOK, monitor)
+	case "DELETE":
+		if slos := s.slosReferencing(id); len(slos) > 0 {
+			writeErrors(w, http.StatusBadRequest, fmt.Sprintf("monitor [%v,%v] is referenced in slos: %v", id, monitor["name"], slos))
+			return
+		}
+		delete(s.monitors, id)
+		writeJson(w, http.StatusOK, object{"deleted_monitor_id": id})
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (s *Server) listMonitors(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	name := strings.ToLower(params.Get("name"))
+	tags := []string{}
+	if params.Get("monitor_tags") != "" {
+		tags = strings.Split(params.Get("monitor_tags"), ",")
+	}
+
+	matches := []object{}
+	for _, id := range s.monitorIds() {
+		monitor := s.monitors[id]
+		if strings.Contains(strings.ToLower(fmt.Sprint(monitor["name"])), name) && hasTags(monitor, tags) {
+			matches = append(matches, monitor)
+		}
+	}
+
+	page, _ := strconv.Atoi(params.Get("page"))
+	pageSize, err := strconv.Atoi(params.Get("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = len(matches) + 1
+	}
+
+	writeJson(w, http.StatusOK, paginate(matches, page, pageSize))
+}
+
+// searchMonitors supports queries of space separated terms like
+// tag:"service:web", id:123, type:"metric alert" and text in the name
+func (s *Server) searchMonitors(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	terms := parseSearchQuery(params.Get("query"))
+
+	matches := []object{}
+	for _, id := range s.monitorIds() {
+		monitor := s.monitors[id]
+		if !s.indexed(monitor) {
+			continue
+		}
+		if matchesSearch(monitor, terms) {
+			matches = append(matches, object{
+				"id":       monitor["id"],
+				"name":     monitor["name"],
+				"type":     monitor["type"],
+				"query":    monitor["query"],
+				"tags":     monitor["tags"],
+				"status":   monitor["overall_state"],
+				"priority": monitor["priority"],
+			})
+		}
+	}
+
+	page, _ := strconv.Atoi(params.Get("page"))
+	perPage, err := strconv.Atoi(params.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 30
+	}
+
+	writeJson(w, http.StatusOK, object{
+		"monitors": paginate(matches, page, perPage),
+		"metadata": object{
+			"page":        page,
+			"per_page":    perPage,
+			"page_count":  (len(matches) + perPage - 1) / perPage,
+			"total_count": len(matches),
+		},
+	})
+}
+
+// indexed reports whether a monitor is in the search index yet
+func (s *Server) indexed(monitor object) bool {
+	if s.SearchIndexDelay == 0 {
+		return true
+	}
+
+	created, err := time.Parse(time.RFC3339, fmt.Sprint(monitor["created"]))
+	return err == nil && time.Since(created) >= s.SearchIndexDelay
+}
+
+func (s *Server) createDowntime(w http.ResponseWriter, body []byte) {
+	downtime, errs := parseDowntime(body)
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs...)
+		return
+	}
+
+	if monitorId, ok := downtime["monitor_id"]; ok {
+		if _, ok := s.monitors[toInt64(monitorId)]; !ok {
+			writeErrors(w, http.StatusBadRequest, fmt.Sprintf("Monitor %v not found", monitorId))
+			return
+		}
+	}
+
+	downtime["id"] = s.nextId()
+	downtime["active"] = true
+	downtime["disabled"] = false
+	s.downtimes[s.lastId] = downtime
+
+	writeJson(w, http.StatusOK, downtime)
+}
+
+func (s *Server) downtime(w http.ResponseWriter, method string, idSegment string, body []byte) {
+	id, err := strconv.ParseInt(idSegment, 10, 64)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, fmt.Sprintf("Invalid downtime id: %v", idSegment))
+		return
+	}
+
+	downtime, ok := s.downtimes[id]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "Downtime not found")
+		return
+	}
+
+	switch method {
+	case "GET":
+		writeJson(w, http.StatusOK, downtime)
+	case "PUT":
+		update, errs := parseDowntime(body)
+		if len(errs) > 0 {
+			writeErrors(w, http.StatusBadRequest, errs...)
+			return
+		}
+		for key, value := range update {
+			downtime[key] = value
+		}
+		writeJson(w, http.StatusOK, downtime)
+	case "DELETE":
+		delete(s.downtimes, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (s *Server) createSLO(w http.ResponseWriter, body []byte) {
+	slo, errs := parseSLO(body)
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs...)
+		return
+	}
+
+	for _, monitorId := range sloMonitorIds(slo) {
+		if _, ok := s.monitors[monitorId]; !ok {
+			writeErrors(w, http.StatusBadRequest, fmt.Sprintf("Monitor %v not found", monitorId))
+			return
+		}
+	}
+
+	id := fmt.Sprintf("%032x", s.nextId())
+	slo["id"] = id
+	s.slos[id] = slo
+
+	writeJson(w, http.StatusOK, object{"data": []object{slo}, "error": nil})
+}
+
+func (s *Server) slo(w http.ResponseWriter, method string, id string, body []byte) {
+	slo, ok := s.slos[id]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "SLO not found")
+		return
+	}
+
+	switch method {
+	case "GET":
+		writeJson(w, http.StatusOK, object{"data": slo, "error": nil})
+	case "PUT":
+		update, errs := parseSLO(body)
+		if len(errs) > 0 {
+			writeErrors(w, http.StatusBadRequest, errs...)
+			return
+		}
+		for key, value := range update {
+			slo[key] = value
+		}
+		writeJson(w, http.StatusOK, object{"data": []object{slo}, "error": nil})
+	case "DELETE":
+		delete(s.slos, id)
+		writeJson(w, http.StatusOK, object{"data": []string{id}, "error": nil})
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// slosReferencing returns the IDs of the SLOs using a monitor
+func (s *Server) slosReferencing(monitorId int64) []string {
+	ids := []string{}
+	for id, slo := range s.slos {
+		for _, sloMonitorId := range sloMonitorIds(slo) {
+			if sloMonitorId == monitorId {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func parseMonitor(body []byte) (object, []string) {
+	monitor, err := parseObject(body)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	errs := []string{}
+	for _, field := range []string{"type", "query", "name"} {
+		if value, ok := monitor[field].(string); !ok || strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Sprintf("The value provided for parameter '%v' is invalid", field))
+		}
+	}
+
+	// Like Datadog, options that aren't sent are set to their defaults
+	options, ok := monitor["options"].(map[string]interface{})
+	if !ok {
+		options = map[string]interface{}{}
+	}
+	for key, value := range monitorOptionDefaults {
+		if _, ok := options[key]; !ok {
+			options[key] = value
+		}
+	}
+	monitor["options"] = options
+
+	// Like Datadog, monitors with a grouped query are multi alerts
+	query := strings.Join(strings.Fields(fmt.Sprint(monitor["query"])), "")
+	if strings.Contains(query, "}by{") || strings.Contains(query, ".by(") {
+		monitor["multi"] = true
+	}
+
+	return monitor, errs
+}
+
+func parseDowntime(body []byte) (object, []string) {
+	downtime, err := parseObject(body)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	if scope, ok := downtime["scope"].([]interface{}); !ok || len(scope) == 0 {
+		return nil, []string{"Scope is required"}
+	}
+
+	return downtime, nil
+}
+
+func parseSLO(body []byte) (object, []string) {
+	slo, err := parseObject(body)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	errs := []string{}
+	if name, ok := slo["name"].(string); !ok || name == "" {
+		errs = append(errs, "The value provided for parameter 'name' is invalid")
+	}
+	switch slo["type"] {
+	case "metric":
+		if _, ok := slo["query"]; !ok {
+			errs = append(errs, "Metric SLOs require a query")
+		}
+	case "monitor":
+		if len(sloMonitorIds(slo)) == 0 {
+			errs = append(errs, "Monitor SLOs require monitor_ids")
+		}
+	default:
+		errs = append(errs, "The value provided for parameter 'type' is invalid")
+	}
+	if thresholds, ok := slo["thresholds"].([]interface{}); !ok || len(thresholds) == 0 {
+		errs = append(errs, "At least one threshold is required")
+	}
+
+	return slo, errs
+}
+
+func parseObject(body []byte) (object, error) {
+	parsed := object{}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("Invalid JSON: %v", err)
+	}
+
+	return parsed, nil
+}
+
+func (o object) copy() object {
+	copied := object{}
+	o.decode(&copied)
+
+	return copied
+}
+
+func (o object) decode(into interface{}) {
+	encoded, _ := json.Marshal(o)
+	_ = json.Unmarshal(encoded, into)
+}
+
+func sloMonitorIds(slo object) []int64 {
+	ids := []int64{}
+	monitorIds, _ := slo["monitor_ids"].([]interface{})
+	for _, id := range monitorIds {
+		ids = append(ids, toInt64(id))
+	}
+
+	return ids
+}
+
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	}
+
+	return 0
+}
+
+func tagsOf(monitor object) []string {
+	tags := []string{}
+	values, _ := monitor["tags"].([]interface{})
+	for _, tag := range values {
+		tags = append(tags, fmt.Sprint(tag))
+	}
+
+	return tags
+}
+
+func hasTags(monitor object, tags []string) bool {
+	monitorTags := map[string]bool{}
+	for _, tag := range tagsOf(monitor) {
+		monitorTags[tag] = true
+	}
+
+	for _, tag := range tags {
+		if !monitorTags[tag] {
+			return false
+		}
+	}
+
+	return true
+}
+
+type searchTerm struct {
+	field string
+	value string
+}
+
+// parseSearchQuery splits a query into terms, keeping quoted values together
+func parseSearchQuery(query string) []searchTerm {
+	terms := []searchTerm{}
+	current := strings.Builder{}
+	quoted := false
+
+	flush := func() {
+		term := current.String()
+		current.Reset()
+		if term == "" {
+			return
+		}
+		parts := strings.SplitN(term, ":", 2)
+		if len(parts) == 2 && (parts[0] == "tag" || parts[0] == "id" || parts[0] == "type" || parts[0] == "status") {
+			terms = append(terms, searchTerm{field: parts[0], value: strings.Trim(parts[1], `"`)})
+		} else {
+			terms = append(terms, searchTerm{value: strings.Trim(term, `"`)})
+		}
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return terms
+}
+
+func matchesSearch(monitor object, terms []searchTerm) bool {
+	for _, term := range terms {
+		switch term.field {
+		case "tag":
+			if !hasTags(monitor, []string{term.value}) {
+				return false
+			}
+		case "id":
+			if fmt.Sprint(monitor["id"]) != term.value {
+				return false
+			}
+		case "type":
+			if fmt.Sprint(monitor["type"]) != term.value {
+				return false
+			}
+		case "status":
+			if !strings.EqualFold(fmt.Sprint(monitor["overall_state"]), term.value) {
+				return false
+			}
+		default:
+			if !strings.Contains(strings.ToLower(fmt.Sprint(monitor["name"])), strings.ToLower(term.value)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func paginate(objects []object, page int, pageSize int) []object {
+	start := page * pageSize
+	if start >= len(objects) || start < 0 {
+		return []object{}
+	}
+
+	end := start + pageSize
+	if end > len(objects) {
+		end = len(objects)
+	}
+
+	return objects[start:end]
+}
+
+// endpointFor returns the endpoint of a request the way rate limits are set,
+// e.g. "GET /monitor/:id"
+func endpointFor(method string, segments []string) string {
+	endpoint := []string{}
+	for i, segment := range segments {
+		if i > 0 && segment != "validate" && segment != "search" {
+			segment = ":id"
+		}
+		endpoint = append(endpoint, segment)
+	}
+
+	return method + " /" + strings.Join(endpoint, "/")
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeErrors(w http.ResponseWriter, status int, errs ...string) {
+	writeJson(w, status, object{"errors": errs})
+}
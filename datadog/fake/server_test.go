@@ -0,0 +1,133 @@
+package fake_test
+
+import (
+	"context"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newClient(server *fake.Server, creds datadog.Credentials) (datadog.Datadog, error) {
+	return datadog.NewForCredentials("INFO", creds, datadog.WithBaseURL(server.URL), datadog.WithRegisterer(prometheus.NewRegistry()))
+}
+
+func TestMonitorLifecycle(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	datadogApi, err := newClient(server, datadog.Credentials{ApiKey: "api-key", AppKey: "app-key"})
+	assert.Nil(t, err)
+
+	spec := v1beta1.DatadogMonitorSpec{
+		Name:  "test",
+		Type:  "metric alert",
+		Query: "avg(last_5m):avg:system.cpu.user{*} > 90",
+		Tags:  []string{"service:web"},
+	}
+
+	monitorId, err := datadogApi.CreateMonitor(ctx, spec, datadog.Owner{})
+	assert.Nil(t, err)
+	assert.NotEqual(t, int64(0), monitorId)
+
+	monitor, err := datadogApi.GetMonitor(ctx, monitorId)
+	assert.Nil(t, err)
+	assert.Equal(t, "test", monitor.Name)
+	assert.Equal(t, "No Data", monitor.OverallState)
+
+	spec.Name = "renamed"
+	assert.Nil(t, datadogApi.UpdateMonitor(ctx, monitorId, spec, datadog.Owner{}))
+	stored, ok := server.Monitor(monitorId)
+	assert.True(t, ok)
+	assert.Equal(t, "renamed", stored.Name)
+	assert.False(t, stored.Multi)
+
+	spec.Query = "avg(last_5m):avg:system.cpu.user{*} by {host} > 90"
+	assert.Nil(t, datadogApi.UpdateMonitor(ctx, monitorId, spec, datadog.Owner{}))
+	stored, _ = server.Monitor(monitorId)
+	assert.True(t, stored.Multi)
+
+	monitors, err := datadogApi.ListMonitors(ctx, datadog.MonitorFilter{Tags: []string{"service:web"}})
+	assert.Nil(t, err)
+	assert.Len(t, monitors, 1)
+
+	assert.Nil(t, datadogApi.DeleteMonitor(ctx, monitorId))
+	_, err = datadogApi.GetMonitor(ctx, monitorId)
+	assert.True(t, datadog.IsNotFound(err))
+}
+
+func TestInvalidMonitor(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	datadogApi, err := newClient(server, datadog.Credentials{})
+	assert.Nil(t, err)
+
+	_, err = datadogApi.CreateMonitor(context.Background(), v1beta1.DatadogMonitorSpec{Name: "test", Type: "metric alert"}, datadog.Owner{})
+	assert.True(t, datadog.IsPermanent(err))
+	assert.Contains(t, err.Error(), "The value provided for parameter 'query' is invalid")
+
+	reasons, err := datadogApi.ValidateMonitor(context.Background(), v1beta1.DatadogMonitorSpec{Name: "test", Query: "avg:system.cpu.user{*}"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"The value provided for parameter 'type' is invalid"}, reasons)
+}
+
+func TestInvalidKeys(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.APIKey = "api-key"
+
+	_, err := newClient(server, datadog.Credentials{ApiKey: "wrong-key"})
+	assert.NotNil(t, err)
+}
+
+func TestMonitorReferencedBySLO(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	datadogApi, err := newClient(server, datadog.Credentials{})
+	assert.Nil(t, err)
+
+	monitorId, err := datadogApi.CreateMonitor(ctx, v1beta1.DatadogMonitorSpec{Name: "test", Type: "metric alert", Query: "avg:system.cpu.user{*} > 90"}, datadog.Owner{})
+	assert.Nil(t, err)
+
+	sloId, err := datadogApi.CreateSLO(ctx, v1beta1.DatadogSLOSpec{
+		Name:       "test",
+		Type:       "monitor",
+		Thresholds: []v1beta1.DatadogSLOThreshold{{Timeframe: "7d", Target: 99.9}},
+	}, []int64{monitorId})
+	assert.Nil(t, err)
+
+	err = datadogApi.DeleteMonitor(ctx, monitorId)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is referenced in slos")
+
+	assert.Nil(t, datadogApi.DeleteSLO(ctx, sloId))
+	assert.Nil(t, datadogApi.DeleteMonitor(ctx, monitorId))
+}
+
+func TestRateLimit(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.SetRateLimit("POST /monitor", 1, time.Minute)
+
+	ctx := context.Background()
+	datadogApi, err := newClient(server, datadog.Credentials{})
+	assert.Nil(t, err)
+
+	spec := v1beta1.DatadogMonitorSpec{Name: "test", Type: "metric alert", Query: "avg:system.cpu.user{*} > 90"}
+
+	_, err = datadogApi.CreateMonitor(ctx, spec, datadog.Owner{})
+	assert.Nil(t, err)
+
+	_, err = datadogApi.CreateMonitor(ctx, spec, datadog.Owner{})
+	retryAfter, ok := datadog.RetryAfter(err)
+	assert.True(t, ok)
+	assert.True(t, retryAfter > 50*time.Second)
+	assert.Equal(t, 1, server.Requests("POST /monitor"))
+}
@@ -0,0 +1,45 @@
+package datadog
+
+import (
+	"github.com/max-rocket-internet/datadog-controller/datadog/restclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The metrics of a client. Clients registered with the same registry share
+// their metrics.
+type metrics struct {
+	monitorEvents  *prometheus.CounterVec
+	downtimeEvents *prometheus.CounterVec
+	sloEvents      *prometheus.CounterVec
+	rateLimited    *prometheus.CounterVec
+}
+
+func newMetrics(registerer prometheus.Registerer) *metrics {
+	counter := func(opts prometheus.CounterOpts, labels ...string) *prometheus.CounterVec {
+		opts.Namespace = "datadog_controller"
+		return restclient.Register(registerer, prometheus.NewCounterVec(opts, labels)).(*prometheus.CounterVec)
+	}
+
+	return &metrics{
+		monitorEvents: counter(prometheus.CounterOpts{
+			Subsystem: "monitor",
+			Name:      "event",
+			Help:      "Count of monitor create/delete events",
+		}, "action"),
+		downtimeEvents: counter(prometheus.CounterOpts{
+			Subsystem: "downtime",
+			Name:      "event",
+			Help:      "Count of downtime create/delete events",
+		}, "action"),
+		sloEvents: counter(prometheus.CounterOpts{
+			Subsystem: "slo",
+			Name:      "event",
+			Help:      "Count of SLO create/delete events",
+		}, "action"),
+		rateLimited: counter(prometheus.CounterOpts{
+			Subsystem: "api",
+			Name:      "rate_limited",
+			Help:      "Count of requests not made or rejected because of the Datadog rate limit of their endpoint",
+		}, "endpoint"),
+	}
+}
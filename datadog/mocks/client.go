@@ -5,13 +5,10 @@ import (
 )
 
 type MockClient struct {
+	// Handles the requests of this client
 	DoFunc func(req *http.Request) (*http.Response, error)
 }
 
-var (
-	GetDoFunc func(req *http.Request) (*http.Response, error)
-)
-
 func (m *MockClient) Do(req *http.Request) (*http.Response, error) {
-	return GetDoFunc(req)
+	return m.DoFunc(req)
 }
@@ -0,0 +1,154 @@
+package datadog
+
+import (
+	"context"
+	"fmt"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"sort"
+	"strings"
+)
+
+// Tags the controller stamps on the monitors it manages, so monitors whose
+// resource no longer exists can be found in Datadog
+const (
+	OwnerClusterTag   = "datadog-controller-cluster"
+	OwnerNamespaceTag = "datadog-controller-namespace"
+	OwnerNameTag      = "datadog-controller-name"
+	OwnerUIDTag       = "datadog-controller-uid"
+)
+
+// Owner is the resource managing a monitor. The zero value owns nothing and
+// adds no tags.
+type Owner struct {
+	// The name of the cluster the controller runs in, if set
+	Cluster   string
+	Namespace string
+	Name      string
+	UID       string
+}
+
+// Tags returns the ownership tags of the owner. Datadog lowercases tags so
+// the values are lowercased too.
+func (o Owner) Tags() []string {
+	tags := []string{}
+
+	for _, tag := range []struct{ key, value string }{
+		{OwnerClusterTag, o.Cluster},
+		{OwnerNamespaceTag, o.Namespace},
+		{OwnerNameTag, o.Name},
+		{OwnerUIDTag, o.UID},
+	} {
+		if tag.value != "" {
+			tags = append(tags, fmt.Sprintf("%v:%v", tag.key, strings.ToLower(tag.value)))
+		}
+	}
+
+	return tags
+}
+
+// OwnerOf reads the owner of a monitor from its tags
+func OwnerOf(MonitorSpec v1beta1.DatadogMonitorSpec) Owner {
+	owner := Owner{}
+
+	for _, tag := range MonitorSpec.Tags {
+		parts := strings.SplitN(tag, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch parts[0] {
+		case OwnerClusterTag:
+			owner.Cluster = parts[1]
+		case OwnerNamespaceTag:
+			owner.Namespace = parts[1]
+		case OwnerNameTag:
+			owner.Name = parts[1]
+		case OwnerUIDTag:
+			owner.UID = parts[1]
+		}
+	}
+
+	return owner
+}
+
+// isOwnerTag reports whether a tag is one of the ownership tags
+func isOwnerTag(tag string) bool {
+	key := strings.SplitN(tag, ":", 2)[0]
+	return key == OwnerClusterTag || key == OwnerNamespaceTag || key == OwnerNameTag || key == OwnerUIDTag
+}
+
+// WithoutOwnerTags returns the tags without the ownership tags
+func WithoutOwnerTags(Tags []string) []string {
+	tags := []string{}
+	for _, tag := range Tags {
+		if !isOwnerTag(tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return tags
+}
+
+// withOwnerTags replaces the ownership tags of a spec with those of owner
+func withOwnerTags(MonitorSpec v1beta1.DatadogMonitorSpec, owner Owner) v1beta1.DatadogMonitorSpec {
+	MonitorSpec.Tags = append(WithoutOwnerTags(MonitorSpec.Tags), owner.Tags()...)
+	return MonitorSpec
+}
+
+// DisownMonitor removes the ownership tags from a monitor, e.g. when it's
+// left in Datadog after its resource is deleted, so it's not collected as
+// garbage
+func (d Datadog) DisownMonitor(ctx context.Context, MonitorId int64) error {
+	d.Log.V(1).Info(fmt.Sprintf("Removing ownership tags from monitor %v", MonitorId))
+
+	monitor, err := d.GetMonitor(ctx, MonitorId)
+	if err != nil {
+		return err
+	}
+
+	if len(WithoutOwnerTags(monitor.Tags)) == len(monitor.Tags) {
+		return nil
+	}
+
+	return d.UpdateMonitor(ctx, MonitorId, monitor.DatadogMonitorSpec, Owner{})
+}
+
+// FindOwnedMonitor looks for a monitor tagged with the UID of owner, e.g.
+// one created before the ID could be saved in the status of its resource. It
+// returns 0 if there is none. If there are several the oldest is returned.
+// Monitors are listed by tag rather than found with the monitor search, as the
+// search index lags behind and misses a monitor created just before.
+func (d Datadog) FindOwnedMonitor(ctx context.Context, owner Owner) (int64, error) {
+	if owner.UID == "" {
+		return 0, nil
+	}
+
+	uidTag := fmt.Sprintf("%v:%v", OwnerUIDTag, strings.ToLower(owner.UID))
+	monitors, err := d.ListMonitors(ctx, MonitorFilter{Tags: []string{uidTag}})
+	if err != nil {
+		return 0, err
+	}
+
+	ids := []int64{}
+	for _, monitor := range monitors {
+		// Tags are matched loosely so the UID is checked again
+		if monitor.Id != 0 && strings.EqualFold(OwnerOf(monitor).UID, owner.UID) {
+			ids = append(ids, monitor.Id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > 1 {
+		d.Log.Info(fmt.Sprintf("Found %v monitors owned by %v/%v, using the oldest %v", len(ids), owner.Namespace, owner.Name, ids[0]))
+	}
+
+	return ids[0], nil
+}
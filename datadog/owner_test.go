@@ -0,0 +1,110 @@
+package datadog
+
+import (
+	"context"
+	"fmt"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestOwnerTags(t *testing.T) {
+	owner := Owner{Cluster: "Production", Namespace: "default", Name: "my-monitor", UID: "2b7c6c9e-0d35-4a28-9d5c-4b5d3e1f0a11"}
+
+	assert.Equal(t, []string{
+		"datadog-controller-cluster:production",
+		"datadog-controller-namespace:default",
+		"datadog-controller-name:my-monitor",
+		"datadog-controller-uid:2b7c6c9e-0d35-4a28-9d5c-4b5d3e1f0a11",
+	}, owner.Tags())
+	assert.Empty(t, Owner{}.Tags())
+
+	spec := v1beta1.DatadogMonitorSpec{Tags: append([]string{"service:web"}, owner.Tags()...)}
+	spec.Options.IncludeTags = true
+	assert.Equal(t, []string{"service:web"}, WithoutOwnerTags(spec.Tags))
+	assert.Equal(t, "my-monitor", OwnerOf(spec).Name)
+	assert.Empty(t, DiffMonitor(v1beta1.DatadogMonitorSpec{Tags: []string{"service:web"}}, spec))
+}
+
+func TestCreateAndDisownMonitor(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	datadogApi, err := NewForCredentials("INFO", Credentials{}, WithBaseURL(server.URL), WithRegisterer(prometheus.NewRegistry()))
+	assert.Nil(t, err)
+
+	owner := Owner{Cluster: "production", Namespace: "default", Name: "my-monitor", UID: "1234"}
+	spec := v1beta1.DatadogMonitorSpec{Name: "test", Type: "metric alert", Query: "avg:system.cpu.user{*} > 90", Tags: []string{"service:web"}}
+
+	monitorId, err := datadogApi.CreateMonitor(ctx, spec, owner)
+	assert.Nil(t, err)
+
+	monitor, _ := server.Monitor(monitorId)
+	assert.Equal(t, append([]string{"service:web"}, owner.Tags()...), monitor.Tags)
+
+	assert.Nil(t, datadogApi.DisownMonitor(ctx, monitorId))
+
+	monitor, _ = server.Monitor(monitorId)
+	assert.Equal(t, []string{"service:web"}, monitor.Tags)
+	assert.Equal(t, Owner{}, OwnerOf(monitor))
+}
+
+func TestFindOwnedMonitor(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	datadogApi, err := NewForCredentials("INFO", Credentials{}, WithBaseURL(server.URL), WithRegisterer(prometheus.NewRegistry()))
+	assert.Nil(t, err)
+
+	owner := Owner{Namespace: "default", Name: "my-monitor", UID: "1234"}
+	spec := v1beta1.DatadogMonitorSpec{Name: "test", Type: "metric alert", Query: "avg:system.cpu.user{*} > 90"}
+
+	monitorId, err := datadogApi.FindOwnedMonitor(ctx, owner)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), monitorId)
+
+	_, err = datadogApi.CreateMonitor(ctx, spec, Owner{UID: "5678"})
+	assert.Nil(t, err)
+	firstId, err := datadogApi.CreateMonitor(ctx, spec, owner)
+	assert.Nil(t, err)
+	_, err = datadogApi.CreateMonitor(ctx, spec, owner)
+	assert.Nil(t, err)
+
+	monitorId, err = datadogApi.FindOwnedMonitor(ctx, owner)
+	assert.Nil(t, err)
+	assert.Equal(t, firstId, monitorId)
+
+	monitorId, err = datadogApi.FindOwnedMonitor(ctx, Owner{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), monitorId)
+}
+
+func TestFindOwnedMonitorNotSearchable(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	// The search index hasn't caught up with the monitor just created
+	server.SearchIndexDelay = time.Hour
+
+	ctx := context.Background()
+	datadogApi, err := NewForCredentials("INFO", Credentials{}, WithBaseURL(server.URL), WithRegisterer(prometheus.NewRegistry()))
+	assert.Nil(t, err)
+
+	owner := Owner{Namespace: "default", Name: "my-monitor", UID: "1234"}
+	spec := v1beta1.DatadogMonitorSpec{Name: "test", Type: "metric alert", Query: "avg:system.cpu.user{*} > 90"}
+
+	createdId, err := datadogApi.CreateMonitor(ctx, spec, owner)
+	assert.Nil(t, err)
+
+	found, err := datadogApi.SearchMonitors(ctx, fmt.Sprintf("tag:\"%v:1234\"", OwnerUIDTag))
+	assert.Nil(t, err)
+	assert.Empty(t, found)
+
+	monitorId, err := datadogApi.FindOwnedMonitor(ctx, owner)
+	assert.Nil(t, err)
+	assert.Equal(t, createdId, monitorId)
+}
@@ -0,0 +1,142 @@
+package datadog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/max-rocket-internet/datadog-controller/datadog/restclient"
+	"golang.org/x/time/rate"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Requests that would have to wait longer than this for the rate limit
+	// of their endpoint fail with a RateLimitError instead
+	maxRateLimitWait = 5 * time.Second
+	// How long to wait after a rate limited response without X-RateLimit headers
+	defaultRateLimitReset = 10 * time.Second
+)
+
+// RateLimitError is returned when Datadog rate limits a request, or when a
+// request isn't made as it would exceed the rate limit of its endpoint
+type RateLimitError struct {
+	Method string
+	Path   string
+	// How long until the rate limit resets
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("Rate limit of Datadog reached for %v %v, retry in %v", e.Method, e.Path, e.RetryAfter)
+}
+
+// RetryAfter returns how long to wait before retrying after an error caused
+// by a Datadog rate limit. It reports false for other errors.
+func RetryAfter(err error) (time.Duration, bool) {
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return rateLimitErr.RetryAfter, true
+	}
+
+	return 0, false
+}
+
+// rateLimits paces the requests to each endpoint with a token bucket sized
+// from the X-RateLimit headers Datadog returns. Datadog limits requests per
+// organization so it's shared by all copies of a client.
+type rateLimits struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointLimit
+}
+
+type endpointLimit struct {
+	limiter *rate.Limiter
+	// Set when Datadog reports no requests are left in the current period
+	resetAt time.Time
+}
+
+func newRateLimits() *rateLimits {
+	return &rateLimits{endpoints: map[string]*endpointLimit{}}
+}
+
+// endpoint returns the limit of an endpoint. Endpoints aren't limited until
+// Datadog reports their limit. The caller must hold mu.
+func (l *rateLimits) endpoint(endpoint string) *endpointLimit {
+	limit, ok := l.endpoints[endpoint]
+	if !ok {
+		limit = &endpointLimit{limiter: rate.NewLimiter(rate.Inf, 0)}
+		l.endpoints[endpoint] = limit
+	}
+
+	return limit
+}
+
+// wait blocks until the rate limit of the endpoint allows another request,
+// or the context is done. If that would take longer than maxRateLimitWait it
+// returns how long to wait instead, without using up a request.
+func (l *rateLimits) wait(ctx context.Context, endpoint string) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if l == nil {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	limit := l.endpoint(endpoint)
+	untilReset := time.Until(limit.resetAt)
+	reservation := limit.limiter.Reserve()
+	l.mu.Unlock()
+
+	delay := reservation.Delay()
+	if untilReset > delay {
+		delay = untilReset
+	}
+
+	if delay > maxRateLimitWait {
+		reservation.Cancel()
+		return delay, nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return 0, nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return 0, ctx.Err()
+	}
+}
+
+// update sizes the token bucket of the endpoint from the X-RateLimit headers
+// of a response. It returns how long until the limit resets, or 0 if the
+// response has no rate limit headers.
+func (l *rateLimits) update(endpoint string, header http.Header) time.Duration {
+	rateLimit, ok := restclient.ParseRateLimit(header)
+	if l == nil || !ok {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.endpoint(endpoint)
+
+	// A new limiter starts with a full bucket, requests left in the current
+	// period are tracked with resetAt
+	every := rate.Every(rateLimit.Period / time.Duration(rateLimit.Limit))
+	if limit.limiter.Limit() != every || limit.limiter.Burst() != rateLimit.Limit {
+		limit.limiter = rate.NewLimiter(every, rateLimit.Limit)
+	}
+
+	if rateLimit.Remaining <= 0 {
+		limit.resetAt = time.Now().Add(rateLimit.Reset)
+	}
+
+	return rateLimit.Reset
+}
@@ -0,0 +1,92 @@
+package datadog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog/mocks"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCreateMonitorRateLimited(t *testing.T) {
+	responseJson := `{"errors":["Can not create duplicate monitors: Rate limit of 5 requests in 600 seconds reached. Please try again later."]}`
+	monitorRequests := 0
+
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/api/v1/validate" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson))),
+			}, nil
+		}
+
+		monitorRequests++
+
+		header := http.Header{}
+		header.Set("X-RateLimit-Limit", "5")
+		header.Set("X-RateLimit-Period", "600")
+		header.Set("X-RateLimit-Remaining", "0")
+		header.Set("X-RateLimit-Reset", "60")
+
+		return &http.Response{
+			StatusCode: 429,
+			Header:     header,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(responseJson))),
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	_, err = datadogApi.CreateMonitor(context.Background(), v1beta1.DatadogMonitorSpec{Name: "test"}, Owner{})
+	retryAfter, ok := RetryAfter(err)
+	assert.True(t, ok)
+	assert.Equal(t, 60*time.Second, retryAfter)
+
+	// No request is made until the limit resets
+	_, err = datadogApi.CreateMonitor(context.Background(), v1beta1.DatadogMonitorSpec{Name: "test"}, Owner{})
+	retryAfter, ok = RetryAfter(err)
+	assert.True(t, ok)
+	assert.True(t, retryAfter > 50*time.Second)
+	assert.Equal(t, 1, monitorRequests)
+}
+
+func TestRetryAfterOtherError(t *testing.T) {
+	_, ok := RetryAfter(newAPIError("GET", "/monitor/12345", 404, nil))
+	assert.False(t, ok)
+}
+
+func TestCreateMonitorCancelled(t *testing.T) {
+	monitorRequests := 0
+
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/api/v1/validate" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson))),
+			}, nil
+		}
+
+		monitorRequests++
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"id": 12345}`))),
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = datadogApi.CreateMonitor(ctx, v1beta1.DatadogMonitorSpec{Name: "test"}, Owner{})
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 0, monitorRequests)
+}
@@ -2,40 +2,312 @@ package restclient
 
 import (
 	"bytes"
+	"context"
+	"github.com/go-logr/logr"
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/prometheus/client_golang/prometheus"
 	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
 type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-var (
-	retryableClient = retryablehttp.NewClient()
-	Client          HTTPClient
-)
+// RateLimit is the rate limit of an endpoint as reported by Datadog in the
+// X-RateLimit headers of a response
+type RateLimit struct {
+	// Number of requests allowed in a period
+	Limit int
+	// Length of a period
+	Period time.Duration
+	// Number of requests left in the current period
+	Remaining int
+	// Time until the current period ends
+	Reset time.Duration
+}
+
+// Client makes requests to an HTTP API, retrying them on failures
+type Client struct {
+	baseURL    string
+	headers    http.Header
+	httpClient HTTPClient
+	retry      RetryPolicy
+	log        logr.Logger
+	registerer prometheus.Registerer
+	latency    *prometheus.HistogramVec
+}
+
+// RetryPolicy configures how requests are retried on failures
+type RetryPolicy struct {
+	// Number of retries after the first attempt
+	Max int
+	// Bounds of the backoff between attempts. Rate limited requests wait
+	// until the limit resets instead, if that's within WaitMax.
+	WaitMin time.Duration
+	WaitMax time.Duration
+	// Timeout of each attempt
+	Timeout time.Duration
+}
 
-func init() {
-	retryableClient.Backoff = retryablehttp.LinearJitterBackoff
-	retryableClient.RetryWaitMin = 1 * time.Second
-	retryableClient.RetryWaitMax = 10 * time.Second
-	retryableClient.RetryMax = 2
+// Option configures a Client
+type Option func(*Client)
+
+// DefaultRetryPolicy returns the retry policy of clients created without
+// WithRetryPolicy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Max:     2,
+		WaitMin: 1 * time.Second,
+		WaitMax: 10 * time.Second,
+		Timeout: 30 * time.Second,
+	}
+}
+
+// WithBaseURL sets the URL the paths of requests are relative to
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithHeaders sets headers sent with every request
+func WithHeaders(headers http.Header) Option {
+	return func(c *Client) {
+		c.headers = headers.Clone()
+	}
+}
+
+// WithHTTPClient makes requests with httpClient, e.g. a mock, instead of
+// retrying them with the retry policy
+func WithHTTPClient(httpClient HTTPClient) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithLogger sets the logger retries are logged with
+func WithLogger(log logr.Logger) Option {
+	return func(c *Client) {
+		c.log = log
+	}
+}
+
+// WithRegisterer sets the registry the metrics of the client are registered
+// with. Clients sharing a registry share their metrics.
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(c *Client) {
+		c.registerer = registerer
+	}
+}
+
+// New returns a client configured with the options
+func New(opts ...Option) *Client {
+	c := &Client{
+		headers:    http.Header{},
+		retry:      DefaultRetryPolicy(),
+		log:        ctrl.Log.WithName("restclient"),
+		registerer: prometheus.DefaultRegisterer,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.httpClient == nil {
+		c.httpClient = c.retryingClient()
+	}
+
+	c.latency = Register(c.registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "datadog_controller",
+		Subsystem: "api",
+		Name:      "latency",
+		Help:      "Latency of the Datadog API",
+	}, []string{
+		"endpoint",
+		"response",
+	})).(*prometheus.HistogramVec)
+
+	return c
+}
+
+func (c *Client) retryingClient() *http.Client {
+	policy := c.retry
+
+	retryableClient := retryablehttp.NewClient()
+	retryableClient.Backoff = rateLimitBackoff
+	retryableClient.CheckRetry = policy.checkRetry
+	retryableClient.RetryWaitMin = policy.WaitMin
+	retryableClient.RetryWaitMax = policy.WaitMax
+	retryableClient.RetryMax = policy.Max
 	retryableClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
-	retryableClient.Logger = nil
-	retryableClient.HTTPClient.Timeout = 30 * time.Second
+	retryableClient.Logger = leveledLogger{c.log}
+	retryableClient.HTTPClient.Timeout = policy.Timeout
 
-	Client = retryableClient.StandardClient()
+	return retryableClient.StandardClient()
 }
 
-func Do(method string, url string, body []byte, headers http.Header) (*http.Response, error) {
-	request, err := http.NewRequest(method, url, bytes.NewReader(body))
+// Do makes a request to a path of the base URL, retrying it on failures
+// until the context is done. The headers are sent in addition to the headers
+// of the client.
+func (c *Client) Do(ctx context.Context, method string, path string, body []byte, headers http.Header) (*http.Response, error) {
+	if createsObject(method, path) {
+		ctx = context.WithValue(ctx, createsObjectKey{}, true)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
 
 	if err != nil {
 		return nil, err
 	}
 
-	request.Header = headers
+	request.Header = c.headers.Clone()
+	for key, values := range headers {
+		request.Header[key] = values
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	c.latency.WithLabelValues(Endpoint(method, path), strconv.Itoa(resp.StatusCode)).Observe(time.Since(start).Seconds())
+
+	return resp, nil
+}
+
+// Endpoint returns the endpoint of a request without IDs and query, e.g.
+// "GET /monitor/:id" for a GET of "/monitor/123?group_states=all"
+func Endpoint(method string, path string) string {
+	segments := strings.Split(strings.SplitN(path, "?", 2)[0], "/")
+
+	for i, segment := range segments {
+		if strings.IndexFunc(segment, unicode.IsDigit) >= 0 {
+			segments[i] = ":id"
+		}
+	}
+
+	return method + " " + strings.Join(segments, "/")
+}
+
+// Register registers a collector, or returns the collector already
+// registered with the same description so clients can share a registry
+func Register(registerer prometheus.Registerer, collector prometheus.Collector) prometheus.Collector {
+	if err := registerer.Register(collector); err != nil {
+		if alreadyRegistered, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return alreadyRegistered.ExistingCollector
+		}
+		panic(err)
+	}
+
+	return collector
+}
+
+// ParseRateLimit reads the X-RateLimit headers of a response. It reports
+// false if the response has none.
+func ParseRateLimit(header http.Header) (RateLimit, bool) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil || limit <= 0 {
+		return RateLimit{}, false
+	}
+
+	period, err := strconv.Atoi(header.Get("X-RateLimit-Period"))
+	if err != nil || period <= 0 {
+		return RateLimit{}, false
+	}
+
+	reset, err := strconv.Atoi(header.Get("X-RateLimit-Reset"))
+	if err != nil || reset < 0 {
+		return RateLimit{}, false
+	}
+
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		remaining = limit
+	}
+
+	return RateLimit{
+		Limit:     limit,
+		Period:    time.Duration(period) * time.Second,
+		Remaining: remaining,
+		Reset:     time.Duration(reset) * time.Second,
+	}, true
+}
+
+// createsObjectKey marks the context of a request that creates an object
+type createsObjectKey struct{}
+
+// createsObject reports whether a request creates an object in Datadog, i.e.
+// a POST other than a validation. Sending it twice could create two objects.
+func createsObject(method string, path string) bool {
+	return method == http.MethodPost && !strings.HasSuffix(strings.SplitN(path, "?", 2)[0], "/validate")
+}
+
+// checkRetry retries like retryablehttp except for rate limited requests
+// that can't be retried before the limit resets within WaitMax. Those are
+// returned to the caller to retry later. Requests creating an object are only
+// retried when rate limited, as Datadog may have created the object before
+// failing. Other failures are returned so the caller can look for the object
+// first.
+func (policy RetryPolicy) checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	rateLimited := err == nil && resp.StatusCode == http.StatusTooManyRequests
+
+	if rateLimited {
+		if limit, ok := ParseRateLimit(resp.Header); ok && limit.Reset > policy.WaitMax {
+			return false, nil
+		}
+	}
+
+	if creates, _ := ctx.Value(createsObjectKey{}).(bool); creates && !rateLimited {
+		return false, ctx.Err()
+	}
+
+	return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+}
+
+// rateLimitBackoff waits until the rate limit resets for rate limited
+// requests and backs off exponentially for other failures
+func rateLimitBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		if limit, ok := ParseRateLimit(resp.Header); ok {
+			if limit.Reset < min {
+				return min
+			}
+			return limit.Reset
+		}
+	}
+
+	return retryablehttp.DefaultBackoff(min, max, attemptNum, resp)
+}
+
+// leveledLogger logs the retries of requests
+type leveledLogger struct {
+	log logr.Logger
+}
+
+func (l leveledLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.log.Info(msg, keysAndValues...)
+}
+
+func (l leveledLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log.Info(msg, keysAndValues...)
+}
+
+func (l leveledLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log.V(1).Info(msg, keysAndValues...)
+}
 
-	return Client.Do(request)
+func (l leveledLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log.V(1).Info(msg, keysAndValues...)
 }
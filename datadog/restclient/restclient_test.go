@@ -0,0 +1,81 @@
+package restclient
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEndpoint(t *testing.T) {
+	assert.Equal(t, "GET /monitor/:id", Endpoint("GET", "/monitor/12345?group_states=all"))
+	assert.Equal(t, "POST /monitor", Endpoint("POST", "/monitor"))
+	assert.Equal(t, "PUT /slo/:id", Endpoint("PUT", "/slo/12ab34cd"))
+	assert.Equal(t, "GET /validate", Endpoint("GET", "/validate"))
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		status   int
+		attempts int
+	}{
+		{"retries updates on server errors", "PUT", "/monitor/12345", http.StatusInternalServerError, 3},
+		{"retries validations on server errors", "POST", "/monitor/validate", http.StatusInternalServerError, 3},
+		{"doesn't retry creates on server errors", "POST", "/monitor", http.StatusInternalServerError, 1},
+		{"retries rate limited creates", "POST", "/monitor", http.StatusTooManyRequests, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.Header().Set("X-RateLimit-Limit", "10")
+				w.Header().Set("X-RateLimit-Period", "10")
+				w.Header().Set("X-RateLimit-Reset", "0")
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			client := New(WithBaseURL(server.URL), WithRegisterer(prometheus.NewRegistry()), WithRetryPolicy(RetryPolicy{
+				Max:     2,
+				WaitMin: time.Millisecond,
+				WaitMax: 10 * time.Millisecond,
+				Timeout: time.Second,
+			}))
+
+			resp, err := client.Do(context.Background(), test.method, test.path, nil, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, test.status, resp.StatusCode)
+			assert.Equal(t, test.attempts, attempts)
+		})
+	}
+}
+
+func TestCreateNotRetriedOnConnectionError(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		// Datadog may have created the object before the connection broke
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer server.Close()
+
+	client := New(WithBaseURL(server.URL), WithRegisterer(prometheus.NewRegistry()), WithRetryPolicy(RetryPolicy{
+		Max:     2,
+		WaitMin: time.Millisecond,
+		WaitMax: 10 * time.Millisecond,
+		Timeout: time.Second,
+	}))
+
+	_, err := client.Do(context.Background(), "POST", "/monitor", nil, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,114 @@
+package datadog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+)
+
+// An SLO as sent to the Datadog API. The monitor IDs are resolved by the controller.
+type slo struct {
+	Name        string                        `json:"name"`
+	Description string                        `json:"description,omitempty"`
+	Type        string                        `json:"type"`
+	Query       *v1beta1.DatadogSLOQuery      `json:"query,omitempty"`
+	MonitorIds  []int64                       `json:"monitor_ids,omitempty"`
+	Groups      []string                      `json:"groups,omitempty"`
+	Tags        []string                      `json:"tags,omitempty"`
+	Thresholds  []v1beta1.DatadogSLOThreshold `json:"thresholds"`
+}
+
+// The SLO API wraps responses in a data list
+type sloResponse struct {
+	Data []struct {
+		Id string `json:"id"`
+	} `json:"data"`
+}
+
+func (d Datadog) CreateSLO(ctx context.Context, SLOSpec v1beta1.DatadogSLOSpec, MonitorIds []int64) (string, error) {
+	d.Log.V(1).Info("Creating SLO")
+
+	requestBody, err := sloRequestBody(SLOSpec, MonitorIds)
+	if err != nil {
+		return "", err
+	}
+
+	results, _, err := d.apiRequest(ctx, "POST", "/slo", requestBody)
+	if err != nil {
+		d.metrics.sloEvents.WithLabelValues("failed").Inc()
+		return "", fmt.Errorf("Error creating SLO: %w", err)
+	}
+
+	requestResponse := sloResponse{}
+	err = json.Unmarshal(results, &requestResponse)
+	if err != nil {
+		d.metrics.sloEvents.WithLabelValues("failed").Inc()
+		return "", err
+	}
+
+	if len(requestResponse.Data) == 0 {
+		d.metrics.sloEvents.WithLabelValues("failed").Inc()
+		return "", fmt.Errorf("Error creating SLO, no ID in response: %v", string(results))
+	}
+
+	d.metrics.sloEvents.WithLabelValues("created").Inc()
+
+	return requestResponse.Data[0].Id, nil
+}
+
+func (d Datadog) UpdateSLO(ctx context.Context, SLOId string, SLOSpec v1beta1.DatadogSLOSpec, MonitorIds []int64) error {
+	d.Log.V(1).Info(fmt.Sprintf("Updating SLO '%v'", SLOId))
+
+	requestBody, err := sloRequestBody(SLOSpec, MonitorIds)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = d.apiRequest(ctx, "PUT", fmt.Sprintf("/slo/%v", SLOId), requestBody)
+	if err != nil {
+		d.metrics.sloEvents.WithLabelValues("failed").Inc()
+		return fmt.Errorf("Error updating SLO '%v': %w", SLOId, err)
+	}
+
+	d.metrics.sloEvents.WithLabelValues("updated").Inc()
+
+	return nil
+}
+
+func (d Datadog) DeleteSLO(ctx context.Context, SLOId string) error {
+	d.Log.V(1).Info(fmt.Sprintf("Deleting SLO %v", SLOId))
+
+	_, _, err := d.apiRequest(ctx, "DELETE", fmt.Sprintf("/slo/%v", SLOId), nil)
+	if IsNotFound(err) {
+		d.Log.V(1).Info(fmt.Sprintf("SLO %v already deleted", SLOId))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error deleting SLO: %w", err)
+	}
+
+	d.metrics.sloEvents.WithLabelValues("deleted").Inc()
+
+	return nil
+}
+
+func sloRequestBody(SLOSpec v1beta1.DatadogSLOSpec, MonitorIds []int64) ([]byte, error) {
+	request := slo{
+		Name:        SLOSpec.Name,
+		Description: SLOSpec.Description,
+		Type:        SLOSpec.Type,
+		Query:       SLOSpec.Query,
+		MonitorIds:  MonitorIds,
+		Groups:      SLOSpec.Groups,
+		Tags:        SLOSpec.Tags,
+		Thresholds:  SLOSpec.Thresholds,
+	}
+
+	return json.Marshal(request)
+}
+
+// SLOURL returns the URL of an SLO in the Datadog app
+func (d Datadog) SLOURL(SLOId string) string {
+	return fmt.Sprintf("https://app.%v/slo?slo_id=%v", d.Conf.DatadogHost, SLOId)
+}
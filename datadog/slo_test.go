@@ -0,0 +1,98 @@
+package datadog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog/mocks"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestCreateSLO(t *testing.T) {
+	newSLO := v1beta1.DatadogSLOSpec{}
+	newSLO.Name = "test-slo"
+	newSLO.Type = "monitor"
+	newSLO.MonitorRefs = []string{"test-monitor"}
+	newSLO.Thresholds = []v1beta1.DatadogSLOThreshold{{Timeframe: "30d", Target: 99.9}}
+
+	responseJson := `{"data": [{"id": "abc123"}], "errors": []}`
+	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
+
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := responseJsonBody
+
+		if req.URL.Path == "/api/v1/validate" {
+			body = ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson)))
+		} else {
+			request := map[string]interface{}{}
+			requestBody, _ := ioutil.ReadAll(req.Body)
+			assert.Nil(t, json.Unmarshal(requestBody, &request))
+			assert.Equal(t, "/api/v1/slo", req.URL.Path)
+			assert.Equal(t, []interface{}{float64(12345), float64(67890)}, request["monitor_ids"])
+			assert.NotContains(t, request, "monitor_refs")
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       body,
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	sloId, err := datadogApi.CreateSLO(context.Background(), newSLO, []int64{12345, 67890})
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", sloId)
+}
+
+func TestCreateSLOFail(t *testing.T) {
+	responseJson := `{"errors": ["Invalid query"]}`
+	responseJsonBody := ioutil.NopCloser(bytes.NewReader([]byte(responseJson)))
+
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := responseJsonBody
+		statusCode := 400
+
+		if req.URL.Path == "/api/v1/validate" {
+			body = ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson)))
+			statusCode = 200
+		}
+
+		return &http.Response{
+			StatusCode: statusCode,
+			Body:       body,
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	_, err = datadogApi.CreateSLO(context.Background(), v1beta1.DatadogSLOSpec{}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestDeleteSLO(t *testing.T) {
+	doFunc := func(req *http.Request) (*http.Response, error) {
+		body := ioutil.NopCloser(bytes.NewReader([]byte(`{"data": ["abc123"]}`)))
+
+		if req.URL.Path == "/api/v1/validate" {
+			body = ioutil.NopCloser(bytes.NewReader([]byte(apiKeyValidResponseJson)))
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       body,
+		}, nil
+	}
+
+	datadogApi, err := New("INFO", WithHTTPClient(&mocks.MockClient{DoFunc: doFunc}))
+	assert.Nil(t, err)
+
+	err = datadogApi.DeleteSLO(context.Background(), "abc123")
+	assert.Nil(t, err)
+}
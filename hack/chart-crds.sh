@@ -0,0 +1,25 @@
+#!/bin/sh
+# Generates the CRDs of the Helm chart from the ones in config/crd/bases,
+# adding the labels of the chart. Run with make chart-crds.
+set -e
+
+cd "$(dirname "$0")/.."
+
+for crd in config/crd/bases/*.yaml; do
+	plural=$(basename "$crd" .yaml | sed 's/^datadoghq\.com_//')
+
+	awk '
+		NR <= 2 && (/^$/ || /^---$/) { next }
+		/^  creationTimestamp: null$/ { next }
+		/^status:$/ { exit }
+		{ print }
+		!labelled && /^  name: / {
+			print "  labels:"
+			print "    app.kubernetes.io/name: {{ include \"datadog-controller.name\" . }}"
+			print "    helm.sh/chart: {{ include \"datadog-controller.chart\" . }}"
+			print "    app.kubernetes.io/instance: {{ .Release.Name }}"
+			print "    app.kubernetes.io/managed-by: {{ .Release.Service }}"
+			labelled = 1
+		}
+	' "$crd" > "chart/templates/crd-$plural.yaml"
+done
@@ -0,0 +1,159 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package importer generates DatadogMonitor manifests from monitors that
+// already exist in Datadog
+package importer
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"io"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+type Options struct {
+	// Namespace of the generated resources. Left out of the manifests when empty.
+	Namespace string
+	// Set the adoption annotation so applying the manifests takes ownership
+	// of the existing monitors instead of creating duplicates
+	Adopt bool
+}
+
+type manifestMetadata struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type manifest struct {
+	APIVersion string                     `json:"apiVersion"`
+	Kind       string                     `json:"kind"`
+	Metadata   manifestMetadata           `json:"metadata"`
+	Spec       v1beta1.DatadogMonitorSpec `json:"spec"`
+}
+
+var (
+	invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Run is the entrypoint of the import command. It lists monitors from Datadog
+// and writes them to out as a multi-document YAML stream.
+func Run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	logLevel := flags.String("log-level", "INFO",
+		"The logging level. Can be DEBUG or INFO")
+	name := flags.String("name", "",
+		"Only import monitors whose name contains this string")
+	tags := flags.String("tags", "",
+		"Only import monitors with all of these comma separated monitor tags")
+	query := flags.String("query", "",
+		"Only import monitors whose query contains this string")
+	namespace := flags.String("namespace", "",
+		"Namespace of the generated resources")
+	adopt := flags.Bool("adopt", true,
+		"Set the "+v1beta1.AdoptMonitorIdAnnotation+" annotation so applying the resources takes ownership of the existing monitors")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	datadogApi, err := datadog.New(*logLevel)
+	if err != nil {
+		return fmt.Errorf("Unable to create working datadog configuration: %v", err)
+	}
+
+	filter := datadog.MonitorFilter{
+		Name:  *name,
+		Query: *query,
+	}
+	if *tags != "" {
+		filter.Tags = strings.Split(*tags, ",")
+	}
+
+	monitors, err := datadogApi.ListMonitors(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+
+	manifests, err := Manifests(monitors, Options{Namespace: *namespace, Adopt: *adopt})
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(manifests)
+	return err
+}
+
+// Manifests renders monitors from Datadog as DatadogMonitor resources
+func Manifests(monitors []v1beta1.DatadogMonitorSpec, options Options) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	for _, monitor := range monitors {
+		m := manifest{
+			APIVersion: v1beta1.GroupVersion.String(),
+			Kind:       "DatadogMonitor",
+			Metadata: manifestMetadata{
+				Name:      ResourceName(monitor),
+				Namespace: options.Namespace,
+			},
+			Spec: monitor,
+		}
+		m.Spec.Id = 0
+		// Monitors managed by a controller get new ownership tags when applied
+		m.Spec.Tags = datadog.WithoutOwnerTags(monitor.Tags)
+
+		if options.Adopt {
+			m.Metadata.Annotations = map[string]string{
+				v1beta1.AdoptMonitorIdAnnotation: fmt.Sprint(monitor.Id),
+			}
+		}
+
+		document, err := yaml.Marshal(m)
+		if err != nil {
+			return nil, fmt.Errorf("Error rendering monitor %v: %v", monitor.Id, err)
+		}
+
+		buffer.WriteString("---\n")
+		buffer.Write(document)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// ResourceName derives a valid and unique resource name from the monitor name and ID
+func ResourceName(monitor v1beta1.DatadogMonitorSpec) string {
+	suffix := fmt.Sprintf("-%v", monitor.Id)
+
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(monitor.Name), "-")
+	name = strings.Trim(name, "-")
+
+	if maxLength := 63 - len(suffix); len(name) > maxLength {
+		name = strings.TrimRight(name[:maxLength], "-")
+	}
+
+	if name == "" {
+		return fmt.Sprintf("monitor%v", suffix)
+	}
+
+	return name + suffix
+}
@@ -0,0 +1,52 @@
+package importer
+
+import (
+	"github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestResourceName(t *testing.T) {
+	monitor := v1beta1.DatadogMonitorSpec{}
+	monitor.Id = 12345
+	monitor.Name = "[prod] My Service: error rate > 5%"
+
+	assert.Equal(t, "prod-my-service-error-rate-5-12345", ResourceName(monitor))
+
+	monitor.Name = "!!!"
+	assert.Equal(t, "monitor-12345", ResourceName(monitor))
+
+	monitor.Name = strings.Repeat("a", 100)
+	assert.Len(t, ResourceName(monitor), 63)
+}
+
+func TestManifests(t *testing.T) {
+	monitor := v1beta1.DatadogMonitorSpec{}
+	monitor.Id = 12345
+	monitor.Name = "test-import"
+	monitor.Message = "test-message"
+	monitor.Query = "test-query"
+	monitor.Type = "query alert"
+
+	expected := `---
+apiVersion: datadoghq.com/v1beta1
+kind: DatadogMonitor
+metadata:
+  annotations:
+    datadoghq.com/adopt-monitor-id: "12345"
+  name: test-import-12345
+  namespace: monitoring
+spec:
+  message: test-message
+  name: test-import
+  options:
+    thresholds: {}
+  query: test-query
+  type: query alert
+`
+
+	actual, err := Manifests([]v1beta1.DatadogMonitorSpec{monitor}, Options{Namespace: "monitoring", Adopt: true})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(actual))
+}
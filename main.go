@@ -17,16 +17,25 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
 	"github.com/max-rocket-internet/datadog-controller/controllers"
 	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/max-rocket-internet/datadog-controller/datadog/fake"
+	"github.com/max-rocket-internet/datadog-controller/importer"
+	"github.com/max-rocket-internet/datadog-controller/webhooks"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sort"
+	"strings"
+	"time"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -42,6 +51,15 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+		if err := importer.Run(os.Args[2:], os.Stdout); err != nil {
+			setupLog.Error(err, "unable to import monitors")
+			os.Exit(1)
+		}
+		return
+	}
+
 	logLevel := flag.String("log-level", "DEBUG",
 		"The logging level. Can be DEBUG or INFO")
 	enableLeaderElection := flag.Bool("enable-leader-election", false,
@@ -50,15 +68,56 @@ func main() {
 	metricsAddr := flag.String("metrics-addr", "0",
 		"The address the metric endpoint binds to. "+
 			"Can be set to 0 to disable metrics serving.")
+	resyncPeriod := flag.Duration("resync-period", 10*time.Minute,
+		"How often monitors are compared with Datadog to detect changes made outside the controller and recreate deleted monitors. "+
+			"Can be overridden per monitor with the datadoghq.com/resync-period annotation. Can be set to 0 to disable drift detection.")
+	enableWebhook := flag.Bool("enable-webhook", false,
+		"Serve the validating admission webhook for DatadogMonitors.")
+	webhookPort := flag.Int("webhook-port", 9443,
+		"The port the webhook server binds to.")
+	webhookCertDir := flag.String("webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs",
+		"The directory containing tls.crt and tls.key for the webhook server.")
+	validateWithDatadog := flag.Bool("validate-with-datadog", false,
+		"Also check monitors with the Datadog validate endpoint in the webhook.")
+	dryRun := flag.Bool("dry-run", false,
+		"Only plan changes to resources in Datadog and record them in their status and events, without making them.")
+	deletionPolicy := flag.String("deletion-policy", datadoghqcomv1beta1.DeletionPolicyDelete,
+		"What to do with a monitor in Datadog when its DatadogMonitor is deleted and it doesn't set deletion_policy. "+
+			"Can be Delete or Orphan.")
+	credentialsSecret := flag.String("credentials-secret", "",
+		"The namespace/name of a Secret with the DD_CLIENT_API_KEY and DD_CLIENT_APP_KEY keys. "+
+			"The keys are reloaded when the Secret changes. Defaults to the environment variables.")
+	clusterName := flag.String("cluster-name", "",
+		"The name of this cluster in the ownership tags of monitors. "+
+			"Must be unique across the clusters using the same Datadog organization. Required for garbage collection.")
+	gcInterval := flag.Duration("gc-interval", time.Hour,
+		"How often to look for monitors in Datadog tagged with --cluster-name that no DatadogMonitor owns, "+
+			"in the default organization and those of DatadogCredentials. Can be set to 0 to disable garbage collection.")
+	gcPolicy := flag.String("gc-policy", controllers.GarbageCollectionPolicyReport,
+		"What to do with orphaned monitors found by the garbage collector. Can be Report or Delete.")
+	templateVars := templateVarsFlag{}
+	flag.Var(templateVars, "template-var",
+		"A variable for the templates in monitor specs as key=value, available as [[ .Vars.key ]]. Can be repeated.")
+	enableMonitorTemplates := flag.Bool("enable-monitor-templates", false,
+		"Generate monitors from DatadogMonitorTemplates. This caches all namespaces, Deployments and Services in the cluster.")
+	enableWorkloadMonitors := flag.Bool("enable-workload-monitors", false,
+		"Generate monitors from the presets in the datadoghq.com/monitors annotation of Deployments, StatefulSets and Services. "+
+			"This caches all of them in the cluster.")
+	fakeDatadog := flag.Bool("fake-datadog", false,
+		"Use an in-memory fake of the Datadog API instead of Datadog, e.g. to run the controller locally without keys. "+
+			"Nothing is kept when the controller stops.")
 
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
-	datadogApi, err := datadog.New(*logLevel)
+	if *deletionPolicy != datadoghqcomv1beta1.DeletionPolicyDelete && *deletionPolicy != datadoghqcomv1beta1.DeletionPolicyOrphan {
+		setupLog.Error(fmt.Errorf("must be %v or %v: %q", datadoghqcomv1beta1.DeletionPolicyDelete, datadoghqcomv1beta1.DeletionPolicyOrphan, *deletionPolicy), "invalid --deletion-policy")
+		os.Exit(1)
+	}
 
-	if err != nil {
-		setupLog.Error(err, "unable to create working datadog configuration")
+	if *gcPolicy != controllers.GarbageCollectionPolicyReport && *gcPolicy != controllers.GarbageCollectionPolicyDelete {
+		setupLog.Error(fmt.Errorf("must be %v or %v: %q", controllers.GarbageCollectionPolicyReport, controllers.GarbageCollectionPolicyDelete, *gcPolicy), "invalid --gc-policy")
 		os.Exit(1)
 	}
 
@@ -67,6 +126,8 @@ func main() {
 		MetricsBindAddress: *metricsAddr,
 		LeaderElection:     *enableLeaderElection,
 		LeaderElectionID:   "03bd7fbd.datadoghq.com",
+		Port:               *webhookPort,
+		CertDir:            *webhookCertDir,
 	})
 
 	if err != nil {
@@ -74,16 +135,157 @@ func main() {
 		os.Exit(1)
 	}
 
+	var datadogApi datadog.Datadog
+	var datadogOptions []datadog.Option
+	var credentials datadog.Credentials
+	var secretName types.NamespacedName
+
+	if *fakeDatadog {
+		fakeServer := fake.NewServer()
+		defer fakeServer.Close()
+
+		setupLog.Info(fmt.Sprintf("Using a fake Datadog API at %v", fakeServer.URL))
+		*credentialsSecret = ""
+		datadogOptions = append(datadogOptions, datadog.WithBaseURL(fakeServer.URL))
+		datadogApi, err = datadog.NewForCredentials(*logLevel, datadog.Credentials{}, datadogOptions...)
+	} else if *credentialsSecret != "" {
+		parts := strings.SplitN(*credentialsSecret, "/", 2)
+		if len(parts) != 2 {
+			setupLog.Error(fmt.Errorf("must be namespace/name: %q", *credentialsSecret), "invalid --credentials-secret")
+			os.Exit(1)
+		}
+		secretName = types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+
+		// The manager's cache isn't started yet so the Secret is read directly
+		credentials, err = controllers.LoadCredentialsSecret(context.Background(), mgr.GetAPIReader(), secretName)
+		if err == nil {
+			datadogApi, err = datadog.NewForCredentials(*logLevel, credentials)
+		}
+	} else {
+		datadogApi, err = datadog.New(*logLevel)
+	}
+
+	if err != nil {
+		setupLog.Error(err, "unable to create working datadog configuration")
+		os.Exit(1)
+	}
+
+	datadogClients := &controllers.DatadogClients{
+		Default:  datadogApi,
+		LogLevel: *logLevel,
+		Options:  datadogOptions,
+		Secrets:  mgr.GetAPIReader(),
+	}
+
+	if err = (&controllers.CredentialsSecretReconciler{
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName("CredentialsSecret"),
+		Recorder:    mgr.GetEventRecorderFor("datadog-controller"),
+		Datadog:     datadogClients,
+		Secrets:     mgr.GetAPIReader(),
+		Secret:      secretName,
+		Credentials: credentials,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CredentialsSecret")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.DatadogMonitorReconciler{
-		Client:   mgr.GetClient(),
-		Log:      ctrl.Log.WithName("controllers").WithName("DatadogMonitor"),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("datadog-controller"),
-		Datadog:  datadogApi,
+		Client:         mgr.GetClient(),
+		Log:            ctrl.Log.WithName("controllers").WithName("DatadogMonitor"),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("datadog-controller"),
+		Datadog:        datadogClients,
+		ResyncPeriod:   *resyncPeriod,
+		DryRun:         *dryRun,
+		DeletionPolicy: *deletionPolicy,
+		ClusterName:    *clusterName,
+		TemplateVars:   templateVars,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DatadogMonitor")
 		os.Exit(1)
 	}
+
+	if *clusterName != "" && *gcInterval > 0 {
+		if err = mgr.Add(&controllers.MonitorGarbageCollector{
+			Client:      mgr.GetClient(),
+			Log:         ctrl.Log.WithName("controllers").WithName("MonitorGarbageCollector"),
+			Datadog:     datadogClients,
+			ClusterName: *clusterName,
+			Interval:    *gcInterval,
+			Policy:      *gcPolicy,
+			DryRun:      *dryRun,
+		}); err != nil {
+			setupLog.Error(err, "unable to create garbage collector")
+			os.Exit(1)
+		}
+	}
+
+	if err = (&controllers.DatadogDowntimeReconciler{
+		Client:       mgr.GetClient(),
+		Log:          ctrl.Log.WithName("controllers").WithName("DatadogDowntime"),
+		Scheme:       mgr.GetScheme(),
+		Recorder:     mgr.GetEventRecorderFor("datadog-controller"),
+		Datadog:      datadogClients,
+		ResyncPeriod: *resyncPeriod,
+		DryRun:       *dryRun,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DatadogDowntime")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.DatadogSLOReconciler{
+		Client:       mgr.GetClient(),
+		Log:          ctrl.Log.WithName("controllers").WithName("DatadogSLO"),
+		Scheme:       mgr.GetScheme(),
+		Recorder:     mgr.GetEventRecorderFor("datadog-controller"),
+		Datadog:      datadogClients,
+		ResyncPeriod: *resyncPeriod,
+		DryRun:       *dryRun,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DatadogSLO")
+		os.Exit(1)
+	}
+
+	if *enableMonitorTemplates {
+		if err = (&controllers.DatadogMonitorTemplateReconciler{
+			Client:       mgr.GetClient(),
+			Log:          ctrl.Log.WithName("controllers").WithName("DatadogMonitorTemplate"),
+			Scheme:       mgr.GetScheme(),
+			Recorder:     mgr.GetEventRecorderFor("datadog-controller"),
+			ClusterName:  *clusterName,
+			TemplateVars: templateVars,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "DatadogMonitorTemplate")
+			os.Exit(1)
+		}
+	}
+
+	if *enableWorkloadMonitors {
+		for _, kind := range []string{"Deployment", "StatefulSet", "Service"} {
+			if err = (&controllers.WorkloadMonitorReconciler{
+				Client:   mgr.GetClient(),
+				Log:      ctrl.Log.WithName("controllers").WithName(kind + "Monitors"),
+				Scheme:   mgr.GetScheme(),
+				Recorder: mgr.GetEventRecorderFor("datadog-controller"),
+				Kind:     kind,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", kind+"Monitors")
+				os.Exit(1)
+			}
+		}
+	}
+
+	if *enableWebhook {
+		if err = (&webhooks.DatadogMonitorValidator{
+			Log:                 ctrl.Log.WithName("webhooks").WithName("DatadogMonitor"),
+			Datadog:             datadogApi,
+			ValidateWithDatadog: *validateWithDatadog,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "DatadogMonitor")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -92,3 +294,26 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// templateVarsFlag collects repeated key=value flags into a map
+type templateVarsFlag map[string]string
+
+func (f templateVarsFlag) String() string {
+	vars := []string{}
+	for key, value := range f {
+		vars = append(vars, key+"="+value)
+	}
+	sort.Strings(vars)
+
+	return strings.Join(vars, ",")
+}
+
+func (f templateVarsFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("must be key=value: %q", value)
+	}
+	f[parts[0]] = parts[1]
+
+	return nil
+}
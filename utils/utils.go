@@ -25,6 +25,20 @@ func RemoveString(slice []string, s string) (result []string) {
 	return
 }
 
+func EqualStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
 func GetEnvString(params ...string) (string, error) {
 	if value, exists := os.LookupEnv(params[0]); exists {
 		return value, nil
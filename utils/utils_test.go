@@ -65,6 +65,27 @@ func TestRemoveString(t *testing.T) {
 	}
 }
 
+func TestEqualStrings(t *testing.T) {
+	a := []string{"one", "two"}
+
+	expected := true
+	actual := EqualStrings(a, []string{"one", "two"})
+	if actual != expected {
+		t.Errorf("Got %t, expected %t, given %v", actual, expected, a)
+	}
+
+	expected = false
+	actual = EqualStrings(a, []string{"two", "one"})
+	if actual != expected {
+		t.Errorf("Got %t, expected %t, given %v", actual, expected, a)
+	}
+
+	actual = EqualStrings(a, []string{"one"})
+	if actual != expected {
+		t.Errorf("Got %t, expected %t, given %v", actual, expected, a)
+	}
+}
+
 func TestGetEnvString(t *testing.T) {
 	os.Setenv("TEST_ENV0", "1")
 
@@ -0,0 +1,113 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
+)
+
+const (
+	DatadogMonitorValidatePath = "/validate-datadoghq-com-v1beta1-datadogmonitor"
+)
+
+// DatadogMonitorValidator rejects DatadogMonitors with a spec that Datadog
+// would refuse, so the mistake is reported by `kubectl apply` instead of as a
+// FailedCreate or FailedUpdate event
+type DatadogMonitorValidator struct {
+	Log     logr.Logger
+	Datadog datadog.MonitorAPI
+	// Also check the monitor with the Datadog validate endpoint
+	ValidateWithDatadog bool
+	decoder             *admission.Decoder
+}
+
+// +kubebuilder:webhook:path=/validate-datadoghq-com-v1beta1-datadogmonitor,mutating=false,failurePolicy=fail,groups=datadoghq.com,resources=datadogmonitors,verbs=create;update,versions=v1beta1,name=vdatadogmonitor.datadoghq.com
+
+func (v *DatadogMonitorValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	instance := &datadoghqcomv1beta1.DatadogMonitor{}
+
+	if err := v.decoder.Decode(req, instance); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	log := v.Log.WithValues("monitor", fmt.Sprintf("%v/%v", instance.Namespace, instance.Name))
+
+	// Updates that don't change the spec, such as adding or removing the
+	// finalizer, are always allowed so existing resources can't get stuck
+	if req.Operation == admissionv1beta1.Update {
+		old := &datadoghqcomv1beta1.DatadogMonitor{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		if equality.Semantic.DeepEqual(old.Spec, instance.Spec) {
+			return admission.Allowed("")
+		}
+
+		// The monitor ID in the status belongs to the organization it was
+		// created in
+		if old.Status.Id != 0 && old.Spec.CredentialsRef != instance.Spec.CredentialsRef {
+			return admission.Denied("spec.credentials_ref: Forbidden: can't be changed once the monitor is created in Datadog")
+		}
+	}
+
+	if errs := instance.Spec.Validate(); len(errs) > 0 {
+		log.V(1).Info(fmt.Sprintf("Rejecting monitor: %v", errs.ToAggregate()))
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+
+	// Queries with references to other resources or templates can't be
+	// checked until they are resolved by the controller, and monitors of
+	// other organizations can't be checked with the keys of the controller
+	if !v.ValidateWithDatadog || strings.Contains(instance.Spec.Query, "${") || instance.Spec.HasTemplates() || instance.Spec.CredentialsRef != "" {
+		return admission.Allowed("")
+	}
+
+	reasons, err := v.Datadog.ValidateMonitor(ctx, instance.Spec)
+	if err != nil {
+		log.Error(err, "Failed to validate monitor with Datadog, allowing it")
+		return admission.Allowed("")
+	}
+
+	if len(reasons) > 0 {
+		log.V(1).Info(fmt.Sprintf("Rejecting monitor: %v", strings.Join(reasons, ", ")))
+		return admission.Denied(fmt.Sprintf("Rejected by Datadog: %v", strings.Join(reasons, ", ")))
+	}
+
+	return admission.Allowed("")
+}
+
+func (v *DatadogMonitorValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+func (v *DatadogMonitorValidator) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(DatadogMonitorValidatePath, &webhook.Admission{Handler: v})
+	return nil
+}
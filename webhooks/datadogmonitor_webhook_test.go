@@ -0,0 +1,220 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	datadoghqcomv1beta1 "github.com/max-rocket-internet/datadog-controller/api/v1beta1"
+	"github.com/max-rocket-internet/datadog-controller/datadog"
+	"github.com/stretchr/testify/assert"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"testing"
+)
+
+// validatingAPI is a MonitorAPI that only implements ValidateMonitor
+type validatingAPI struct {
+	datadog.MonitorAPI
+	reasons []string
+	err     error
+	calls   int
+}
+
+func (a *validatingAPI) ValidateMonitor(ctx context.Context, spec datadoghqcomv1beta1.DatadogMonitorSpec) ([]string, error) {
+	a.calls++
+	return a.reasons, a.err
+}
+
+func testMonitor(modify func(monitor *datadoghqcomv1beta1.DatadogMonitor)) *datadoghqcomv1beta1.DatadogMonitor {
+	monitor := &datadoghqcomv1beta1.DatadogMonitor{
+		TypeMeta:   metav1.TypeMeta{APIVersion: datadoghqcomv1beta1.GroupVersion.String(), Kind: "DatadogMonitor"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cpu"},
+		Spec: datadoghqcomv1beta1.DatadogMonitorSpec{
+			Name:  "High CPU",
+			Type:  "metric alert",
+			Query: "avg(last_5m):avg:system.cpu.user{*} > 90",
+		},
+	}
+	if modify != nil {
+		modify(monitor)
+	}
+
+	return monitor
+}
+
+func rawMonitor(t *testing.T, monitor *datadoghqcomv1beta1.DatadogMonitor) runtime.RawExtension {
+	if monitor == nil {
+		return runtime.RawExtension{}
+	}
+
+	raw, err := json.Marshal(monitor)
+	assert.Nil(t, err)
+
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestHandle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, datadoghqcomv1beta1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name string
+		// The stored monitor for an update, nil for a create
+		old     *datadoghqcomv1beta1.DatadogMonitor
+		monitor *datadoghqcomv1beta1.DatadogMonitor
+		api     *validatingAPI
+		allowed bool
+		reason  string
+		// Whether the monitor is checked with Datadog
+		validated bool
+	}{
+		{
+			name:      "allows a monitor Datadog accepts",
+			monitor:   testMonitor(nil),
+			api:       &validatingAPI{},
+			allowed:   true,
+			validated: true,
+		},
+		{
+			name:      "denies a monitor Datadog rejects",
+			monitor:   testMonitor(nil),
+			api:       &validatingAPI{reasons: []string{"The value provided for parameter 'query' is invalid"}},
+			reason:    "Rejected by Datadog: The value provided for parameter 'query' is invalid",
+			validated: true,
+		},
+		{
+			name:      "allows a monitor when Datadog can't validate it",
+			monitor:   testMonitor(nil),
+			api:       &validatingAPI{err: errors.New("Service Unavailable")},
+			allowed:   true,
+			validated: true,
+		},
+		{
+			name:    "denies an invalid spec without asking Datadog",
+			monitor: testMonitor(func(monitor *datadoghqcomv1beta1.DatadogMonitor) { monitor.Spec.Priority = 6 }),
+			api:     &validatingAPI{},
+			reason:  "spec.priority: Invalid value: 6: must be from 1 (high) to 5 (low)",
+		},
+		{
+			name: "skips Datadog for references",
+			monitor: testMonitor(func(monitor *datadoghqcomv1beta1.DatadogMonitor) {
+				monitor.Spec.Type = "composite"
+				monitor.Spec.Query = "${monitor:cpu} && ${monitor:memory}"
+			}),
+			api:     &validatingAPI{err: errors.New("not called")},
+			allowed: true,
+		},
+		{
+			name:    "skips Datadog for templates",
+			monitor: testMonitor(func(monitor *datadoghqcomv1beta1.DatadogMonitor) { monitor.Spec.Name = "High CPU on [[ .Cluster ]]" }),
+			api:     &validatingAPI{err: errors.New("not called")},
+			allowed: true,
+		},
+		{
+			name:    "skips Datadog for monitors of other organizations",
+			monitor: testMonitor(func(monitor *datadoghqcomv1beta1.DatadogMonitor) { monitor.Spec.CredentialsRef = "team-a" }),
+			api:     &validatingAPI{err: errors.New("not called")},
+			allowed: true,
+		},
+		{
+			name: "allows updates that leave the spec unchanged",
+			old:  testMonitor(func(monitor *datadoghqcomv1beta1.DatadogMonitor) { monitor.Spec.Priority = 6 }),
+			monitor: testMonitor(func(monitor *datadoghqcomv1beta1.DatadogMonitor) {
+				monitor.Spec.Priority = 6
+				monitor.Finalizers = []string{"datadogmonitors.finalizers.datadoghq.com"}
+			}),
+			api:     &validatingAPI{reasons: []string{"not called"}},
+			allowed: true,
+		},
+		{
+			name:      "validates updates of the spec",
+			old:       testMonitor(nil),
+			monitor:   testMonitor(func(monitor *datadoghqcomv1beta1.DatadogMonitor) { monitor.Spec.Message = "CPU is high" }),
+			api:       &validatingAPI{reasons: []string{"Invalid message"}},
+			reason:    "Rejected by Datadog: Invalid message",
+			validated: true,
+		},
+		{
+			name:    "denies changing credentials_ref of a created monitor",
+			old:     testMonitor(func(monitor *datadoghqcomv1beta1.DatadogMonitor) { monitor.Status.Id = 11 }),
+			monitor: testMonitor(func(monitor *datadoghqcomv1beta1.DatadogMonitor) { monitor.Spec.CredentialsRef = "team-a" }),
+			api:     &validatingAPI{},
+			reason:  "spec.credentials_ref: Forbidden: can't be changed once the monitor is created in Datadog",
+		},
+		{
+			name:    "allows changing credentials_ref before the monitor is created",
+			old:     testMonitor(nil),
+			monitor: testMonitor(func(monitor *datadoghqcomv1beta1.DatadogMonitor) { monitor.Spec.CredentialsRef = "team-a" }),
+			api:     &validatingAPI{},
+			allowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := &DatadogMonitorValidator{
+				Log:                 ctrl.Log.WithName("test"),
+				Datadog:             test.api,
+				ValidateWithDatadog: true,
+			}
+			assert.Nil(t, v.InjectDecoder(decoder))
+
+			operation := admissionv1beta1.Create
+			if test.old != nil {
+				operation = admissionv1beta1.Update
+			}
+
+			response := v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: operation,
+				Object:    rawMonitor(t, test.monitor),
+				OldObject: rawMonitor(t, test.old),
+			}})
+
+			assert.Equal(t, test.allowed, response.Allowed)
+			if !test.allowed {
+				assert.Equal(t, metav1.StatusReason(test.reason), response.Result.Reason)
+			}
+			assert.Equal(t, test.validated, test.api.calls > 0)
+		})
+	}
+}
+
+func TestHandleWithoutDatadog(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, datadoghqcomv1beta1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	assert.Nil(t, err)
+
+	api := &validatingAPI{reasons: []string{"not called"}}
+	v := &DatadogMonitorValidator{Log: ctrl.Log.WithName("test"), Datadog: api}
+	assert.Nil(t, v.InjectDecoder(decoder))
+
+	response := v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+		Operation: admissionv1beta1.Create,
+		Object:    rawMonitor(t, testMonitor(nil)),
+	}})
+
+	assert.True(t, response.Allowed)
+	assert.Zero(t, api.calls)
+}